@@ -0,0 +1,32 @@
+package kvbase
+
+// ValueRef locates the stored bytes of a single key inside of an arena filled by ReadManyInto
+type ValueRef struct {
+	Index  int
+	Offset int
+	Length int
+	Found  bool
+}
+
+// Bytes returns the value referenced inside of the provided arena
+func (ref ValueRef) Bytes(arena []byte) []byte {
+	return arena[ref.Offset : ref.Offset+ref.Length]
+}
+
+// ArenaReader is implemented by backends able to copy many raw values into a caller-provided buffer
+type ArenaReader interface {
+	ReadManyInto(bucket string, keys []string, arena []byte) ([]ValueRef, []byte, error)
+}
+
+// ReadManyInto copies the stored bytes of the provided keys contiguously into arena, growing it when needed, and
+// returns one ValueRef per key alongside the (possibly reallocated) arena. The arena is truncated before use, so it can
+// be reused across calls once the previous results have been consumed. Values are copied, so the arena remains valid
+// for as long as the caller keeps it.
+func ReadManyInto(store Backend, bucket string, keys []string, arena []byte) ([]ValueRef, []byte, error) {
+	reader, ok := store.(ArenaReader)
+	if !ok {
+		return nil, arena, ErrNotSupported
+	}
+
+	return reader.ReadManyInto(bucket, keys, arena)
+}
@@ -0,0 +1,109 @@
+package kvbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"sync"
+)
+
+var arrayLocks [64]sync.Mutex
+
+// AppendToArray appends element to the JSON array stored under the provided key, creating the record if it doesn't
+// exist. When maxLen is positive the array is trimmed to its newest maxLen elements. Appends to the same key are
+// serialized within the process.
+func AppendToArray(store Backend, bucket string, key string, element interface{}, maxLen int) error {
+	lock := arrayLock(bucket, key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	data, err := json.Marshal(element)
+	if err != nil {
+		return err
+	}
+
+	var raw json.RawMessage
+	if err := store.Read(bucket, key, &raw); errors.Is(err, ErrKeyNotFound) {
+		return store.Create(bucket, key, []json.RawMessage{data})
+	} else if err != nil {
+		return err
+	}
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) < 2 || raw[0] != '[' || raw[len(raw)-1] != ']' {
+		return ErrNotArray
+	}
+
+	if maxLen <= 0 {
+		// Without trimming, the element can be spliced in before the closing bracket without decoding the array
+		appended := make(json.RawMessage, 0, len(raw)+len(data)+1)
+		appended = append(appended, raw[:len(raw)-1]...)
+
+		if len(bytes.TrimSpace(raw[1:len(raw)-1])) > 0 {
+			appended = append(appended, ',')
+		}
+
+		appended = append(append(appended, data...), ']')
+
+		return store.Update(bucket, key, appended)
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(raw, &elements); err != nil {
+		return err
+	}
+
+	elements = append(elements, data)
+
+	if len(elements) > maxLen {
+		elements = elements[len(elements)-maxLen:]
+	}
+
+	return store.Update(bucket, key, elements)
+}
+
+// ReadArraySlice unmarshals up to limit elements of the JSON array stored under the provided key, starting at offset,
+// into model. A non-positive limit reads every element after offset.
+func ReadArraySlice(store Backend, bucket string, key string, offset int, limit int, model interface{}) error {
+	var raw json.RawMessage
+	if err := store.Read(bucket, key, &raw); err != nil {
+		return err
+	}
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) < 2 || raw[0] != '[' {
+		return ErrNotArray
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal(raw, &elements); err != nil {
+		return err
+	}
+
+	if offset < 0 || offset > len(elements) {
+		offset = len(elements)
+	}
+
+	elements = elements[offset:]
+
+	if limit > 0 && limit < len(elements) {
+		elements = elements[:limit]
+	}
+
+	data, err := json.Marshal(elements)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, model)
+}
+
+func arrayLock(bucket string, key string) *sync.Mutex {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(bucket))
+	_, _ = hash.Write([]byte{0})
+	_, _ = hash.Write([]byte(key))
+
+	return &arrayLocks[hash.Sum32()%uint32(len(arrayLocks))]
+}
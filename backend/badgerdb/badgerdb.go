@@ -1,22 +1,29 @@
 package kvbaseBackendBadgerDB
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
 	"errors"
 	"github.com/Wolveix/kvbase"
+	"github.com/Wolveix/kvbase/pkg/kvdriver"
 	"github.com/dgraph-io/badger/v2"
+	"io"
+	"sort"
 	"strings"
 )
 
 type backend struct {
-	kvbase.Backend
+	Codec      kvbase.Codec
 	Connection *badger.DB
 	Memory     bool
 	Source     string
 }
 
+var _ kvbase.BackendCtx = (*backend)(nil)
+
 func init() {
 	store := backend{
+		Codec:      kvbase.JSONCodec{},
 		Connection: nil,
 		Memory:     false,
 		Source:     "data",
@@ -56,58 +63,322 @@ func (store *backend) Initialize(source string, memory bool) error {
 	return nil
 }
 
+// Close closes the connection to the backend. Closing an already closed backend is a no-op.
+func (store *backend) Close() error {
+	db := store.Connection
+	if db == nil {
+		return nil
+	}
+
+	store.Connection = nil
+
+	return db.Close()
+}
+
+// Configure applies the options provided to kvbase.New
+func (store *backend) Configure(options kvbase.Options) error {
+	store.Codec = options.Codec
+
+	return nil
+}
+
+// Backup writes every record of every bucket to w, reading them all from a single transaction
+func (store *backend) Backup(w io.Writer) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	writer, err := kvbase.NewBackupWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+
+			bucket, key, ok := kvdriver.SplitKey(string(item.Key()))
+			if !ok {
+				continue
+			}
+
+			if err := item.Value(func(data []byte) error {
+				return writer.Write(bucket, key, data)
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// Buckets returns the name of every bucket in sorted order. Rather than walking every record, it seeks past each
+// bucket's records once its name is known.
+func (store *backend) Buckets() ([]string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	buckets := []string{}
+
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); {
+			bucket, _, ok := kvdriver.SplitKey(string(it.Item().Key()))
+			if !ok {
+				it.Next()
+				continue
+			}
+
+			buckets = append(buckets, bucket)
+
+			// The separator is followed by every key of the bucket, so seeking to the next byte value skips them all
+			next := []byte(kvdriver.Prefix(bucket))
+			next[len(next)-1]++
+			it.Seek(next)
+		}
+
+		// Composite keys also sort on the separator, which places "bucket2" before "bucket"
+		sort.Strings(buckets)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
 // Count returns the total number of records inside of the provided bucket
 func (store *backend) Count(bucket string) (int, error) {
+	return store.CountCtx(context.Background(), bucket)
+}
+
+// CountCtx is Count, returning the context's error once ctx is done
+func (store *backend) CountCtx(ctx context.Context, bucket string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
 	counter := 0
 
 	return counter, db.View(func(txn *badger.Txn) error {
-		prefix := []byte(bucket + "_")
+		prefix := []byte(kvdriver.Prefix(bucket))
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
 		defer it.Close()
 		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
 			counter++
 		}
 		return nil
 	})
 }
 
+// CountPrefix returns the number of records inside of the provided bucket whose keys start with prefix
+func (store *backend) CountPrefix(bucket string, prefix string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	counter := 0
+
+	err := db.View(func(txn *badger.Txn) error {
+		prefix := []byte(kvdriver.Key(bucket, prefix))
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			counter++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return counter, nil
+}
+
 // Create inserts a record into the backend
 func (store *backend) Create(bucket string, key string, model interface{}) error {
+	return store.CreateCtx(context.Background(), bucket, key, model)
+}
+
+// CreateCtx is Create, returning the context's error once ctx is done
+func (store *backend) CreateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if _, err := store.view(bucket, key); err == nil {
-		return errors.New("key already exists")
+		return kvbase.ErrKeyExists
 	}
 
 	return store.write(bucket, key, model)
 }
 
+// CreateBatch inserts every record in a single transaction, writing nothing if any key already exists
+func (store *backend) CreateBatch(bucket string, records map[string]interface{}) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	keys, values, err := kvdriver.MarshalBatch(store.Codec, records)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(txn *badger.Txn) error {
+		for i, key := range keys {
+			if _, err := txn.Get([]byte(kvdriver.Key(bucket, key))); err == nil {
+				return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyExists}
+			} else if err != badger.ErrKeyNotFound {
+				return err
+			}
+
+			if err := txn.Set([]byte(kvdriver.Key(bucket, key)), values[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
 // Delete removes a record from the backend
 func (store *backend) Delete(bucket string, key string) error {
+	return store.DeleteCtx(context.Background(), bucket, key)
+}
+
+// DeleteCtx is Delete, returning the context's error once ctx is done
+func (store *backend) DeleteCtx(ctx context.Context, bucket string, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
 	if _, err := store.view(bucket, key); err != nil {
 		return err
 	}
 
 	return db.Update(func(txn *badger.Txn) error {
-		return txn.Delete([]byte(bucket + "_" + key))
+		return txn.Delete([]byte(kvdriver.Key(bucket, key)))
+	})
+}
+
+// DeleteBatch removes every record in a single transaction, deleting nothing if any key doesn't exist
+func (store *backend) DeleteBatch(bucket string, keys []string) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	return db.Update(func(txn *badger.Txn) error {
+		for _, key := range keys {
+			if _, err := txn.Get([]byte(kvdriver.Key(bucket, key))); err == badger.ErrKeyNotFound {
+				return &kvbase.BatchError{Key: key, Err: kvbase.WrapError(kvbase.ErrKeyNotFound, err)}
+			} else if err != nil {
+				return err
+			}
+		}
+
+		for _, key := range keys {
+			if err := txn.Delete([]byte(kvdriver.Key(bucket, key))); err != nil {
+				return err
+			}
+		}
+
+		return nil
 	})
 }
 
+// DeletePrefix removes every record inside of the provided bucket whose key starts with prefix, returning how many
+// records were removed
+func (store *backend) DeletePrefix(bucket string, prefix string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	counter := 0
+
+	err := db.Update(func(txn *badger.Txn) error {
+		prefix := []byte(kvdriver.Key(bucket, prefix))
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := txn.Delete(it.Item().KeyCopy(nil)); err != nil {
+				return err
+			}
+			counter++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return counter, nil
+}
+
 // Drop deletes a bucket (and all of its contents) from the backend
 func (store *backend) Drop(bucket string) error {
+	return store.DropCtx(context.Background(), bucket)
+}
+
+// DropCtx is Drop, returning the context's error once ctx is done
+func (store *backend) DropCtx(ctx context.Context, bucket string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
 	return db.Update(func(txn *badger.Txn) error {
-		prefix := []byte(bucket + "_")
+		prefix := []byte(kvdriver.Prefix(bucket))
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
 		defer it.Close()
 		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-			if err := txn.Delete(it.Item().Key()); err != nil {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if err := txn.Delete(it.Item().KeyCopy(nil)); err != nil {
 				return err
 			}
 		}
@@ -115,27 +386,225 @@ func (store *backend) Drop(bucket string) error {
 	})
 }
 
+// ForEach unmarshals every record inside of the provided bucket into model in sorted key order, calling fn with
+// each key. Iteration stops at the first error returned by fn, which is returned unless it is kvbase.ErrStopIteration.
+func (store *backend) ForEach(bucket string, model interface{}, fn func(key string) error) error {
+	fn = kvdriver.Guard(fn)
+
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	err := db.View(func(txn *badger.Txn) error {
+		prefix := []byte(kvdriver.Prefix(bucket))
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+
+			if err := item.Value(func(data []byte) error {
+				return kvdriver.Unmarshal(store.Codec, data, model)
+			}); err != nil {
+				return err
+			}
+
+			if err := fn(kvdriver.TrimPrefix(bucket, string(item.Key()))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, kvbase.ErrStopIteration) {
+		return nil
+	}
+
+	return err
+}
+
 // Get returns all records inside of the provided bucket
 func (store *backend) Get(bucket string, model interface{}) (*map[string]interface{}, error) {
+	return store.GetCtx(context.Background(), bucket, model)
+}
+
+// GetCtx is Get, returning the context's error once ctx is done
+func (store *backend) GetCtx(ctx context.Context, bucket string, model interface{}) (*map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
 	results := make(map[string]interface{})
 
 	return &results, db.View(func(txn *badger.Txn) error {
-		prefix := []byte(bucket + "_")
+		prefix := []byte(kvdriver.Prefix(bucket))
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
 		it := txn.NewIterator(opts)
 		defer it.Close()
 		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
 			item := it.Item()
-			key := strings.TrimPrefix(string(item.Key()), bucket+"_")
+			key := kvdriver.TrimPrefix(bucket, string(item.Key()))
 
 			if err := item.Value(func(value []byte) error {
-				if err := json.Unmarshal(value, &model); err != nil {
+				record := kvdriver.NewModel(model)
+				if err := store.Codec.Unmarshal(value, &record); err != nil {
+					return err
+				}
+
+				results[key] = record
+
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetPage returns up to limit records inside of the provided bucket in sorted key order, starting after the
+// startAfter key, alongside the cursor for the next page
+func (store *backend) GetPage(bucket string, model interface{}, limit int, startAfter string) (*map[string]interface{}, string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+	cursor := ""
+	prefix := []byte(kvdriver.Prefix(bucket))
+
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		it.Seek(prefix)
+		if startAfter != "" {
+			if it.Seek([]byte(kvdriver.Key(bucket, startAfter))); it.ValidForPrefix(prefix) && string(it.Item().Key()) == kvdriver.Key(bucket, startAfter) {
+				it.Next()
+			}
+		}
+
+		for ; it.ValidForPrefix(prefix); it.Next() {
+			if limit > 0 && len(results) == limit {
+				cursor = startAfter
+				break
+			}
+
+			data, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			record := kvdriver.NewModel(model)
+			if err := store.Codec.Unmarshal(data, &record); err != nil {
+				return err
+			}
+
+			startAfter = kvdriver.TrimPrefix(bucket, string(it.Item().Key()))
+			results[startAfter] = record
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPageReverse returns up to limit records inside of the provided bucket in reverse key order, starting before
+// the startBefore key, alongside the cursor for the next page
+func (store *backend) GetPageReverse(bucket string, model interface{}, limit int, startBefore string) (*map[string]interface{}, string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+	cursor := ""
+	prefix := []byte(kvdriver.Prefix(bucket))
+
+	err := db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		// Reverse iterators seek to the last key at or before the provided one, so start from just past the bucket
+		seek := []byte(kvdriver.Key(bucket, startBefore))
+		if startBefore == "" {
+			seek = []byte(kvdriver.Prefix(bucket))
+			seek[len(seek)-1]++
+		}
+
+		if it.Seek(seek); it.Valid() && bytes.Equal(it.Item().Key(), seek) {
+			it.Next()
+		}
+
+		for ; it.ValidForPrefix(prefix); it.Next() {
+			if limit > 0 && len(results) == limit {
+				cursor = startBefore
+				break
+			}
+
+			data, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			record := kvdriver.NewModel(model)
+			if err := store.Codec.Unmarshal(data, &record); err != nil {
+				return err
+			}
+
+			startBefore = kvdriver.TrimPrefix(bucket, string(it.Item().Key()))
+			results[startBefore] = record
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPrefix returns the records inside of the provided bucket whose keys start with prefix
+func (store *backend) GetPrefix(bucket string, prefix string, model interface{}) (*map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	err := db.View(func(txn *badger.Txn) error {
+		prefix := []byte(kvdriver.Key(bucket, prefix))
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			key := kvdriver.TrimPrefix(bucket, string(item.Key()))
+
+			if err := item.Value(func(data []byte) error {
+				value := kvdriver.NewModel(model)
+				if err := store.Codec.Unmarshal(data, &value); err != nil {
 					return err
 				}
 
-				results[key] = model
+				results[key] = value
 
 				return nil
 			}); err != nil {
@@ -144,20 +613,160 @@ func (store *backend) Get(bucket string, model interface{}) (*map[string]interfa
 		}
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// GetRange returns the records inside of the provided bucket whose keys fall between startKey (inclusive) and endKey
+// (exclusive) in byte order
+func (store *backend) GetRange(bucket string, startKey string, endKey string, model interface{}) (*map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	err := db.View(func(txn *badger.Txn) error {
+		prefix := []byte(kvdriver.Prefix(bucket))
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek([]byte(kvdriver.Key(bucket, startKey))); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			key := kvdriver.TrimPrefix(bucket, string(item.Key()))
+
+			if endKey != "" && key >= endKey {
+				break
+			}
+
+			if err := item.Value(func(data []byte) error {
+				value := kvdriver.NewModel(model)
+				if err := store.Codec.Unmarshal(data, &value); err != nil {
+					return err
+				}
+
+				results[key] = value
+
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// Keys returns the keys of every record inside of the provided bucket, in sorted order
+func (store *backend) Keys(bucket string) ([]string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	keys := []string{}
+
+	err := db.View(func(txn *badger.Txn) error {
+		prefix := []byte(kvdriver.Prefix(bucket))
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, kvdriver.TrimPrefix(bucket, string(it.Item().Key())))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
 }
 
 // Read returns a single struct from the provided bucket, using the provided key
 func (store *backend) Read(bucket string, key string, model interface{}) error {
+	return store.ReadCtx(context.Background(), bucket, key, model)
+}
+
+// ReadCtx is Read, returning the context's error once ctx is done
+func (store *backend) ReadCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	data, err := store.view(bucket, key)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(data, &model)
+	return store.Codec.Unmarshal(data, &model)
+}
+
+// RenameBucket moves every record of oldName into newName inside of a single transaction
+func (store *backend) RenameBucket(oldName string, newName string) error {
+	_, err := store.move(kvdriver.Prefix(oldName), newName)
+	return err
+}
+
+// Restore loads a stream written by Backup inside of a single transaction, deleting every record first when wipe is
+// set. Backups too large for one transaction fail with badger.ErrTxnTooBig, without writing anything.
+func (store *backend) Restore(r io.Reader, wipe bool) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	records, err := kvbase.ReadBackup(r)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(txn *badger.Txn) error {
+		if wipe {
+			opts := badger.DefaultIteratorOptions
+			opts.PrefetchValues = false
+			it := txn.NewIterator(opts)
+			for it.Rewind(); it.Valid(); it.Next() {
+				if _, _, ok := kvdriver.SplitKey(string(it.Item().Key())); !ok {
+					continue
+				}
+
+				if err := txn.Delete(it.Item().KeyCopy(nil)); err != nil {
+					it.Close()
+					return err
+				}
+			}
+			it.Close()
+		}
+
+		for _, record := range records {
+			if err := txn.Set([]byte(kvdriver.Key(record.Bucket, record.Key)), record.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // Update modifies an existing record from the backend, inside of the provided bucket, using the provided key
 func (store *backend) Update(bucket string, key string, model interface{}) error {
+	return store.UpdateCtx(context.Background(), bucket, key, model)
+}
+
+// UpdateCtx is Update, returning the context's error once ctx is done
+func (store *backend) UpdateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if _, err := store.view(bucket, key); err != nil {
 		return err
 	}
@@ -165,13 +774,116 @@ func (store *backend) Update(bucket string, key string, model interface{}) error
 	return store.write(bucket, key, model)
 }
 
+// Upsert inserts or replaces a record, regardless of whether the key already exists
+func (store *backend) Upsert(bucket string, key string, model interface{}) error {
+	return store.UpsertCtx(context.Background(), bucket, key, model)
+}
+
+// UpsertCtx is Upsert, returning the context's error once ctx is done
+func (store *backend) UpsertCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return store.write(bucket, key, model)
+}
+
+// MigrateBucket moves the records an earlier version stored under the unescaped name of the provided bucket to the
+// prefix current versions read, returning how many were moved
+func (store *backend) MigrateBucket(bucket string) (int, error) {
+	if kvdriver.LegacyPrefix(bucket) == kvdriver.Prefix(bucket) {
+		return 0, nil
+	}
+
+	count, err := store.move(kvdriver.LegacyPrefix(bucket), bucket)
+	if errors.Is(err, kvbase.ErrBucketNotFound) {
+		return 0, nil
+	}
+
+	return count, err
+}
+
+// Tx runs fn inside of a single read-write transaction
+func (store *backend) Tx(fn func(tx kvbase.Transaction) error) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	return db.Update(func(txn *badger.Txn) error {
+		return fn(&transaction{txn, store.Codec})
+	})
+}
+
+// move moves every record whose composite key starts with prefix into newName inside of a single transaction,
+// returning how many were moved
+func (store *backend) move(prefix string, newName string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	type entry struct {
+		key  []byte
+		data []byte
+	}
+
+	var entries []entry
+
+	err := db.Update(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Seek([]byte(prefix)); it.ValidForPrefix([]byte(prefix)); it.Next() {
+			data, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+
+			entries = append(entries, entry{it.Item().KeyCopy(nil), data})
+		}
+
+		if len(entries) == 0 {
+			return kvbase.ErrBucketNotFound
+		}
+
+		target := []byte(kvdriver.Prefix(newName))
+		if it.Seek(target); it.ValidForPrefix(target) {
+			return kvbase.ErrBucketExists
+		}
+
+		for _, entry := range entries {
+			if err := txn.Set([]byte(kvdriver.Key(newName, strings.TrimPrefix(string(entry.key), prefix))), entry.data); err != nil {
+				return err
+			}
+
+			if err := txn.Delete(entry.key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return len(entries), nil
+}
+
 func (store *backend) view(bucket string, key string) ([]byte, error) {
 	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
 	var data []byte
 
 	return data, db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(bucket + "_" + key))
-		if err != nil {
+		item, err := txn.Get([]byte(kvdriver.Key(bucket, key)))
+		if err == badger.ErrKeyNotFound {
+			return kvbase.WrapError(kvbase.ErrKeyNotFound, err)
+		} else if err != nil {
 			return err
 		}
 
@@ -185,13 +897,80 @@ func (store *backend) view(bucket string, key string) ([]byte, error) {
 
 func (store *backend) write(bucket string, key string, model interface{}) error {
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	data, err := json.Marshal(&model)
+	data, err := kvdriver.Marshal(store.Codec, model)
 	if err != nil {
 		return err
 	}
 
 	return db.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(bucket+"_"+key), data)
+		return txn.Set([]byte(kvdriver.Key(bucket, key)), data)
 	})
 }
+
+type transaction struct {
+	txn   *badger.Txn
+	codec kvbase.Codec
+}
+
+// Create inserts a record within the transaction
+func (t *transaction) Create(bucket string, key string, model interface{}) error {
+	if _, err := t.view(bucket, key); err == nil {
+		return kvbase.ErrKeyExists
+	} else if !errors.Is(err, kvbase.ErrKeyNotFound) {
+		return err
+	}
+
+	return t.write(bucket, key, model)
+}
+
+// Delete removes a record within the transaction
+func (t *transaction) Delete(bucket string, key string) error {
+	if _, err := t.view(bucket, key); err != nil {
+		return err
+	}
+
+	return t.txn.Delete([]byte(kvdriver.Key(bucket, key)))
+}
+
+// Read returns a single struct within the transaction
+func (t *transaction) Read(bucket string, key string, model interface{}) error {
+	data, err := t.view(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	return t.codec.Unmarshal(data, &model)
+}
+
+// Update modifies an existing record within the transaction
+func (t *transaction) Update(bucket string, key string, model interface{}) error {
+	if _, err := t.view(bucket, key); err != nil {
+		return err
+	}
+
+	return t.write(bucket, key, model)
+}
+
+func (t *transaction) view(bucket string, key string) ([]byte, error) {
+	item, err := t.txn.Get([]byte(kvdriver.Key(bucket, key)))
+	if err == badger.ErrKeyNotFound {
+		return nil, kvbase.WrapError(kvbase.ErrKeyNotFound, err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return item.ValueCopy(nil)
+}
+
+func (t *transaction) write(bucket string, key string, model interface{}) error {
+	data, err := kvdriver.Marshal(t.codec, model)
+	if err != nil {
+		return err
+	}
+
+	return t.txn.Set([]byte(kvdriver.Key(bucket, key)), data)
+}
@@ -1,27 +1,37 @@
 package kvbaseBackendBboltDB
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
 	"errors"
 	"github.com/Wolveix/kvbase"
+	"github.com/Wolveix/kvbase/pkg/kvdriver"
 	"go.etcd.io/bbolt"
+	"io"
+	"os"
 	"time"
 )
 
 type backend struct {
-	kvbase.Backend
+	kvdriver.BaseBackend
+	Codec      kvbase.Codec
 	Connection *bbolt.DB
 	Memory     bool
 	Source     string
 }
 
+var _ kvbase.BackendCtx = (*backend)(nil)
+
 func init() {
 	store := backend{
+		Codec:      kvbase.JSONCodec{},
 		Connection: nil,
 		Memory:     false,
 		Source:     "data.db",
 	}
 
+	store.BaseBackend = kvdriver.BaseBackend{Driver: &store}
+
 	if err := kvbase.Register("bboltdb", &store); err != nil {
 		panic(err)
 	}
@@ -49,9 +59,89 @@ func (store *backend) Initialize(source string, memory bool) error {
 	return nil
 }
 
+// Close closes the connection to the backend. Closing an already closed backend is a no-op.
+func (store *backend) Close() error {
+	db := store.Connection
+	if db == nil {
+		return nil
+	}
+
+	store.Connection = nil
+
+	return db.Close()
+}
+
+// Configure applies the options provided to kvbase.New
+func (store *backend) Configure(options kvbase.Options) error {
+	store.Codec = options.Codec
+
+	return nil
+}
+
+// Backup writes every record of every bucket to w, reading them all from a single transaction
+func (store *backend) Backup(w io.Writer) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	writer, err := kvbase.NewBackupWriter(w)
+	if err != nil {
+		return err
+	}
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			return b.ForEach(func(key, data []byte) error {
+				return writer.Write(string(name), string(key), data)
+			})
+		})
+	}); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// Buckets returns the name of every bucket in sorted order
+func (store *backend) Buckets() ([]string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	buckets := []string{}
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+			buckets = append(buckets, string(name))
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buckets, nil
+}
+
 // Count returns the total number of records inside of the provided bucket
 func (store *backend) Count(bucket string) (int, error) {
+	return store.CountCtx(context.Background(), bucket)
+}
+
+// CountCtx is Count, returning the context's error once ctx is done
+func (store *backend) CountCtx(ctx context.Context, bucket string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
 	counter := 0
 
 	if err := store.checkBucket(bucket); err != nil {
@@ -67,18 +157,104 @@ func (store *backend) Count(bucket string) (int, error) {
 	})
 }
 
+// CountPrefix returns the number of records inside of the provided bucket whose keys start with prefix
+func (store *backend) CountPrefix(bucket string, prefix string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	counter := 0
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for key, _ := c.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, _ = c.Next() {
+			counter++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return counter, nil
+}
+
 // Create inserts a record into the backend
 func (store *backend) Create(bucket string, key string, model interface{}) error {
+	return store.CreateCtx(context.Background(), bucket, key, model)
+}
+
+// CreateCtx is Create, returning the context's error once ctx is done
+func (store *backend) CreateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if _, err := store.view(bucket, key); err == nil {
-		return errors.New("key already exists")
+		return kvdriver.KeyExists(bucket, key)
 	}
 
 	return store.write(bucket, key, model)
 }
 
+// CreateBatch inserts every record in a single transaction, writing nothing if any key already exists
+func (store *backend) CreateBatch(bucket string, records map[string]interface{}) error {
+	if err := kvdriver.ValidateBucket(bucket); err != nil {
+		return err
+	}
+
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	keys, values, err := kvdriver.MarshalBatch(store.Codec, records)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+
+		for i, key := range keys {
+			if b.Get([]byte(key)) != nil {
+				return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyExists}
+			}
+
+			if err := b.Put([]byte(key), values[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
 // Delete removes a record from the backend
 func (store *backend) Delete(bucket string, key string) error {
+	return store.DeleteCtx(context.Background(), bucket, key)
+}
+
+// DeleteCtx is Delete, returning the context's error once ctx is done
+func (store *backend) DeleteCtx(ctx context.Context, bucket string, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
 	if _, err := store.view(bucket, key); err != nil {
 		return err
@@ -91,18 +267,146 @@ func (store *backend) Delete(bucket string, key string) error {
 	})
 }
 
+// DeleteBatch removes every record in a single transaction, deleting nothing if any key doesn't exist
+func (store *backend) DeleteBatch(bucket string, keys []string) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+
+		for _, key := range keys {
+			if b == nil || b.Get([]byte(key)) == nil {
+				return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyNotFound}
+			}
+		}
+
+		for _, key := range keys {
+			if err := b.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeletePrefix removes every record inside of the provided bucket whose key starts with prefix, returning how many
+// records were removed
+func (store *backend) DeletePrefix(bucket string, prefix string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	counter := 0
+
+	err := db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		var keys [][]byte
+
+		c := b.Cursor()
+		for key, _ := c.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, _ = c.Next() {
+			keys = append(keys, key)
+		}
+
+		for _, key := range keys {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		counter = len(keys)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return counter, nil
+}
+
 // Drop deletes a bucket (and all of its contents) from the backend
 func (store *backend) Drop(bucket string) error {
+	return store.DropCtx(context.Background(), bucket)
+}
+
+// DropCtx is Drop, returning the context's error once ctx is done
+func (store *backend) DropCtx(ctx context.Context, bucket string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
 	return db.Update(func(tx *bbolt.Tx) error {
-		return tx.DeleteBucket([]byte(bucket))
+		if err := tx.DeleteBucket([]byte(bucket)); err == bbolt.ErrBucketNotFound {
+			return kvdriver.BucketNotFound(bucket)
+		} else if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ForEach unmarshals every record inside of the provided bucket into model in sorted key order, calling fn with
+// each key. Iteration stops at the first error returned by fn, which is returned unless it is kvbase.ErrStopIteration.
+func (store *backend) ForEach(bucket string, model interface{}, fn func(key string) error) error {
+	fn = kvdriver.Guard(fn)
+
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(key, data []byte) error {
+			if err := kvdriver.Unmarshal(store.Codec, data, model); err != nil {
+				return err
+			}
+
+			return fn(string(key))
+		})
 	})
+	if errors.Is(err, kvbase.ErrStopIteration) {
+		return nil
+	}
+
+	return err
 }
 
 // Get returns all records inside of the provided bucket
 func (store *backend) Get(bucket string, model interface{}) (*map[string]interface{}, error) {
+	return store.GetCtx(context.Background(), bucket, model)
+}
+
+// GetCtx is Get, returning the context's error once ctx is done
+func (store *backend) GetCtx(ctx context.Context, bucket string, model interface{}) (*map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
 	results := make(map[string]interface{})
 
 	err := store.checkBucket(bucket)
@@ -114,29 +418,330 @@ func (store *backend) Get(bucket string, model interface{}) (*map[string]interfa
 		b := tx.Bucket([]byte(bucket))
 
 		return b.ForEach(func(key, value []byte) error {
-			if err := json.Unmarshal(value, &model); err != nil {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			record := kvdriver.NewModel(model)
+			if err := store.Codec.Unmarshal(value, &record); err != nil {
+				return err
+			}
+
+			results[string(key)] = record
+
+			return nil
+		})
+	})
+}
+
+// GetPage returns up to limit records inside of the provided bucket in sorted key order, starting after the
+// startAfter key, alongside the cursor for the next page
+func (store *backend) GetPage(bucket string, model interface{}, limit int, startAfter string) (*map[string]interface{}, string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+	cursor := ""
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+
+		key, value := c.First()
+		if startAfter != "" {
+			if key, value = c.Seek([]byte(startAfter)); key != nil && string(key) == startAfter {
+				key, value = c.Next()
+			}
+		}
+
+		for ; key != nil; key, value = c.Next() {
+			if limit > 0 && len(results) == limit {
+				cursor = startAfter
+				break
+			}
+
+			record := kvdriver.NewModel(model)
+			if err := store.Codec.Unmarshal(value, &record); err != nil {
+				return err
+			}
+
+			results[string(key)] = record
+			startAfter = string(key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPageReverse returns up to limit records inside of the provided bucket in reverse key order, starting before
+// the startBefore key, alongside the cursor for the next page
+func (store *backend) GetPageReverse(bucket string, model interface{}, limit int, startBefore string) (*map[string]interface{}, string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+	cursor := ""
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+
+		key, value := c.Last()
+		if startBefore != "" {
+			if key, value = c.Seek([]byte(startBefore)); key == nil {
+				key, value = c.Last()
+			} else {
+				key, value = c.Prev()
+			}
+		}
+
+		for ; key != nil; key, value = c.Prev() {
+			if limit > 0 && len(results) == limit {
+				cursor = startBefore
+				break
+			}
+
+			record := kvdriver.NewModel(model)
+			if err := store.Codec.Unmarshal(value, &record); err != nil {
+				return err
+			}
+
+			results[string(key)] = record
+			startBefore = string(key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPrefix returns the records inside of the provided bucket whose keys start with prefix
+func (store *backend) GetPrefix(bucket string, prefix string, model interface{}) (*map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for key, data := c.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, data = c.Next() {
+			value := kvdriver.NewModel(model)
+			if err := store.Codec.Unmarshal(data, &value); err != nil {
+				return err
+			}
+
+			results[string(key)] = value
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// GetRange returns the records inside of the provided bucket whose keys fall between startKey (inclusive) and endKey
+// (exclusive) in byte order
+func (store *backend) GetRange(bucket string, startKey string, endKey string, model interface{}) (*map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		c := b.Cursor()
+		for key, data := c.Seek([]byte(startKey)); key != nil && (endKey == "" || string(key) < endKey); key, data = c.Next() {
+			value := kvdriver.NewModel(model)
+			if err := store.Codec.Unmarshal(data, &value); err != nil {
 				return err
 			}
 
-			results[string(key)] = model
+			results[string(key)] = value
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// Keys returns the keys of every record inside of the provided bucket, in sorted order
+func (store *backend) Keys(bucket string) ([]string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	keys := []string{}
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		return b.ForEach(func(key, _ []byte) error {
+			keys = append(keys, string(key))
 
 			return nil
 		})
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
 }
 
 // Read returns a single struct from the provided bucket, using the provided key
 func (store *backend) Read(bucket string, key string, model interface{}) error {
+	return store.ReadCtx(context.Background(), bucket, key, model)
+}
+
+// ReadCtx is Read, returning the context's error once ctx is done
+func (store *backend) ReadCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	data, err := store.view(bucket, key)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(data, &model)
+	return store.Codec.Unmarshal(data, &model)
+}
+
+// RenameBucket moves every record of oldName into newName inside of a single transaction
+func (store *backend) RenameBucket(oldName string, newName string) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		source := tx.Bucket([]byte(oldName))
+		if source == nil {
+			return kvdriver.BucketNotFound(oldName)
+		}
+
+		if tx.Bucket([]byte(newName)) != nil {
+			return kvdriver.BucketExists(newName)
+		}
+
+		destination, err := tx.CreateBucket([]byte(newName))
+		if err != nil {
+			return err
+		}
+
+		// Copied, as the old bucket's pages are released when it is deleted
+		if err := source.ForEach(func(key, data []byte) error {
+			return destination.Put(append([]byte{}, key...), append([]byte{}, data...))
+		}); err != nil {
+			return err
+		}
+
+		return tx.DeleteBucket([]byte(oldName))
+	})
+}
+
+// Restore loads a stream written by Backup inside of a single transaction, dropping every bucket first when wipe is set
+func (store *backend) Restore(r io.Reader, wipe bool) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	records, err := kvbase.ReadBackup(r)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		if wipe {
+			var buckets [][]byte
+
+			if err := tx.ForEach(func(name []byte, _ *bbolt.Bucket) error {
+				buckets = append(buckets, append([]byte{}, name...))
+
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			for _, bucket := range buckets {
+				if err := tx.DeleteBucket(bucket); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, record := range records {
+			b, err := tx.CreateBucketIfNotExists([]byte(record.Bucket))
+			if err != nil {
+				return err
+			}
+
+			if err := b.Put([]byte(record.Key), record.Value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 }
 
 // Update modifies an existing record from the backend, inside of the provided bucket, using the provided key
 func (store *backend) Update(bucket string, key string, model interface{}) error {
+	return store.UpdateCtx(context.Background(), bucket, key, model)
+}
+
+// UpdateCtx is Update, returning the context's error once ctx is done
+func (store *backend) UpdateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if _, err := store.view(bucket, key); err != nil {
 		return err
 	}
@@ -144,8 +749,248 @@ func (store *backend) Update(bucket string, key string, model interface{}) error
 	return store.write(bucket, key, model)
 }
 
+// Upsert inserts or replaces a record, regardless of whether the key already exists
+func (store *backend) Upsert(bucket string, key string, model interface{}) error {
+	return store.UpsertCtx(context.Background(), bucket, key, model)
+}
+
+// UpsertCtx is Upsert, returning the context's error once ctx is done
+func (store *backend) UpsertCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return store.write(bucket, key, model)
+}
+
+// FragmentationReport returns the logical and physical space usage of the backend, computed in a single transaction
+func (store *backend) FragmentationReport(fn func(stats kvbase.BucketFragmentation) error) (*kvbase.FragmentationReport, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	dbStats := db.Stats()
+	report := kvbase.FragmentationReport{
+		PageSize:      db.Info().PageSize,
+		FreelistPages: dbStats.FreePageN,
+		PendingPages:  dbStats.PendingPageN,
+	}
+
+	info, err := os.Stat(db.Path())
+	if err != nil {
+		return nil, err
+	}
+
+	report.FileSize = info.Size()
+
+	// A compacted file still needs its two meta pages, the freelist and the root bucket page
+	usedPages := int64(4)
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			bucketStats := b.Stats()
+			stats := kvbase.BucketFragmentation{
+				Bucket:         string(name),
+				Keys:           bucketStats.KeyN,
+				AllocatedBytes: int64(bucketStats.BranchAlloc + bucketStats.LeafAlloc),
+				InuseBytes:     int64(bucketStats.BranchInuse + bucketStats.LeafInuse),
+			}
+
+			// Inline buckets live inside their parent's page and have no pages of their own
+			if stats.AllocatedBytes == 0 {
+				stats.AllocatedBytes = int64(bucketStats.InlineBucketInuse)
+				stats.InuseBytes = int64(bucketStats.InlineBucketInuse)
+			}
+
+			if stats.AllocatedBytes > 0 {
+				stats.FillPercent = float64(stats.InuseBytes) / float64(stats.AllocatedBytes) * 100
+			}
+
+			if err := b.ForEach(func(key, value []byte) error {
+				stats.LogicalBytes += int64(len(key) + len(value))
+
+				return nil
+			}); err != nil {
+				return err
+			}
+
+			report.LogicalBytes += stats.LogicalBytes
+			usedPages += (stats.InuseBytes + int64(report.PageSize) - 1) / int64(report.PageSize)
+
+			if fn == nil {
+				report.Buckets = append(report.Buckets, stats)
+
+				return nil
+			}
+
+			return fn(stats)
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	if reclaimable := report.FileSize - usedPages*int64(report.PageSize); reclaimable > 0 {
+		report.ReclaimableBytes = reclaimable
+	}
+
+	return &report, nil
+}
+
+// ReadManyInto copies the raw values of the provided keys into arena within a single transaction
+func (store *backend) ReadManyInto(bucket string, keys []string, arena []byte) ([]kvbase.ValueRef, []byte, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, arena, kvbase.ErrClosed
+	}
+
+	refs := make([]kvbase.ValueRef, len(keys))
+	arena = arena[:0]
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+
+		var buffer []byte
+
+		for i, key := range keys {
+			refs[i].Index = i
+
+			if b == nil {
+				continue
+			}
+
+			buffer = append(buffer[:0], key...)
+
+			if value := b.Get(buffer); value != nil {
+				refs[i] = kvbase.ValueRef{Index: i, Offset: len(arena), Length: len(value), Found: true}
+				arena = append(arena, value...)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, arena, err
+	}
+
+	return refs, arena, nil
+}
+
+// GetFromBuckets returns the records of every provided bucket within a single transaction
+func (store *backend) GetFromBuckets(buckets []string, model interface{}) (map[string]map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]map[string]interface{}, len(buckets))
+
+	err := db.View(func(tx *bbolt.Tx) error {
+		for _, bucket := range buckets {
+			records := make(map[string]interface{})
+			results[bucket] = records
+
+			b := tx.Bucket([]byte(bucket))
+			if b == nil {
+				continue
+			}
+
+			if err := b.ForEach(func(key, data []byte) error {
+				value := kvdriver.NewModel(model)
+				if err := store.Codec.Unmarshal(data, &value); err != nil {
+					return err
+				}
+
+				records[string(key)] = value
+
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Stats returns the file size, freelist size and page utilization of the backend
+func (store *backend) Stats() (*kvbase.StoreStats, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	dbStats := db.Stats()
+
+	info, err := os.Stat(db.Path())
+	if err != nil {
+		return nil, err
+	}
+
+	var allocated, inuse int
+
+	if err := db.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			bucketStats := b.Stats()
+
+			// Inline buckets live inside their parent's page and have no pages of their own
+			if bucketStats.BranchAlloc+bucketStats.LeafAlloc == 0 {
+				allocated += bucketStats.InlineBucketInuse
+				inuse += bucketStats.InlineBucketInuse
+			} else {
+				allocated += bucketStats.BranchAlloc + bucketStats.LeafAlloc
+				inuse += bucketStats.BranchInuse + bucketStats.LeafInuse
+			}
+
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	utilization := 0.0
+	if allocated > 0 {
+		utilization = float64(inuse) / float64(allocated) * 100
+	}
+
+	return &kvbase.StoreStats{
+		DiskBytes: info.Size(),
+		Driver: map[string]interface{}{
+			"file_size":        info.Size(),
+			"page_size":        db.Info().PageSize,
+			"freelist_pages":   dbStats.FreePageN,
+			"pending_pages":    dbStats.PendingPageN,
+			"page_utilization": utilization,
+		},
+	}, nil
+}
+
+// Tx runs fn inside of a single read-write transaction
+func (store *backend) Tx(fn func(tx kvbase.Transaction) error) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		return fn(&transaction{tx, store.Codec})
+	})
+}
+
 func (store *backend) checkBucket(bucket string) error {
+	if err := kvdriver.ValidateBucket(bucket); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
 	return db.Update(func(tx *bbolt.Tx) error {
 		if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
@@ -158,6 +1003,10 @@ func (store *backend) checkBucket(bucket string) error {
 
 func (store *backend) view(bucket string, key string) ([]byte, error) {
 	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
 	var data []byte
 
 	if err := store.checkBucket(bucket); err != nil {
@@ -170,7 +1019,7 @@ func (store *backend) view(bucket string, key string) ([]byte, error) {
 		data = b.Get([]byte(key))
 
 		if data == nil {
-			return errors.New("key does not exist")
+			return kvdriver.KeyNotFound(bucket, key)
 		}
 
 		return nil
@@ -178,20 +1027,90 @@ func (store *backend) view(bucket string, key string) ([]byte, error) {
 }
 
 func (store *backend) write(bucket string, key string, model interface{}) error {
-	db := store.Connection
-
-	data, err := json.Marshal(&model)
-	if err != nil {
+	if err := kvdriver.ValidateBucket(bucket); err != nil {
 		return err
 	}
 
-	if err = store.checkBucket(bucket); err != nil {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	data, err := kvdriver.Marshal(store.Codec, model)
+	if err != nil {
 		return err
 	}
 
 	return db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte(bucket))
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
 
 		return b.Put([]byte(key), data)
 	})
 }
+
+type transaction struct {
+	tx    *bbolt.Tx
+	codec kvbase.Codec
+}
+
+// Create inserts a record within the transaction
+func (t *transaction) Create(bucket string, key string, model interface{}) error {
+	b, err := t.tx.CreateBucketIfNotExists([]byte(bucket))
+	if err != nil {
+		return err
+	}
+
+	if b.Get([]byte(key)) != nil {
+		return kvdriver.KeyExists(bucket, key)
+	}
+
+	data, err := kvdriver.Marshal(t.codec, model)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(key), data)
+}
+
+// Delete removes a record within the transaction
+func (t *transaction) Delete(bucket string, key string) error {
+	b := t.tx.Bucket([]byte(bucket))
+	if b == nil || b.Get([]byte(key)) == nil {
+		return kvdriver.KeyNotFound(bucket, key)
+	}
+
+	return b.Delete([]byte(key))
+}
+
+// Read returns a single struct within the transaction
+func (t *transaction) Read(bucket string, key string, model interface{}) error {
+	b := t.tx.Bucket([]byte(bucket))
+	if b == nil {
+		return kvdriver.KeyNotFound(bucket, key)
+	}
+
+	data := b.Get([]byte(key))
+	if data == nil {
+		return kvdriver.KeyNotFound(bucket, key)
+	}
+
+	return t.codec.Unmarshal(data, &model)
+}
+
+// Update modifies an existing record within the transaction
+func (t *transaction) Update(bucket string, key string, model interface{}) error {
+	b := t.tx.Bucket([]byte(bucket))
+	if b == nil || b.Get([]byte(key)) == nil {
+		return kvdriver.KeyNotFound(bucket, key)
+	}
+
+	data, err := kvdriver.Marshal(t.codec, model)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(key), data)
+}
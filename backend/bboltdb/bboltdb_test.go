@@ -1,8 +1,15 @@
 package kvbaseBackendBboltDB_test
 
 import (
+	"bytes"
+	"github.com/Wolveix/kvbase"
 	_ "github.com/Wolveix/kvbase/backend/bboltdb"
 	"github.com/Wolveix/kvbase/pkg/kvbaseBackendTest"
+	"go.etcd.io/bbolt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
 )
 
@@ -10,6 +17,322 @@ func Test_Disk(t *testing.T) {
 	kvbaseBackendTest.RunTests(t, "bboltdb", "testdata", false)
 }
 
+func Test_FragmentationReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "churn.db")
+	compacted := filepath.Join(dir, "compacted.db")
+
+	if err := churn(source); err != nil {
+		t.Fatal("Error on fixture creation:", err)
+	}
+
+	if err := compact(source, compacted); err != nil {
+		t.Fatal("Error on fixture compaction:", err)
+	}
+
+	store, err := kvbase.New("bboltdb", source, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := kvbase.Fragmentation(store, nil)
+	if err != nil {
+		t.Fatal("Error on fragmentation report:", err)
+	}
+
+	sourceInfo, _ := os.Stat(source)
+	compactedInfo, _ := os.Stat(compacted)
+	actual := sourceInfo.Size() - compactedInfo.Size()
+
+	if report.FileSize != sourceInfo.Size() {
+		t.Fatal("Expected file size", sourceInfo.Size(), "got", report.FileSize)
+	}
+
+	if len(report.Buckets) != 2 {
+		t.Fatal("Expected 2 buckets, got", len(report.Buckets))
+	}
+
+	if report.LogicalBytes == 0 || report.FreelistPages == 0 {
+		t.Fatal("Expected logical bytes and free pages, got", report.LogicalBytes, report.FreelistPages)
+	}
+
+	tolerance := sourceInfo.Size() / 10
+	if diff := report.ReclaimableBytes - actual; diff > tolerance || diff < -tolerance {
+		t.Fatal("Expected reclaimable bytes close to", actual, "got", report.ReclaimableBytes)
+	}
+
+	streamed := 0
+	if _, err := kvbase.Fragmentation(store, func(stats kvbase.BucketFragmentation) error {
+		streamed++
+		return nil
+	}); err != nil {
+		t.Fatal("Error on fragmentation report:", err)
+	}
+
+	if streamed != 2 {
+		t.Fatal("Expected 2 streamed buckets, got", streamed)
+	}
+}
+
+func Test_Stats(t *testing.T) {
+	store, err := kvbase.New("bboltdb", "testdata", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll("testdata")
+
+	if err := store.Create("bucket", "key", &map[string]string{"Name": "John Smith"}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	stats, err := kvbase.Stats(store)
+	if err != nil {
+		t.Fatal("Error on stats:", err)
+	}
+
+	if stats.DiskBytes == 0 || stats.Driver["file_size"].(int64) != stats.DiskBytes {
+		t.Fatal("Expected file size to be reported, got", stats.Driver)
+	}
+
+	if utilization := stats.Driver["page_utilization"].(float64); utilization <= 0 || utilization > 100 {
+		t.Fatal("Expected page utilization between 0 and 100, got", utilization)
+	}
+}
+
+func Test_ReadManyInto(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := kvbase.New("bboltdb", filepath.Join(dir, "arena.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := store.Create("bucket", strconv.Itoa(i), &map[string]int{"i": i}); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	keys := []string{"0", "missing", "2"}
+	arena := make([]byte, 0, 8)
+
+	refs, arena, err := kvbase.ReadManyInto(store, "bucket", keys, arena)
+	if err != nil {
+		t.Fatal("Error on bulk read:", err)
+	}
+
+	if len(refs) != len(keys) || refs[1].Found || refs[1].Index != 1 {
+		t.Fatal("Expected the missing key to be reported as not found, got", refs)
+	}
+
+	if value := string(refs[2].Bytes(arena)); value != `{"i":2}` {
+		t.Fatal("Expected the stored bytes of the last key, got", value)
+	}
+
+	refs, arena, err = kvbase.ReadManyInto(store, "bucket", []string{"1"}, arena)
+	if err != nil {
+		t.Fatal("Error on bulk read:", err)
+	}
+
+	if !refs[0].Found || refs[0].Offset != 0 || string(refs[0].Bytes(arena)) != `{"i":1}` {
+		t.Fatal("Expected the arena to be reused from the start, got", refs, string(arena))
+	}
+}
+
+func Test_GetFromBuckets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := kvbase.New("bboltdb", filepath.Join(dir, "buckets.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bucket := range []string{"events-1", "events-2"} {
+		for i := 0; i < 3; i++ {
+			if err := store.Create(bucket, strconv.Itoa(i), &map[string]string{"bucket": bucket}); err != nil {
+				t.Fatal("Error on record creation:", err)
+			}
+		}
+	}
+
+	results, err := kvbase.GetFromBuckets(store, []string{"events-1", "events-missing", "events-2"}, &map[string]string{})
+	if err != nil {
+		t.Fatal("Error on multi-bucket get:", err)
+	}
+
+	if len(results) != 3 || len(results["events-1"]) != 3 || len(results["events-2"]) != 3 {
+		t.Fatal("Expected 3 records in each existing bucket, got:", results)
+	}
+
+	if records, ok := results["events-missing"]; !ok || len(records) != 0 {
+		t.Fatal("Expected an empty map for the missing bucket, got:", records, ok)
+	}
+
+	if record := *results["events-2"]["0"].(*map[string]string); record["bucket"] != "events-2" {
+		t.Fatal("Expected each record to be decoded into its own instance, got:", record)
+	}
+}
+
 func Benchmark_Disk(b *testing.B) {
 	kvbaseBackendTest.RunBenches(b, "bboltdb", "testdata", false)
 }
+
+func churn(source string) error {
+	db, err := bbolt.Open(source, 0600, nil)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	value := bytes.Repeat([]byte("x"), 512)
+
+	for _, bucket := range []string{"kept", "churned", "dropped"} {
+		if err := db.Update(func(tx *bbolt.Tx) error {
+			b, err := tx.CreateBucket([]byte(bucket))
+			if err != nil {
+				return err
+			}
+
+			for i := 0; i < 2000; i++ {
+				if err := b.Put([]byte(strconv.Itoa(i)), value); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("churned"))
+
+		for i := 0; i < 2000; i += 2 {
+			if err := b.Delete([]byte(strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+
+		return tx.DeleteBucket([]byte("dropped"))
+	})
+}
+
+func compact(source string, destination string) error {
+	src, err := bbolt.Open(source, 0600, &bbolt.Options{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := bbolt.Open(destination, 0600, nil)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	return src.View(func(srcTx *bbolt.Tx) error {
+		return dst.Update(func(dstTx *bbolt.Tx) error {
+			return srcTx.ForEach(func(name []byte, srcBucket *bbolt.Bucket) error {
+				dstBucket, err := dstTx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+
+				dstBucket.FillPercent = 1
+
+				return srcBucket.ForEach(func(key, value []byte) error {
+					return dstBucket.Put(key, value)
+				})
+			})
+		})
+	})
+}
+
+func Benchmark_ReadManyInto(b *testing.B) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := kvbase.New("bboltdb", filepath.Join(dir, "arena.db"), false)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+
+		if err := store.Create("bucket", keys[i], &map[string]int{"i": i}); err != nil {
+			b.Fatal("Error on record creation:", err)
+		}
+	}
+
+	var arena []byte
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, arena, err = kvbase.ReadManyInto(store, "bucket", keys, arena); err != nil {
+			b.Fatal("Error on bulk read:", err)
+		}
+	}
+}
+
+func Benchmark_GetFromBuckets(b *testing.B) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := kvbase.New("bboltdb", filepath.Join(dir, "buckets.db"), false)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	buckets := make([]string, 7)
+	for day := range buckets {
+		buckets[day] = "events-" + strconv.Itoa(day)
+
+		for i := 0; i < 100; i++ {
+			if err := store.Create(buckets[day], strconv.Itoa(i), &map[string]int{"i": i}); err != nil {
+				b.Fatal("Error on record creation:", err)
+			}
+		}
+	}
+
+	b.Run("GetFromBuckets", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := kvbase.GetFromBuckets(store, buckets, &map[string]int{}); err != nil {
+				b.Fatal("Error on multi-bucket get:", err)
+			}
+		}
+	})
+
+	b.Run("Get", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, bucket := range buckets {
+				if _, err := store.Get(bucket, &map[string]int{}); err != nil {
+					b.Fatal("Error on record get:", err)
+				}
+			}
+		}
+	})
+}
@@ -1,22 +1,28 @@
 package kvbaseBackendBitcask
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"github.com/Wolveix/kvbase"
+	"github.com/Wolveix/kvbase/pkg/kvdriver"
 	"github.com/prologic/bitcask"
+	"io"
+	"sort"
 	"strings"
 )
 
 type backend struct {
-	kvbase.Backend
+	Codec      kvbase.Codec
 	Connection *bitcask.Bitcask
 	Memory     bool
 	Source     string
 }
 
+var _ kvbase.BackendCtx = (*backend)(nil)
+
 func init() {
 	store := backend{
+		Codec:      kvbase.JSONCodec{},
 		Connection: nil,
 		Memory:     false,
 		Source:     "data",
@@ -49,32 +55,180 @@ func (store *backend) Initialize(source string, memory bool) error {
 	return nil
 }
 
+// Close closes the connection to the backend. Closing an already closed backend is a no-op.
+func (store *backend) Close() error {
+	db := store.Connection
+	if db == nil {
+		return nil
+	}
+
+	store.Connection = nil
+
+	return db.Close()
+}
+
+// Configure applies the options provided to kvbase.New
+func (store *backend) Configure(options kvbase.Options) error {
+	store.Codec = options.Codec
+
+	return nil
+}
+
+// Backup writes every record of every bucket to w. Bitcask has no snapshots, so records written during the backup
+// may or may not be included.
+func (store *backend) Backup(w io.Writer) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	var keys []string
+	if err := db.Scan([]byte{}, func(key []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	sort.Strings(keys)
+
+	writer, err := kvbase.NewBackupWriter(w)
+	if err != nil {
+		return err
+	}
+
+	for _, rawKey := range keys {
+		bucket, key, ok := kvdriver.SplitKey(rawKey)
+		if !ok {
+			continue
+		}
+
+		data, err := db.Get([]byte(rawKey))
+		if err == bitcask.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := writer.Write(bucket, key, data); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// Buckets returns the name of every bucket holding at least one record, in sorted order
+func (store *backend) Buckets() ([]string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	var keys []string
+	if err := db.Scan([]byte{}, func(key []byte) error {
+		keys = append(keys, string(key))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return kvdriver.Buckets(keys), nil
+}
+
 // Count returns the total number of records inside of the provided bucket
 func (store *backend) Count(bucket string) (int, error) {
+	return store.CountCtx(context.Background(), bucket)
+}
+
+// CountCtx is Count, returning the context's error once ctx is done
+func (store *backend) CountCtx(ctx context.Context, bucket string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
 	counter := 0
 
-	return counter, db.Scan([]byte(bucket+"_"), func(key []byte) error {
+	return counter, db.Scan([]byte(kvdriver.Prefix(bucket)), func(key []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		counter++
 		return nil
 	})
 }
 
+// CountPrefix returns the number of records inside of the provided bucket whose keys start with prefix
+func (store *backend) CountPrefix(bucket string, prefix string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	counter := 0
+
+	if err := db.Scan([]byte(kvdriver.Key(bucket, prefix)), func(key []byte) error {
+		counter++
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return counter, nil
+}
+
 // Create inserts a record into the backend
 func (store *backend) Create(bucket string, key string, model interface{}) error {
+	return store.CreateCtx(context.Background(), bucket, key, model)
+}
+
+// CreateCtx is Create, returning the context's error once ctx is done
+func (store *backend) CreateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	if db.Has([]byte(bucket + "_" + key)) {
-		return errors.New("key already exists")
+	if db.Has([]byte(kvdriver.Key(bucket, key))) {
+		return kvbase.ErrKeyExists
 	}
 
-	data, err := json.Marshal(&model)
+	return store.write(bucket, key, model)
+}
+
+// CreateBatch inserts every record in the backend, writing nothing if any key already exists
+func (store *backend) CreateBatch(bucket string, records map[string]interface{}) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	keys, values, err := kvdriver.MarshalBatch(store.Codec, records)
 	if err != nil {
 		return err
 	}
 
-	if err := db.Put([]byte(bucket+"_"+key), data); err != nil {
-		return err
+	// Bitcask has no atomic batches, so the whole batch is validated before anything is written
+	for _, key := range keys {
+		if db.Has([]byte(kvdriver.Key(bucket, key))) {
+			return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyExists}
+		}
+	}
+
+	for i, key := range keys {
+		if err := db.Put([]byte(kvdriver.Key(bucket, key)), values[i]); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -82,21 +236,96 @@ func (store *backend) Create(bucket string, key string, model interface{}) error
 
 // Delete removes a record from the backend
 func (store *backend) Delete(bucket string, key string) error {
+	return store.DeleteCtx(context.Background(), bucket, key)
+}
+
+// DeleteCtx is Delete, returning the context's error once ctx is done
+func (store *backend) DeleteCtx(ctx context.Context, bucket string, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	if !db.Has([]byte(bucket + "_" + key)) {
-		return errors.New("key doesn't exist")
+	if !db.Has([]byte(kvdriver.Key(bucket, key))) {
+		return kvbase.ErrKeyNotFound
 	}
 
-	return db.Delete([]byte(bucket + "_" + key))
+	return db.Delete([]byte(kvdriver.Key(bucket, key)))
+}
+
+// DeleteBatch removes every record in the backend, deleting nothing if any key doesn't exist
+func (store *backend) DeleteBatch(bucket string, keys []string) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	for _, key := range keys {
+		if !db.Has([]byte(kvdriver.Key(bucket, key))) {
+			return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyNotFound}
+		}
+	}
+
+	for _, key := range keys {
+		if err := db.Delete([]byte(kvdriver.Key(bucket, key))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeletePrefix removes every record inside of the provided bucket whose key starts with prefix, returning how many
+// records were removed
+func (store *backend) DeletePrefix(bucket string, prefix string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	var keys [][]byte
+	if err := db.Scan([]byte(kvdriver.Key(bucket, prefix)), func(key []byte) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	for _, key := range keys {
+		if err := db.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(keys), nil
 }
 
 // Drop deletes a bucket (and all of its contents) from the backend
 func (store *backend) Drop(bucket string) error {
+	return store.DropCtx(context.Background(), bucket)
+}
+
+// DropCtx is Drop, returning the context's error once ctx is done
+func (store *backend) DropCtx(ctx context.Context, bucket string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
 	var keys [][]byte
-	if err := db.Scan([]byte(bucket+"_"), func(key []byte) error {
+	if err := db.Scan([]byte(kvdriver.Prefix(bucket)), func(key []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		keys = append(keys, key)
 		return nil
 	}); err != nil {
@@ -104,6 +333,10 @@ func (store *backend) Drop(bucket string) error {
 	}
 
 	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		if err := db.Delete(key); err != nil {
 			return err
 		}
@@ -112,55 +345,424 @@ func (store *backend) Drop(bucket string) error {
 	return nil
 }
 
+// ForEach unmarshals every record inside of the provided bucket into model in sorted key order, calling fn with
+// each key. Iteration stops at the first error returned by fn, which is returned unless it is kvbase.ErrStopIteration.
+func (store *backend) ForEach(bucket string, model interface{}, fn func(key string) error) error {
+	fn = kvdriver.Guard(fn)
+
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		data, err := db.Get([]byte(kvdriver.Key(bucket, key)))
+		if err == bitcask.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := kvdriver.Unmarshal(store.Codec, data, model); err != nil {
+			return err
+		}
+
+		if err := fn(key); errors.Is(err, kvbase.ErrStopIteration) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Get returns all records inside of the provided bucket
 func (store *backend) Get(bucket string, model interface{}) (*map[string]interface{}, error) {
+	return store.GetCtx(context.Background(), bucket, model)
+}
+
+// GetCtx is Get, returning the context's error once ctx is done
+func (store *backend) GetCtx(ctx context.Context, bucket string, model interface{}) (*map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
 	results := make(map[string]interface{})
 
-	return &results, db.Scan([]byte(bucket+"_"), func(rawKey []byte) error {
+	return &results, db.Scan([]byte(kvdriver.Prefix(bucket)), func(rawKey []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		data, err := db.Get(rawKey)
 		if err != nil {
 			return err
 		}
 
-		if err := json.Unmarshal(data, &model); err != nil {
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &record); err != nil {
 			return err
 		}
 
-		key := strings.TrimPrefix(string(rawKey), bucket+"_")
-		results[key] = model
+		key := kvdriver.TrimPrefix(bucket, string(rawKey))
+		results[key] = record
 		return nil
 	})
 }
 
+// GetPage returns up to limit records inside of the provided bucket in sorted key order, starting after the
+// startAfter key, alongside the cursor for the next page
+func (store *backend) GetPage(bucket string, model interface{}, limit int, startAfter string) (*map[string]interface{}, string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return nil, "", err
+	}
+
+	page, cursor := kvdriver.Page(keys, limit, startAfter)
+	results := make(map[string]interface{}, len(page))
+
+	for _, key := range page {
+		data, err := db.Get([]byte(kvdriver.Key(bucket, key)))
+		if err == bitcask.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &record); err != nil {
+			return nil, "", err
+		}
+
+		results[key] = record
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPageReverse returns up to limit records inside of the provided bucket in reverse key order, starting before
+// the startBefore key, alongside the cursor for the next page
+func (store *backend) GetPageReverse(bucket string, model interface{}, limit int, startBefore string) (*map[string]interface{}, string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return nil, "", err
+	}
+
+	page, cursor := kvdriver.PageReverse(keys, limit, startBefore)
+	results := make(map[string]interface{}, len(page))
+
+	for _, key := range page {
+		data, err := db.Get([]byte(kvdriver.Key(bucket, key)))
+		if err == bitcask.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &record); err != nil {
+			return nil, "", err
+		}
+
+		results[key] = record
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPrefix returns the records inside of the provided bucket whose keys start with prefix
+func (store *backend) GetPrefix(bucket string, prefix string, model interface{}) (*map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	if err := db.Scan([]byte(kvdriver.Key(bucket, prefix)), func(rawKey []byte) error {
+		data, err := db.Get(rawKey)
+		if err != nil {
+			return err
+		}
+
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &value); err != nil {
+			return err
+		}
+
+		results[kvdriver.TrimPrefix(bucket, string(rawKey))] = value
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// GetRange returns the records inside of the provided bucket whose keys fall between startKey (inclusive) and endKey
+// (exclusive) in byte order
+func (store *backend) GetRange(bucket string, startKey string, endKey string, model interface{}) (*map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	if err := db.Scan([]byte(kvdriver.Prefix(bucket)), func(rawKey []byte) error {
+		key := kvdriver.TrimPrefix(bucket, string(rawKey))
+		if !kvdriver.InRange(key, startKey, endKey) {
+			return nil
+		}
+
+		data, err := db.Get(rawKey)
+		if err != nil {
+			return err
+		}
+
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &value); err != nil {
+			return err
+		}
+
+		results[key] = value
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// Keys returns the keys of every record inside of the provided bucket, in sorted order
+func (store *backend) Keys(bucket string) ([]string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	keys := []string{}
+
+	if err := db.Scan([]byte(kvdriver.Prefix(bucket)), func(key []byte) error {
+		keys = append(keys, kvdriver.TrimPrefix(bucket, string(key)))
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
 // Read returns a single struct from the provided bucket, using the provided key
 func (store *backend) Read(bucket string, key string, model interface{}) error {
+	return store.ReadCtx(context.Background(), bucket, key, model)
+}
+
+// ReadCtx is Read, returning the context's error once ctx is done
+func (store *backend) ReadCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	data, err := db.Get([]byte(bucket + "_" + key))
+	data, err := db.Get([]byte(kvdriver.Key(bucket, key)))
+	if err == bitcask.ErrKeyNotFound {
+		return kvbase.WrapError(kvbase.ErrKeyNotFound, err)
+	} else if err != nil {
+		return err
+	}
+
+	return store.Codec.Unmarshal(data, &model)
+}
+
+// RenameBucket moves every record of oldName into newName. Bitcask can't write a group of records atomically, so the
+// records are copied before the old ones are deleted.
+func (store *backend) RenameBucket(oldName string, newName string) error {
+	_, err := store.move(kvdriver.Prefix(oldName), newName)
+	return err
+}
+
+// Restore loads a stream written by Backup, deleting every record first when wipe is set. Bitcask can't write a group
+// of records atomically, so the whole stream is validated before anything is written.
+func (store *backend) Restore(r io.Reader, wipe bool) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	records, err := kvbase.ReadBackup(r)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(data, &model)
+	if wipe {
+		var keys [][]byte
+		if err := db.Scan([]byte{}, func(key []byte) error {
+			if _, _, ok := kvdriver.SplitKey(string(key)); ok {
+				keys = append(keys, key)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := db.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, record := range records {
+		if err := db.Put([]byte(kvdriver.Key(record.Bucket, record.Key)), record.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Update modifies an existing record from the backend, inside of the provided bucket, using the provided key
 func (store *backend) Update(bucket string, key string, model interface{}) error {
+	return store.UpdateCtx(context.Background(), bucket, key, model)
+}
+
+// UpdateCtx is Update, returning the context's error once ctx is done
+func (store *backend) UpdateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	if !db.Has([]byte(bucket + "_" + key)) {
-		return errors.New("key doesn't exist")
+	if !db.Has([]byte(kvdriver.Key(bucket, key))) {
+		return kvbase.ErrKeyNotFound
 	}
 
-	data, err := json.Marshal(&model)
-	if err != nil {
+	return store.write(bucket, key, model)
+}
+
+// Upsert inserts or replaces a record, regardless of whether the key already exists
+func (store *backend) Upsert(bucket string, key string, model interface{}) error {
+	return store.UpsertCtx(context.Background(), bucket, key, model)
+}
+
+// UpsertCtx is Upsert, returning the context's error once ctx is done
+func (store *backend) UpsertCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	if err := db.Put([]byte(bucket+"_"+key), data); err != nil {
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	return store.write(bucket, key, model)
+}
+
+// move moves every record whose composite key starts with prefix into newName, returning how many were moved
+func (store *backend) move(prefix string, newName string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	var keys [][]byte
+	if err := db.Scan([]byte(prefix), func(key []byte) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	if len(keys) == 0 {
+		return 0, kvbase.ErrBucketNotFound
+	}
+
+	exists := false
+	if err := db.Scan([]byte(kvdriver.Prefix(newName)), func(key []byte) error {
+		exists = true
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	if exists {
+		return 0, kvbase.ErrBucketExists
+	}
+
+	for _, key := range keys {
+		data, err := db.Get(key)
+		if err != nil {
+			return 0, err
+		}
+
+		if err := db.Put([]byte(kvdriver.Key(newName, strings.TrimPrefix(string(key), prefix))), data); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, key := range keys {
+		if err := db.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(keys), nil
+}
+
+// MigrateBucket moves the records an earlier version stored under the unescaped name of the provided bucket to the
+// prefix current versions read, returning how many were moved
+func (store *backend) MigrateBucket(bucket string) (int, error) {
+	if kvdriver.LegacyPrefix(bucket) == kvdriver.Prefix(bucket) {
+		return 0, nil
+	}
+
+	count, err := store.move(kvdriver.LegacyPrefix(bucket), bucket)
+	if errors.Is(err, kvbase.ErrBucketNotFound) {
+		return 0, nil
+	}
+
+	return count, err
+}
+
+func (store *backend) write(bucket string, key string, model interface{}) error {
+	db := store.Connection
+
+	data, err := kvdriver.Marshal(store.Codec, model)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return db.Put([]byte(kvdriver.Key(bucket, key)), data)
 }
@@ -1,22 +1,29 @@
 package kvbaseBackendDiskv
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"github.com/Wolveix/kvbase"
+	"github.com/Wolveix/kvbase/pkg/kvdriver"
 	"github.com/peterbourgon/diskv"
+	"io"
+	"os"
+	"sort"
 	"strings"
 )
 
 type backend struct {
-	kvbase.Backend
+	Codec      kvbase.Codec
 	Connection *diskv.Diskv
 	Memory     bool
 	Source     string
 }
 
+var _ kvbase.BackendCtx = (*backend)(nil)
+
 func init() {
 	store := backend{
+		Codec:      kvbase.JSONCodec{},
 		Connection: nil,
 		Memory:     false,
 		Source:     "data",
@@ -50,13 +57,121 @@ func (store *backend) Initialize(source string, memory bool) error {
 	return nil
 }
 
+// Close releases the backend. Closing an already closed backend is a no-op.
+func (store *backend) Close() error {
+	store.Connection = nil
+
+	return nil
+}
+
+// Configure applies the options provided to kvbase.New
+func (store *backend) Configure(options kvbase.Options) error {
+	store.Codec = options.Codec
+
+	return nil
+}
+
+// Backup writes every record of every bucket to w. Diskv has no snapshots, so records written during the backup may
+// or may not be included.
+func (store *backend) Backup(w io.Writer) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	var keys []string
+	for key := range db.Keys(nil) {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	writer, err := kvbase.NewBackupWriter(w)
+	if err != nil {
+		return err
+	}
+
+	for _, rawKey := range keys {
+		bucket, key, ok := kvdriver.SplitKey(rawKey)
+		if !ok {
+			continue
+		}
+
+		data, err := db.Read(rawKey)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := writer.Write(bucket, key, data); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// Buckets returns the name of every bucket holding at least one record, in sorted order
+func (store *backend) Buckets() ([]string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	var keys []string
+	for key := range db.Keys(nil) {
+		keys = append(keys, key)
+	}
+
+	return kvdriver.Buckets(keys), nil
+}
+
 // Count returns the total number of records inside of the provided bucket
 func (store *backend) Count(bucket string) (int, error) {
+	return store.CountCtx(context.Background(), bucket)
+}
+
+// CountCtx is Count, returning the context's error once ctx is done
+func (store *backend) CountCtx(ctx context.Context, bucket string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
 	counter := 0
 
-	keys := db.KeysPrefix(bucket+"_", nil)
+	keys := db.KeysPrefix(kvdriver.Prefix(bucket), ctx.Done())
 	for range keys {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		counter++
+	}
+
+	// Cancelling ctx also closes the key channel, ending the loop early
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return counter, nil
+}
+
+// CountPrefix returns the number of records inside of the provided bucket whose keys start with prefix
+func (store *backend) CountPrefix(bucket string, prefix string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	counter := 0
+
+	for range db.KeysPrefix(kvdriver.Key(bucket, prefix), nil) {
 		counter++
 	}
 
@@ -65,19 +180,50 @@ func (store *backend) Count(bucket string) (int, error) {
 
 // Create inserts a record into the backend
 func (store *backend) Create(bucket string, key string, model interface{}) error {
+	return store.CreateCtx(context.Background(), bucket, key, model)
+}
+
+// CreateCtx is Create, returning the context's error once ctx is done
+func (store *backend) CreateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	if db.Has(kvdriver.Key(bucket, key)) {
+		return kvbase.ErrKeyExists
+	}
+
+	return store.write(bucket, key, model)
+}
 
-	if db.Has(bucket + "_" + key) {
-		return errors.New("key already exists")
+// CreateBatch inserts every record in the backend, writing nothing if any key already exists
+func (store *backend) CreateBatch(bucket string, records map[string]interface{}) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
 	}
 
-	data, err := json.Marshal(&model)
+	keys, values, err := kvdriver.MarshalBatch(store.Codec, records)
 	if err != nil {
 		return err
 	}
 
-	if err := db.Write(bucket+"_"+key, data); err != nil {
-		return err
+	// Diskv has no atomic batches, so the whole batch is validated before anything is written
+	for _, key := range keys {
+		if db.Has(kvdriver.Key(bucket, key)) {
+			return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyExists}
+		}
+	}
+
+	for i, key := range keys {
+		if err := db.Write(kvdriver.Key(bucket, key), values[i]); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -85,85 +231,512 @@ func (store *backend) Create(bucket string, key string, model interface{}) error
 
 // Delete removes a record from the backend
 func (store *backend) Delete(bucket string, key string) error {
+	return store.DeleteCtx(context.Background(), bucket, key)
+}
+
+// DeleteCtx is Delete, returning the context's error once ctx is done
+func (store *backend) DeleteCtx(ctx context.Context, bucket string, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	if !db.Has(bucket + "_" + key) {
-		return errors.New("key doesn't exist")
+	if !db.Has(kvdriver.Key(bucket, key)) {
+		return kvbase.ErrKeyNotFound
 	}
 
-	if err := db.Erase(bucket + "_" + key); err != nil {
+	if err := db.Erase(kvdriver.Key(bucket, key)); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// DeleteBatch removes every record in the backend, deleting nothing if any key doesn't exist
+func (store *backend) DeleteBatch(bucket string, keys []string) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	for _, key := range keys {
+		if !db.Has(kvdriver.Key(bucket, key)) {
+			return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyNotFound}
+		}
+	}
+
+	for _, key := range keys {
+		if err := db.Erase(kvdriver.Key(bucket, key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeletePrefix removes every record inside of the provided bucket whose key starts with prefix, returning how many
+// records were removed
+func (store *backend) DeletePrefix(bucket string, prefix string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	var keys []string
+	for key := range db.KeysPrefix(kvdriver.Key(bucket, prefix), nil) {
+		keys = append(keys, key)
+	}
+
+	for _, key := range keys {
+		if err := db.Erase(key); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(keys), nil
+}
+
 // Drop deletes a bucket (and all of its contents) from the backend
 func (store *backend) Drop(bucket string) error {
+	return store.DropCtx(context.Background(), bucket)
+}
+
+// DropCtx is Drop, returning the context's error once ctx is done
+func (store *backend) DropCtx(ctx context.Context, bucket string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	keys := db.KeysPrefix(bucket+"_", nil)
+	keys := db.KeysPrefix(kvdriver.Prefix(bucket), ctx.Done())
 	for key := range keys {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		if err := db.Erase(key); err != nil {
 			return err
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ForEach unmarshals every record inside of the provided bucket into model in sorted key order, calling fn with
+// each key. Iteration stops at the first error returned by fn, which is returned unless it is kvbase.ErrStopIteration.
+func (store *backend) ForEach(bucket string, model interface{}, fn func(key string) error) error {
+	fn = kvdriver.Guard(fn)
+
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		data, err := db.Read(kvdriver.Key(bucket, key))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := kvdriver.Unmarshal(store.Codec, data, model); err != nil {
+			return err
+		}
+
+		if err := fn(key); errors.Is(err, kvbase.ErrStopIteration) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 // Get returns all records inside of the provided bucket
 func (store *backend) Get(bucket string, model interface{}) (*map[string]interface{}, error) {
+	return store.GetCtx(context.Background(), bucket, model)
+}
+
+// GetCtx is Get, returning the context's error once ctx is done
+func (store *backend) GetCtx(ctx context.Context, bucket string, model interface{}) (*map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
 	results := make(map[string]interface{})
 
-	keys := db.KeysPrefix(bucket+"_", nil)
+	keys := db.KeysPrefix(kvdriver.Prefix(bucket), ctx.Done())
 	for rawKey := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		value, err := db.Read(rawKey)
 		if err != nil {
 			return nil, err
 		}
 
-		if err := json.Unmarshal(value, &model); err != nil {
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(value, &record); err != nil {
+			return nil, err
+		}
+
+		key := kvdriver.TrimPrefix(bucket, string(rawKey))
+
+		results[key] = record
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// GetPage returns up to limit records inside of the provided bucket in sorted key order, starting after the
+// startAfter key, alongside the cursor for the next page
+func (store *backend) GetPage(bucket string, model interface{}, limit int, startAfter string) (*map[string]interface{}, string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return nil, "", err
+	}
+
+	page, cursor := kvdriver.Page(keys, limit, startAfter)
+	results := make(map[string]interface{}, len(page))
+
+	for _, key := range page {
+		data, err := db.Read(kvdriver.Key(bucket, key))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &record); err != nil {
+			return nil, "", err
+		}
+
+		results[key] = record
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPageReverse returns up to limit records inside of the provided bucket in reverse key order, starting before
+// the startBefore key, alongside the cursor for the next page
+func (store *backend) GetPageReverse(bucket string, model interface{}, limit int, startBefore string) (*map[string]interface{}, string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return nil, "", err
+	}
+
+	page, cursor := kvdriver.PageReverse(keys, limit, startBefore)
+	results := make(map[string]interface{}, len(page))
+
+	for _, key := range page {
+		data, err := db.Read(kvdriver.Key(bucket, key))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &record); err != nil {
+			return nil, "", err
+		}
+
+		results[key] = record
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPrefix returns the records inside of the provided bucket whose keys start with prefix
+func (store *backend) GetPrefix(bucket string, prefix string, model interface{}) (*map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	for rawKey := range db.KeysPrefix(kvdriver.Key(bucket, prefix), nil) {
+		data, err := db.Read(rawKey)
+		if err != nil {
+			return nil, err
+		}
+
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &value); err != nil {
 			return nil, err
 		}
 
-		key := strings.TrimPrefix(string(rawKey), bucket+"_")
+		results[kvdriver.TrimPrefix(bucket, rawKey)] = value
+	}
 
-		results[key] = model
+	return &results, nil
+}
+
+// GetRange returns the records inside of the provided bucket whose keys fall between startKey (inclusive) and endKey
+// (exclusive) in byte order
+func (store *backend) GetRange(bucket string, startKey string, endKey string, model interface{}) (*map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	for rawKey := range db.KeysPrefix(kvdriver.Prefix(bucket), nil) {
+		key := kvdriver.TrimPrefix(bucket, rawKey)
+		if !kvdriver.InRange(key, startKey, endKey) {
+			continue
+		}
+
+		data, err := db.Read(rawKey)
+		if err != nil {
+			return nil, err
+		}
+
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+
+		results[key] = value
 	}
 
 	return &results, nil
 }
 
+// Keys returns the keys of every record inside of the provided bucket, in sorted order
+func (store *backend) Keys(bucket string) ([]string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	keys := []string{}
+
+	for key := range db.KeysPrefix(kvdriver.Prefix(bucket), nil) {
+		keys = append(keys, kvdriver.TrimPrefix(bucket, key))
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
 // Read returns a single struct from the provided bucket, using the provided key
 func (store *backend) Read(bucket string, key string, model interface{}) error {
+	return store.ReadCtx(context.Background(), bucket, key, model)
+}
+
+// ReadCtx is Read, returning the context's error once ctx is done
+func (store *backend) ReadCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	data, err := db.Read(bucket + "_" + key)
+	data, err := db.Read(kvdriver.Key(bucket, key))
+	if os.IsNotExist(err) {
+		return kvbase.WrapError(kvbase.ErrKeyNotFound, err)
+	} else if err != nil {
+		return err
+	}
+
+	return store.Codec.Unmarshal(data, &model)
+}
+
+// RenameBucket moves every record of oldName into newName. Diskv can't write a group of records atomically, so the
+// records are copied before the old ones are erased.
+func (store *backend) RenameBucket(oldName string, newName string) error {
+	_, err := store.move(kvdriver.Prefix(oldName), newName)
+	return err
+}
+
+// Restore loads a stream written by Backup, deleting every record first when wipe is set. Diskv can't write a group
+// of records atomically, so the whole stream is validated before anything is written.
+func (store *backend) Restore(r io.Reader, wipe bool) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	records, err := kvbase.ReadBackup(r)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(data, &model)
+	if wipe {
+		for key := range db.Keys(nil) {
+			if _, _, ok := kvdriver.SplitKey(key); !ok {
+				continue
+			}
+
+			if err := db.Erase(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, record := range records {
+		if err := db.Write(kvdriver.Key(record.Bucket, record.Key), record.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Update modifies an existing record from the backend, inside of the provided bucket, using the provided key
 func (store *backend) Update(bucket string, key string, model interface{}) error {
+	return store.UpdateCtx(context.Background(), bucket, key, model)
+}
+
+// UpdateCtx is Update, returning the context's error once ctx is done
+func (store *backend) UpdateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	if !db.Has(bucket + "_" + key) {
-		return errors.New("key doesn't exist")
+	if !db.Has(kvdriver.Key(bucket, key)) {
+		return kvbase.ErrKeyNotFound
 	}
 
-	data, err := json.Marshal(&model)
-	if err != nil {
+	return store.write(bucket, key, model)
+}
+
+// Upsert inserts or replaces a record, regardless of whether the key already exists
+func (store *backend) Upsert(bucket string, key string, model interface{}) error {
+	return store.UpsertCtx(context.Background(), bucket, key, model)
+}
+
+// UpsertCtx is Upsert, returning the context's error once ctx is done
+func (store *backend) UpsertCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
 		return err
 	}
 
-	if err := db.Write(bucket+"_"+key, data); err != nil {
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	return store.write(bucket, key, model)
+}
+
+// move moves every record whose composite key starts with prefix into newName, returning how many were moved
+func (store *backend) move(prefix string, newName string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	var keys []string
+	for key := range db.KeysPrefix(prefix, nil) {
+		keys = append(keys, key)
+	}
+
+	if len(keys) == 0 {
+		return 0, kvbase.ErrBucketNotFound
+	}
+
+	cancel := make(chan struct{})
+	_, exists := <-db.KeysPrefix(kvdriver.Prefix(newName), cancel)
+	close(cancel)
+
+	if exists {
+		return 0, kvbase.ErrBucketExists
+	}
+
+	for _, key := range keys {
+		data, err := db.Read(key)
+		if err != nil {
+			return 0, err
+		}
+
+		if err := db.Write(kvdriver.Key(newName, strings.TrimPrefix(key, prefix)), data); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, key := range keys {
+		if err := db.Erase(key); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(keys), nil
+}
+
+// MigrateBucket moves the records an earlier version stored under the unescaped name of the provided bucket to the
+// prefix current versions read, returning how many were moved
+func (store *backend) MigrateBucket(bucket string) (int, error) {
+	if kvdriver.LegacyPrefix(bucket) == kvdriver.Prefix(bucket) {
+		return 0, nil
+	}
+
+	count, err := store.move(kvdriver.LegacyPrefix(bucket), bucket)
+	if errors.Is(err, kvbase.ErrBucketNotFound) {
+		return 0, nil
+	}
+
+	return count, err
+}
+
+func (store *backend) write(bucket string, key string, model interface{}) error {
+	db := store.Connection
+
+	data, err := kvdriver.Marshal(store.Codec, model)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return db.Write(kvdriver.Key(bucket, key), data)
 }
@@ -0,0 +1,871 @@
+package kvbaseBackendFile
+
+import (
+	"context"
+	"errors"
+	"github.com/Wolveix/kvbase"
+	"github.com/Wolveix/kvbase/internal/atomicfile"
+	"github.com/Wolveix/kvbase/pkg/kvdriver"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const extension = ".json"
+
+type backend struct {
+	Codec      kvbase.Codec
+	Connection string
+	Memory     bool
+	Mux        sync.RWMutex
+	Source     string
+}
+
+var _ kvbase.BackendCtx = (*backend)(nil)
+
+func init() {
+	store := backend{
+		Codec:      kvbase.JSONCodec{},
+		Connection: "",
+		Memory:     false,
+		Source:     "data",
+	}
+
+	if err := kvbase.Register("file", &store); err != nil {
+		panic(err)
+	}
+}
+
+// Initialize initialises a new store using the file backend, storing each record as <source>/<bucket>/<key>.json
+func (store *backend) Initialize(source string, memory bool) error {
+	if memory {
+		return errors.New("kvbase: file doesn't support memory-only")
+	}
+
+	if source == "" {
+		source = "data"
+	}
+
+	if err := os.MkdirAll(source, 0755); err != nil {
+		return err
+	}
+
+	store.Connection = source
+	store.Memory = memory
+	store.Source = source
+
+	return nil
+}
+
+// Close releases the backend. Closing an already closed backend is a no-op.
+func (store *backend) Close() error {
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	store.Connection = ""
+
+	return nil
+}
+
+// Configure applies the options provided to kvbase.New
+func (store *backend) Configure(options kvbase.Options) error {
+	store.Codec = options.Codec
+
+	return nil
+}
+
+// Backup writes every record of every bucket to w, holding the read lock for the whole backup
+func (store *backend) Backup(w io.Writer) error {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	buckets, err := store.buckets()
+	if err != nil {
+		return err
+	}
+
+	writer, err := kvbase.NewBackupWriter(w)
+	if err != nil {
+		return err
+	}
+
+	for _, bucket := range buckets {
+		keys, err := store.keys(bucket)
+		if err != nil {
+			return err
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			data, err := ioutil.ReadFile(filepath.Join(store.Connection, escape(bucket), escape(key)+extension))
+			if err != nil {
+				return err
+			}
+
+			if err := writer.Write(bucket, key, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	return writer.Close()
+}
+
+// Buckets returns the name of every bucket in sorted order
+func (store *backend) Buckets() ([]string, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	buckets, err := store.buckets()
+	if buckets == nil && err == nil {
+		buckets = []string{}
+	}
+
+	return buckets, err
+}
+
+// Count returns the total number of records inside of the provided bucket
+func (store *backend) Count(bucket string) (int, error) {
+	return store.CountCtx(context.Background(), bucket)
+}
+
+// CountCtx is Count, returning the context's error once ctx is done
+func (store *backend) CountCtx(ctx context.Context, bucket string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	keys, err := store.keys(bucket)
+
+	return len(keys), err
+}
+
+// CountPrefix returns the number of records inside of the provided bucket whose keys start with prefix
+func (store *backend) CountPrefix(bucket string, prefix string) (int, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	keys, err := store.keys(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	counter := 0
+
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			counter++
+		}
+	}
+
+	return counter, nil
+}
+
+// Create inserts a record into the backend
+func (store *backend) Create(bucket string, key string, model interface{}) error {
+	return store.CreateCtx(context.Background(), bucket, key, model)
+}
+
+// CreateCtx is Create, returning the context's error once ctx is done
+func (store *backend) CreateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := store.path(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := kvdriver.Marshal(store.Codec, model)
+	if err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return kvbase.ErrKeyExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	return write(path, data)
+}
+
+// CreateBatch inserts every record in the backend, writing nothing if any key already exists
+func (store *backend) CreateBatch(bucket string, records map[string]interface{}) error {
+	keys, values, err := kvdriver.MarshalBatch(store.Codec, records)
+	if err != nil {
+		return err
+	}
+
+	paths := make([]string, len(keys))
+
+	for i, key := range keys {
+		if paths[i], err = store.path(bucket, key); err != nil {
+			return &kvbase.BatchError{Key: key, Err: err}
+		}
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	// Files can't be written atomically as a group, so the whole batch is validated before anything is written
+	for i, key := range keys {
+		if _, err := os.Stat(paths[i]); err == nil {
+			return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyExists}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	for i := range keys {
+		if err := write(paths[i], values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Delete removes a record from the backend
+func (store *backend) Delete(bucket string, key string) error {
+	return store.DeleteCtx(context.Background(), bucket, key)
+}
+
+// DeleteCtx is Delete, returning the context's error once ctx is done
+func (store *backend) DeleteCtx(ctx context.Context, bucket string, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := store.path(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if err := os.Remove(path); os.IsNotExist(err) {
+		return kvbase.WrapError(kvbase.ErrKeyNotFound, err)
+	} else if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteBatch removes every record in the backend, deleting nothing if any key doesn't exist
+func (store *backend) DeleteBatch(bucket string, keys []string) error {
+	paths := make([]string, len(keys))
+
+	for i, key := range keys {
+		path, err := store.path(bucket, key)
+		if err != nil {
+			return &kvbase.BatchError{Key: key, Err: err}
+		}
+
+		paths[i] = path
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	for i, key := range keys {
+		if _, err := os.Stat(paths[i]); os.IsNotExist(err) {
+			return &kvbase.BatchError{Key: key, Err: kvbase.WrapError(kvbase.ErrKeyNotFound, err)}
+		} else if err != nil {
+			return err
+		}
+	}
+
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeletePrefix removes every record inside of the provided bucket whose key starts with prefix, returning how many
+// records were removed
+func (store *backend) DeletePrefix(bucket string, prefix string) (int, error) {
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	keys, err := store.keys(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	counter := 0
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(store.Connection, escape(bucket), escape(key)+extension)); err != nil {
+			return counter, err
+		}
+
+		counter++
+	}
+
+	return counter, nil
+}
+
+// Drop deletes a bucket (and all of its contents) from the backend
+func (store *backend) Drop(bucket string) error {
+	return store.DropCtx(context.Background(), bucket)
+}
+
+// DropCtx is Drop, returning the context's error once ctx is done
+func (store *backend) DropCtx(ctx context.Context, bucket string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	dir, err := store.dir(bucket)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// ForEach unmarshals every record inside of the provided bucket into model in sorted key order, calling fn with
+// each key. Iteration stops at the first error returned by fn, which is returned unless it is kvbase.ErrStopIteration.
+func (store *backend) ForEach(bucket string, model interface{}, fn func(key string) error) error {
+	fn = kvdriver.Guard(fn)
+
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		store.Mux.RLock()
+		data, err := ioutil.ReadFile(filepath.Join(store.Connection, escape(bucket), escape(key)+extension))
+		store.Mux.RUnlock()
+
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := kvdriver.Unmarshal(store.Codec, data, model); err != nil {
+			return err
+		}
+
+		if err := fn(key); errors.Is(err, kvbase.ErrStopIteration) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get returns all records inside of the provided bucket
+func (store *backend) Get(bucket string, model interface{}) (*map[string]interface{}, error) {
+	return store.GetCtx(context.Background(), bucket, model)
+}
+
+// GetCtx is Get, returning the context's error once ctx is done
+func (store *backend) GetCtx(ctx context.Context, bucket string, model interface{}) (*map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	keys, err := store.keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]interface{})
+
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(store.Connection, escape(bucket), escape(key)+extension))
+		if err != nil {
+			return nil, err
+		}
+
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+
+		results[key] = value
+	}
+
+	return &results, nil
+}
+
+// GetPage returns up to limit records inside of the provided bucket in sorted key order, starting after the
+// startAfter key, alongside the cursor for the next page
+func (store *backend) GetPage(bucket string, model interface{}, limit int, startAfter string) (*map[string]interface{}, string, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	keys, err := store.keys(bucket)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sort.Strings(keys)
+
+	page, cursor := kvdriver.Page(keys, limit, startAfter)
+	results := make(map[string]interface{}, len(page))
+
+	for _, key := range page {
+		data, err := ioutil.ReadFile(filepath.Join(store.Connection, escape(bucket), escape(key)+extension))
+		if err != nil {
+			return nil, "", err
+		}
+
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &record); err != nil {
+			return nil, "", err
+		}
+
+		results[key] = record
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPageReverse returns up to limit records inside of the provided bucket in reverse key order, starting before
+// the startBefore key, alongside the cursor for the next page
+func (store *backend) GetPageReverse(bucket string, model interface{}, limit int, startBefore string) (*map[string]interface{}, string, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	keys, err := store.keys(bucket)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sort.Strings(keys)
+
+	page, cursor := kvdriver.PageReverse(keys, limit, startBefore)
+	results := make(map[string]interface{}, len(page))
+
+	for _, key := range page {
+		data, err := ioutil.ReadFile(filepath.Join(store.Connection, escape(bucket), escape(key)+extension))
+		if err != nil {
+			return nil, "", err
+		}
+
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &record); err != nil {
+			return nil, "", err
+		}
+
+		results[key] = record
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPrefix returns the records inside of the provided bucket whose keys start with prefix
+func (store *backend) GetPrefix(bucket string, prefix string, model interface{}) (*map[string]interface{}, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	keys, err := store.keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]interface{})
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(store.Connection, escape(bucket), escape(key)+extension))
+		if err != nil {
+			return nil, err
+		}
+
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+
+		results[key] = value
+	}
+
+	return &results, nil
+}
+
+// GetRange returns the records inside of the provided bucket whose keys fall between startKey (inclusive) and endKey
+// (exclusive) in byte order
+func (store *backend) GetRange(bucket string, startKey string, endKey string, model interface{}) (*map[string]interface{}, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	keys, err := store.keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]interface{})
+
+	for _, key := range keys {
+		if !kvdriver.InRange(key, startKey, endKey) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(store.Connection, escape(bucket), escape(key)+extension))
+		if err != nil {
+			return nil, err
+		}
+
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+
+		results[key] = value
+	}
+
+	return &results, nil
+}
+
+// Keys returns the keys of every record inside of the provided bucket, in sorted order
+func (store *backend) Keys(bucket string) ([]string, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	keys, err := store.keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if keys == nil {
+		keys = []string{}
+	}
+
+	// Escaped file names don't sort in the same order as the keys they encode
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Read returns a single struct from the provided bucket, using the provided key
+func (store *backend) Read(bucket string, key string, model interface{}) error {
+	return store.ReadCtx(context.Background(), bucket, key, model)
+}
+
+// ReadCtx is Read, returning the context's error once ctx is done
+func (store *backend) ReadCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := store.path(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	store.Mux.RLock()
+	data, err := ioutil.ReadFile(path)
+	store.Mux.RUnlock()
+
+	if os.IsNotExist(err) {
+		return kvbase.WrapError(kvbase.ErrKeyNotFound, err)
+	} else if err != nil {
+		return err
+	}
+
+	return store.Codec.Unmarshal(data, &model)
+}
+
+// RenameBucket moves every record of oldName into newName by renaming the bucket's directory, which is atomic
+func (store *backend) RenameBucket(oldName string, newName string) error {
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	source, err := store.dir(oldName)
+	if err != nil {
+		return err
+	}
+
+	destination, err := store.dir(newName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(source); os.IsNotExist(err) {
+		return kvbase.WrapError(kvbase.ErrBucketNotFound, err)
+	} else if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(destination); err == nil {
+		return kvbase.ErrBucketExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Rename(source, destination)
+}
+
+// Restore loads a stream written by Backup under the write lock, dropping every bucket first when wipe is set. Files
+// can't be written atomically as a group, so the whole stream is validated before anything is written.
+func (store *backend) Restore(r io.Reader, wipe bool) error {
+	records, err := kvbase.ReadBackup(r)
+	if err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	paths := make([]string, len(records))
+
+	for i, record := range records {
+		if paths[i], err = store.path(record.Bucket, record.Key); err != nil {
+			return err
+		}
+	}
+
+	if wipe {
+		buckets, err := store.buckets()
+		if err != nil {
+			return err
+		}
+
+		for _, bucket := range buckets {
+			dir, err := store.dir(bucket)
+			if err != nil {
+				return err
+			}
+
+			if err := os.RemoveAll(dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, record := range records {
+		if err := write(paths[i], record.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Update modifies an existing record from the backend, inside of the provided bucket, using the provided key
+func (store *backend) Update(bucket string, key string, model interface{}) error {
+	return store.UpdateCtx(context.Background(), bucket, key, model)
+}
+
+// UpdateCtx is Update, returning the context's error once ctx is done
+func (store *backend) UpdateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := store.path(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := kvdriver.Marshal(store.Codec, model)
+	if err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return kvbase.WrapError(kvbase.ErrKeyNotFound, err)
+	} else if err != nil {
+		return err
+	}
+
+	return write(path, data)
+}
+
+// Upsert inserts or replaces a record, regardless of whether the key already exists
+func (store *backend) Upsert(bucket string, key string, model interface{}) error {
+	return store.UpsertCtx(context.Background(), bucket, key, model)
+}
+
+// UpsertCtx is Upsert, returning the context's error once ctx is done
+func (store *backend) UpsertCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	path, err := store.path(bucket, key)
+	if err != nil {
+		return err
+	}
+
+	data, err := kvdriver.Marshal(store.Codec, model)
+	if err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	return write(path, data)
+}
+
+// buckets returns the name of every bucket directory in sorted order
+func (store *backend) buckets() ([]string, error) {
+	if store.Connection == "" {
+		return nil, kvbase.ErrClosed
+	}
+
+	entries, err := ioutil.ReadDir(store.Connection)
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []string
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		bucket, err := url.PathUnescape(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		buckets = append(buckets, bucket)
+	}
+
+	sort.Strings(buckets)
+
+	return buckets, nil
+}
+
+// keys returns the unescaped keys of every record inside of the provided bucket
+func (store *backend) keys(bucket string) ([]string, error) {
+	dir, err := store.dir(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		// Dotfiles are temporary files left behind by interrupted writes
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, extension) {
+			continue
+		}
+
+		key, err := url.PathUnescape(strings.TrimSuffix(name, extension))
+		if err != nil {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// dir returns the directory holding the provided bucket. An empty name would resolve to the root directory itself,
+// so it's refused before any caller can remove or list the root.
+func (store *backend) dir(bucket string) (string, error) {
+	if store.Connection == "" {
+		return "", kvbase.ErrClosed
+	}
+
+	if bucket == "" {
+		return "", kvbase.WrapError(kvbase.ErrInvalidBucket, errors.New("file requires a bucket name"))
+	}
+
+	return filepath.Join(store.Connection, escape(bucket)), nil
+}
+
+// path returns the file holding the provided record
+func (store *backend) path(bucket string, key string) (string, error) {
+	dir, err := store.dir(bucket)
+	if err != nil {
+		return "", err
+	}
+
+	if key == "" {
+		return "", errors.New("kvbase: file requires a key")
+	}
+
+	return filepath.Join(dir, escape(key)+extension), nil
+}
+
+// write atomically replaces the file at path, creating its bucket directory when needed
+func write(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(path, data, 0644)
+}
+
+// escape percent-encodes every byte outside of [A-Za-z0-9_-], including dots and path separators, so names can
+// never traverse out of the root directory or collide with temporary files. Letters keep their case, so on
+// case-insensitive filesystems (the defaults on macOS and Windows) names differing only in case, such as "John" and
+// "john", share a file.
+func escape(name string) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' {
+			builder.WriteByte(c)
+		} else {
+			builder.WriteByte('%')
+			builder.WriteByte("0123456789ABCDEF"[c>>4])
+			builder.WriteByte("0123456789ABCDEF"[c&15])
+		}
+	}
+
+	return builder.String()
+}
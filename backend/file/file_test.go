@@ -0,0 +1,95 @@
+package kvbaseBackendFile_test
+
+import (
+	"github.com/Wolveix/kvbase"
+	_ "github.com/Wolveix/kvbase/backend/file"
+	"github.com/Wolveix/kvbase/pkg/kvbaseBackendTest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Disk(t *testing.T) {
+	kvbaseBackendTest.RunTests(t, "file", "testdata", false)
+}
+
+func Test_KeyEscaping(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+
+	store, err := kvbase.New("file", root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{"../escape", "a/b", `a\b`, "..", ".hidden"}
+
+	for _, key := range keys {
+		if err := store.Create("../bucket", key, &map[string]string{"Key": key}); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	if entries, err := ioutil.ReadDir(dir); err != nil || len(entries) != 1 {
+		t.Fatal("Expected every record to stay inside of the root directory, got", len(entries), "entries")
+	}
+
+	results, err := store.Get("../bucket", &map[string]string{})
+	if err != nil {
+		t.Fatal("Error on record get:", err)
+	}
+
+	for _, key := range keys {
+		value, ok := (*results)[key].(*map[string]string)
+		if !ok || (*value)["Key"] != key {
+			t.Fatal("Expected", key, "to round-trip, got", (*results)[key])
+		}
+	}
+}
+
+func Test_KeyCase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := kvbase.New("file", dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"John", "john"} {
+		if err := store.Upsert("users", key, &map[string]string{"Key": key}); err != nil {
+			t.Fatal("Error on record upsert:", err)
+		}
+	}
+
+	// Names keep their case on disk, so whether "John" and "john" are distinct records depends on the filesystem
+	entries, err := ioutil.ReadDir(filepath.Join(dir, "users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := store.Keys("users")
+	if err != nil {
+		t.Fatal("Error on key listing:", err)
+	}
+
+	caseSensitive := len(entries) == 2
+	if caseSensitive && (len(keys) != 2 || keys[0] != "John" || keys[1] != "john") {
+		t.Fatal("Expected distinct records on a case-sensitive filesystem, got:", keys)
+	} else if !caseSensitive && len(keys) != 1 {
+		t.Fatal("Expected a single shared record on a case-insensitive filesystem, got:", keys)
+	}
+}
+
+func Benchmark_Disk(b *testing.B) {
+	kvbaseBackendTest.RunBenches(b, "file", "testdata", false)
+}
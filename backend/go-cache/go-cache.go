@@ -1,24 +1,33 @@
 package kvbaseBackendGoCache
 
 import (
-	"encoding/json"
+	"bytes"
+	"context"
+	"encoding/gob"
 	"errors"
 	"github.com/Wolveix/kvbase"
+	"github.com/Wolveix/kvbase/internal/atomicfile"
+	"github.com/Wolveix/kvbase/pkg/kvdriver"
 	"github.com/patrickmn/go-cache"
+	"io"
+	"sort"
 	"strings"
 	"sync"
 )
 
 type backend struct {
-	kvbase.Backend
+	Codec      kvbase.Codec
 	Connection *cache.Cache
 	Memory     bool
 	Mux        sync.RWMutex
 	Source     string
 }
 
+var _ kvbase.BackendCtx = (*backend)(nil)
+
 func init() {
 	store := backend{
+		Codec:      kvbase.JSONCodec{},
 		Connection: nil,
 		Memory:     false,
 		Source:     "data",
@@ -52,15 +61,114 @@ func (store *backend) Initialize(source string, memory bool) error {
 	return nil
 }
 
+// Close releases the backend. Closing an already closed backend is a no-op.
+func (store *backend) Close() error {
+	store.Connection = nil
+
+	return nil
+}
+
+// Configure applies the options provided to kvbase.New
+func (store *backend) Configure(options kvbase.Options) error {
+	store.Codec = options.Codec
+
+	return nil
+}
+
+// Backup writes every record of every bucket to w, reading them from a single copy of the cache's items
+func (store *backend) Backup(w io.Writer) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	writer, err := kvbase.NewBackupWriter(w)
+	if err != nil {
+		return err
+	}
+
+	data := db.Items()
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		bucket, record, ok := kvdriver.SplitKey(key)
+		if !ok {
+			continue
+		}
+
+		if err := writer.Write(bucket, record, data[key].Object.([]byte)); err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// Buckets returns the name of every bucket holding at least one record, in sorted order
+func (store *backend) Buckets() ([]string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	var keys []string
+	for key := range db.Items() {
+		keys = append(keys, key)
+	}
+
+	return kvdriver.Buckets(keys), nil
+}
+
 // Count returns the total number of records inside of the provided bucket
 func (store *backend) Count(bucket string) (int, error) {
+	return store.CountCtx(context.Background(), bucket)
+}
+
+// CountCtx is Count, returning the context's error once ctx is done
+func (store *backend) CountCtx(ctx context.Context, bucket string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
 	counter := 0
 
 	data := db.Items()
 
 	for key := range data {
-		if strings.HasPrefix(key, bucket+"_") {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+
+		if strings.HasPrefix(key, kvdriver.Prefix(bucket)) {
+			counter++
+		}
+	}
+
+	return counter, nil
+}
+
+// CountPrefix returns the number of records inside of the provided bucket whose keys start with prefix
+func (store *backend) CountPrefix(bucket string, prefix string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	counter := 0
+
+	for key := range db.Items() {
+		if strings.HasPrefix(key, kvdriver.Key(bucket, prefix)) {
 			counter++
 		}
 	}
@@ -70,15 +178,28 @@ func (store *backend) Count(bucket string) (int, error) {
 
 // Create inserts a record into the backend
 func (store *backend) Create(bucket string, key string, model interface{}) error {
+	return store.CreateCtx(context.Background(), bucket, key, model)
+}
+
+// CreateCtx is Create, returning the context's error once ctx is done
+func (store *backend) CreateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	data, err := json.Marshal(&model)
+	data, err := kvdriver.Marshal(store.Codec, model)
 	if err != nil {
 		return err
 	}
 
-	if err = db.Add(bucket+"_"+key, data, cache.NoExpiration); err != nil {
-		return err
+	// Add only fails when the key is already set
+	if err = db.Add(kvdriver.Key(bucket, key), data, cache.NoExpiration); err != nil {
+		return kvbase.WrapError(kvbase.ErrKeyExists, err)
 	}
 
 	if err := store.save(); err != nil {
@@ -88,16 +209,53 @@ func (store *backend) Create(bucket string, key string, model interface{}) error
 	return nil
 }
 
+// CreateBatch inserts every record in the backend, writing nothing if any key already exists
+func (store *backend) CreateBatch(bucket string, records map[string]interface{}) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	keys, values, err := kvdriver.MarshalBatch(store.Codec, records)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if _, found := db.Get(kvdriver.Key(bucket, key)); found {
+			return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyExists}
+		}
+	}
+
+	for i, key := range keys {
+		db.Set(kvdriver.Key(bucket, key), values[i], cache.NoExpiration)
+	}
+
+	return store.save()
+}
+
 // Delete removes a record from the backend
 func (store *backend) Delete(bucket string, key string) error {
+	return store.DeleteCtx(context.Background(), bucket, key)
+}
+
+// DeleteCtx is Delete, returning the context's error once ctx is done
+func (store *backend) DeleteCtx(ctx context.Context, bucket string, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	_, found := db.Get(bucket + "_" + key)
+	_, found := db.Get(kvdriver.Key(bucket, key))
 	if !found {
-		return errors.New("key could not be found")
+		return kvbase.ErrKeyNotFound
 	}
 
-	db.Delete(bucket + "_" + key)
+	db.Delete(kvdriver.Key(bucket, key))
 
 	if err := store.save(); err != nil {
 		return err
@@ -106,14 +264,74 @@ func (store *backend) Delete(bucket string, key string) error {
 	return nil
 }
 
+// DeleteBatch removes every record in the backend, deleting nothing if any key doesn't exist
+func (store *backend) DeleteBatch(bucket string, keys []string) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	for _, key := range keys {
+		if _, found := db.Get(kvdriver.Key(bucket, key)); !found {
+			return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyNotFound}
+		}
+	}
+
+	for _, key := range keys {
+		db.Delete(kvdriver.Key(bucket, key))
+	}
+
+	return store.save()
+}
+
+// DeletePrefix removes every record inside of the provided bucket whose key starts with prefix, returning how many
+// records were removed
+func (store *backend) DeletePrefix(bucket string, prefix string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	counter := 0
+
+	for key := range db.Items() {
+		if strings.HasPrefix(key, kvdriver.Key(bucket, prefix)) {
+			db.Delete(key)
+			counter++
+		}
+	}
+
+	if err := store.save(); err != nil {
+		return 0, err
+	}
+
+	return counter, nil
+}
+
 // Drop deletes a bucket (and all of its contents) from the backend
 func (store *backend) Drop(bucket string) error {
+	return store.DropCtx(context.Background(), bucket)
+}
+
+// DropCtx is Drop, returning the context's error once ctx is done
+func (store *backend) DropCtx(ctx context.Context, bucket string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
 	data := db.Items()
 
 	for key := range data {
-		if strings.HasPrefix(key, bucket+"_") {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(key, kvdriver.Prefix(bucket)) {
 			db.Delete(key)
 		}
 	}
@@ -125,49 +343,307 @@ func (store *backend) Drop(bucket string) error {
 	return nil
 }
 
+// ForEach unmarshals every record inside of the provided bucket into model in sorted key order, calling fn with
+// each key. Iteration stops at the first error returned by fn, which is returned unless it is kvbase.ErrStopIteration.
+func (store *backend) ForEach(bucket string, model interface{}, fn func(key string) error) error {
+	fn = kvdriver.Guard(fn)
+
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		value, found := db.Get(kvdriver.Key(bucket, key))
+		if !found {
+			continue
+		}
+
+		data := value.([]byte)
+
+		if err := kvdriver.Unmarshal(store.Codec, data, model); err != nil {
+			return err
+		}
+
+		if err := fn(key); errors.Is(err, kvbase.ErrStopIteration) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Get returns all records inside of the provided bucket
 func (store *backend) Get(bucket string, model interface{}) (*map[string]interface{}, error) {
+	return store.GetCtx(context.Background(), bucket, model)
+}
+
+// GetCtx is Get, returning the context's error once ctx is done
+func (store *backend) GetCtx(ctx context.Context, bucket string, model interface{}) (*map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
 	results := make(map[string]interface{})
 
 	data := db.Items()
 
 	for key, value := range data {
-		if strings.HasPrefix(key, bucket+"_") {
-			if err := json.Unmarshal(value.Object.([]byte), &model); err != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		if strings.HasPrefix(key, kvdriver.Prefix(bucket)) {
+			record := kvdriver.NewModel(model)
+			if err := store.Codec.Unmarshal(value.Object.([]byte), &record); err != nil {
 				return nil, err
 			}
 
-			results[strings.TrimPrefix(key, bucket+"_")] = model
+			results[kvdriver.TrimPrefix(bucket, key)] = record
 		}
 	}
 
 	return &results, nil
 }
 
+// GetPage returns up to limit records inside of the provided bucket in sorted key order, starting after the
+// startAfter key, alongside the cursor for the next page
+func (store *backend) GetPage(bucket string, model interface{}, limit int, startAfter string) (*map[string]interface{}, string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return nil, "", err
+	}
+
+	page, cursor := kvdriver.Page(keys, limit, startAfter)
+	results := make(map[string]interface{}, len(page))
+
+	for _, key := range page {
+		value, found := db.Get(kvdriver.Key(bucket, key))
+		if !found {
+			continue
+		}
+
+		data := value.([]byte)
+
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &record); err != nil {
+			return nil, "", err
+		}
+
+		results[key] = record
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPageReverse returns up to limit records inside of the provided bucket in reverse key order, starting before
+// the startBefore key, alongside the cursor for the next page
+func (store *backend) GetPageReverse(bucket string, model interface{}, limit int, startBefore string) (*map[string]interface{}, string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return nil, "", err
+	}
+
+	page, cursor := kvdriver.PageReverse(keys, limit, startBefore)
+	results := make(map[string]interface{}, len(page))
+
+	for _, key := range page {
+		value, found := db.Get(kvdriver.Key(bucket, key))
+		if !found {
+			continue
+		}
+
+		data := value.([]byte)
+
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &record); err != nil {
+			return nil, "", err
+		}
+
+		results[key] = record
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPrefix returns the records inside of the provided bucket whose keys start with prefix
+func (store *backend) GetPrefix(bucket string, prefix string, model interface{}) (*map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	for key, item := range db.Items() {
+		if strings.HasPrefix(key, kvdriver.Key(bucket, prefix)) {
+			value := kvdriver.NewModel(model)
+			if err := store.Codec.Unmarshal(item.Object.([]byte), &value); err != nil {
+				return nil, err
+			}
+
+			results[kvdriver.TrimPrefix(bucket, key)] = value
+		}
+	}
+
+	return &results, nil
+}
+
+// GetRange returns the records inside of the provided bucket whose keys fall between startKey (inclusive) and endKey
+// (exclusive) in byte order
+func (store *backend) GetRange(bucket string, startKey string, endKey string, model interface{}) (*map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	for rawKey, item := range db.Items() {
+		if !strings.HasPrefix(rawKey, kvdriver.Prefix(bucket)) {
+			continue
+		}
+
+		key := kvdriver.TrimPrefix(bucket, rawKey)
+		if !kvdriver.InRange(key, startKey, endKey) {
+			continue
+		}
+
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(item.Object.([]byte), &value); err != nil {
+			return nil, err
+		}
+
+		results[key] = value
+	}
+
+	return &results, nil
+}
+
+// Keys returns the keys of every record inside of the provided bucket, in sorted order
+func (store *backend) Keys(bucket string) ([]string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	keys := []string{}
+
+	for key := range db.Items() {
+		if strings.HasPrefix(key, kvdriver.Prefix(bucket)) {
+			keys = append(keys, kvdriver.TrimPrefix(bucket, key))
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
 // Read returns a single struct from the provided bucket, using the provided key
 func (store *backend) Read(bucket string, key string, model interface{}) error {
+	return store.ReadCtx(context.Background(), bucket, key, model)
+}
+
+// ReadCtx is Read, returning the context's error once ctx is done
+func (store *backend) ReadCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	data, found := db.Get(bucket + "_" + key)
+	data, found := db.Get(kvdriver.Key(bucket, key))
 	if !found {
-		return errors.New("key could not be found")
+		return kvbase.ErrKeyNotFound
 	}
 
-	return json.Unmarshal(data.([]byte), &model)
+	return store.Codec.Unmarshal(data.([]byte), &model)
+}
+
+// RenameBucket moves every record of oldName into newName
+func (store *backend) RenameBucket(oldName string, newName string) error {
+	_, err := store.move(kvdriver.Prefix(oldName), newName)
+	return err
+}
+
+// Restore loads a stream written by Backup, deleting every record first when wipe is set. The stream is validated
+// before the cache is touched.
+func (store *backend) Restore(r io.Reader, wipe bool) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	records, err := kvbase.ReadBackup(r)
+	if err != nil {
+		return err
+	}
+
+	if wipe {
+		for key := range db.Items() {
+			if _, _, ok := kvdriver.SplitKey(key); ok {
+				db.Delete(key)
+			}
+		}
+	}
+
+	for _, record := range records {
+		db.Set(kvdriver.Key(record.Bucket, record.Key), record.Value, cache.NoExpiration)
+	}
+
+	return store.save()
 }
 
 // Update modifies an existing record from the backend, inside of the provided bucket, using the provided key
 func (store *backend) Update(bucket string, key string, model interface{}) error {
+	return store.UpdateCtx(context.Background(), bucket, key, model)
+}
+
+// UpdateCtx is Update, returning the context's error once ctx is done
+func (store *backend) UpdateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	data, err := json.Marshal(&model)
+	data, err := kvdriver.Marshal(store.Codec, model)
 	if err != nil {
 		return err
 	}
 
-	if err := db.Replace(bucket+"_"+key, data, cache.NoExpiration); err != nil {
-		return err
+	// Replace only fails when the key isn't set
+	if err := db.Replace(kvdriver.Key(bucket, key), data, cache.NoExpiration); err != nil {
+		return kvbase.WrapError(kvbase.ErrKeyNotFound, err)
 	}
 
 	if err := store.save(); err != nil {
@@ -177,13 +653,105 @@ func (store *backend) Update(bucket string, key string, model interface{}) error
 	return nil
 }
 
+// Upsert inserts or replaces a record, regardless of whether the key already exists
+func (store *backend) Upsert(bucket string, key string, model interface{}) error {
+	return store.UpsertCtx(context.Background(), bucket, key, model)
+}
+
+// UpsertCtx is Upsert, returning the context's error once ctx is done
+func (store *backend) UpsertCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	data, err := kvdriver.Marshal(store.Codec, model)
+	if err != nil {
+		return err
+	}
+
+	db.Set(kvdriver.Key(bucket, key), data, cache.NoExpiration)
+
+	return store.save()
+}
+
+// move moves every record whose composite key starts with prefix into newName, returning how many were moved
+func (store *backend) move(prefix string, newName string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	data := db.Items()
+
+	var keys []string
+
+	exists := false
+
+	for key := range data {
+		if strings.HasPrefix(key, kvdriver.Prefix(newName)) {
+			exists = true
+		}
+
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return 0, kvbase.ErrBucketNotFound
+	}
+
+	if exists {
+		return 0, kvbase.ErrBucketExists
+	}
+
+	for _, key := range keys {
+		db.Set(kvdriver.Key(newName, strings.TrimPrefix(key, prefix)), data[key].Object, cache.NoExpiration)
+		db.Delete(key)
+	}
+
+	if err := store.save(); err != nil {
+		return 0, err
+	}
+
+	return len(keys), nil
+}
+
+// MigrateBucket moves the records an earlier version stored under the unescaped name of the provided bucket to the
+// prefix current versions read, returning how many were moved
+func (store *backend) MigrateBucket(bucket string) (int, error) {
+	if kvdriver.LegacyPrefix(bucket) == kvdriver.Prefix(bucket) {
+		return 0, nil
+	}
+
+	count, err := store.move(kvdriver.LegacyPrefix(bucket), bucket)
+	if errors.Is(err, kvbase.ErrBucketNotFound) {
+		return 0, nil
+	}
+
+	return count, err
+}
+
 func (store *backend) save() error {
 	if !store.Memory {
 		store.Mux.RLock()
-		if err := store.Connection.SaveFile(store.Source); err != nil {
+		defer store.Mux.RUnlock()
+
+		// Serialized the way go-cache's own SaveFile does, so that LoadFile can read it back, but written atomically
+		// rather than by truncating the file in place
+		var buffer bytes.Buffer
+		if err := gob.NewEncoder(&buffer).Encode(store.Connection.Items()); err != nil {
+			return err
+		}
+
+		if err := atomicfile.WriteFile(store.Source, buffer.Bytes(), 0644); err != nil {
 			return err
 		}
-		store.Mux.RUnlock()
 	}
 
 	return nil
@@ -1,8 +1,12 @@
 package kvbaseBackendGoCache_test
 
 import (
+	"github.com/Wolveix/kvbase"
 	_ "github.com/Wolveix/kvbase/backend/go-cache"
 	"github.com/Wolveix/kvbase/pkg/kvbaseBackendTest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -14,6 +18,42 @@ func Test_Memory(t *testing.T) {
 	kvbaseBackendTest.RunTests(t, "go-cache", "testdata", true)
 }
 
+func Test_Reopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "data")
+
+	store, err := kvbase.New("go-cache", source, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Create("bucket", "key", map[string]string{"Name": "John Smith"}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	store.Close()
+
+	if store, err = kvbase.New("go-cache", source, false); err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	record := map[string]string{}
+	if err := store.Read("bucket", "key", &record); err != nil || record["Name"] != "John Smith" {
+		t.Fatal("Expected the record to be loaded back from disk, got:", record, err)
+	}
+
+	// The file is replaced through a temporary file, which mustn't be left behind
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Fatal("Expected only the data file, got", len(files), "files")
+	}
+}
+
 func Benchmark_Disk(b *testing.B) {
 	kvbaseBackendTest.RunBenches(b, "go-cache", "testdata", false)
 }
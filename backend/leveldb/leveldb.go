@@ -1,28 +1,51 @@
 package kvbaseBackendLevelDB
 
 import (
-	"encoding/json"
+	"bufio"
+	"context"
 	"errors"
 	"github.com/Wolveix/kvbase"
+	"github.com/Wolveix/kvbase/pkg/kvdriver"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/util"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 )
 
+// LevelStats describes a single level of the LSM tree, as reported by the leveldb.stats property
+type LevelStats struct {
+	Level   int
+	Tables  int
+	SizeMB  float64
+	TimeSec float64
+	ReadMB  float64
+	WriteMB float64
+}
+
 type backend struct {
-	kvbase.Backend
+	kvdriver.BaseBackend
+	Codec      kvbase.Codec
 	Connection *leveldb.DB
 	Memory     bool
 	Source     string
 }
 
+var _ kvbase.BackendCtx = (*backend)(nil)
+
 func init() {
 	store := backend{
+		Codec:      kvbase.JSONCodec{},
 		Connection: nil,
 		Memory:     false,
 		Source:     "data",
 	}
 
+	store.BaseBackend = kvdriver.BaseBackend{Driver: &store}
+
 	if err := kvbase.Register("leveldb", &store); err != nil {
 		panic(err)
 	}
@@ -50,12 +73,145 @@ func (store *backend) Initialize(source string, memory bool) error {
 	return nil
 }
 
+// Close closes the connection to the backend. Closing an already closed backend is a no-op.
+func (store *backend) Close() error {
+	db := store.Connection
+	if db == nil {
+		return nil
+	}
+
+	store.Connection = nil
+
+	return db.Close()
+}
+
+// Configure applies the options provided to kvbase.New
+func (store *backend) Configure(options kvbase.Options) error {
+	store.Codec = options.Codec
+
+	return nil
+}
+
+// Backup writes every record of every bucket to w, reading them all from a single snapshot
+func (store *backend) Backup(w io.Writer) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	snapshot, err := db.GetSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+
+	writer, err := kvbase.NewBackupWriter(w)
+	if err != nil {
+		return err
+	}
+
+	iter := snapshot.NewIterator(nil, nil)
+	for iter.Next() {
+		bucket, key, ok := kvdriver.SplitKey(string(iter.Key()))
+		if !ok {
+			continue
+		}
+
+		if err := writer.Write(bucket, key, iter.Value()); err != nil {
+			iter.Release()
+			return err
+		}
+	}
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// Buckets returns the name of every bucket in sorted order. Rather than walking every record, it seeks past each
+// bucket's records once its name is known.
+func (store *backend) Buckets() ([]string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	buckets := []string{}
+
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+
+	for ok := iter.First(); ok; {
+		bucket, _, found := kvdriver.SplitKey(string(iter.Key()))
+		if !found {
+			ok = iter.Next()
+			continue
+		}
+
+		buckets = append(buckets, bucket)
+
+		ok = iter.Seek(util.BytesPrefix([]byte(kvdriver.Prefix(bucket))).Limit)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	// Composite keys also sort on the separator, which places "bucket2" before "bucket"
+	sort.Strings(buckets)
+
+	return buckets, nil
+}
+
 // Count returns the total number of records inside of the provided bucket
 func (store *backend) Count(bucket string) (int, error) {
+	return store.CountCtx(context.Background(), bucket)
+}
+
+// CountCtx is Count, returning the context's error once ctx is done
+func (store *backend) CountCtx(ctx context.Context, bucket string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
 	counter := 0
 
-	iter := db.NewIterator(util.BytesPrefix([]byte(bucket+"_")), nil)
+	iter := db.NewIterator(util.BytesPrefix([]byte(kvdriver.Prefix(bucket))), nil)
+	for iter.Next() {
+		if err := ctx.Err(); err != nil {
+			iter.Release()
+			return 0, err
+		}
+
+		counter++
+	}
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+
+	return counter, nil
+}
+
+// CountPrefix returns the number of records inside of the provided bucket whose keys start with prefix
+func (store *backend) CountPrefix(bucket string, prefix string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	counter := 0
+
+	iter := db.NewIterator(util.BytesPrefix([]byte(kvdriver.Key(bucket, prefix))), nil)
 	for iter.Next() {
 		counter++
 	}
@@ -70,72 +226,400 @@ func (store *backend) Count(bucket string) (int, error) {
 
 // Create inserts a record into the backend
 func (store *backend) Create(bucket string, key string, model interface{}) error {
+	return store.CreateCtx(context.Background(), bucket, key, model)
+}
+
+// CreateCtx is Create, returning the context's error once ctx is done
+func (store *backend) CreateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	if _, err := db.Get([]byte(kvdriver.Key(bucket, key)), nil); err == nil {
+		return kvdriver.KeyExists(bucket, key)
+	} else if err != leveldb.ErrNotFound {
+		return err
+	}
+
+	return store.write(bucket, key, model)
+}
+
+// CreateBatch inserts every record in a single transaction, writing nothing if any key already exists
+func (store *backend) CreateBatch(bucket string, records map[string]interface{}) error {
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	if _, err := db.Get([]byte(bucket+"_"+key), nil); err == nil {
-		return errors.New("key already exists")
+	keys, values, err := kvdriver.MarshalBatch(store.Codec, records)
+	if err != nil {
+		return err
 	}
 
-	data, err := json.Marshal(&model)
+	// The transaction blocks other writes, so no key can be created between the check and the write
+	tr, err := db.OpenTransaction()
 	if err != nil {
 		return err
 	}
+	defer tr.Discard()
 
-	if err := db.Put([]byte(bucket+"_"+key), data, nil); err != nil {
+	batch := new(leveldb.Batch)
+
+	for i, key := range keys {
+		if ok, err := tr.Has([]byte(kvdriver.Key(bucket, key)), nil); err != nil {
+			return err
+		} else if ok {
+			return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyExists}
+		}
+
+		batch.Put([]byte(kvdriver.Key(bucket, key)), values[i])
+	}
+
+	if err := tr.Write(batch, nil); err != nil {
 		return err
 	}
 
-	return nil
+	return tr.Commit()
 }
 
 // Delete removes a record from the backend
 func (store *backend) Delete(bucket string, key string) error {
+	return store.DeleteCtx(context.Background(), bucket, key)
+}
+
+// DeleteCtx is Delete, returning the context's error once ctx is done
+func (store *backend) DeleteCtx(ctx context.Context, bucket string, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	if _, err := db.Get([]byte(bucket+"_"+key), nil); err != nil {
+	if _, err := db.Get([]byte(kvdriver.Key(bucket, key)), nil); err == leveldb.ErrNotFound {
+		return kvdriver.KeyNotFound(bucket, key)
+	} else if err != nil {
 		return err
 	}
 
-	if err := db.Delete([]byte(bucket+"_"+key), nil); err != nil {
+	if err := db.Delete([]byte(kvdriver.Key(bucket, key)), nil); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// DeleteBatch removes every record in a single transaction, deleting nothing if any key doesn't exist
+func (store *backend) DeleteBatch(bucket string, keys []string) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	// The transaction blocks other writes, so no key can be deleted between the check and the write
+	tr, err := db.OpenTransaction()
+	if err != nil {
+		return err
+	}
+	defer tr.Discard()
+
+	batch := new(leveldb.Batch)
+
+	for _, key := range keys {
+		if ok, err := tr.Has([]byte(kvdriver.Key(bucket, key)), nil); err != nil {
+			return err
+		} else if !ok {
+			return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyNotFound}
+		}
+
+		batch.Delete([]byte(kvdriver.Key(bucket, key)))
+	}
+
+	if err := tr.Write(batch, nil); err != nil {
+		return err
+	}
+
+	return tr.Commit()
+}
+
+// DeletePrefix removes every record inside of the provided bucket whose key starts with prefix, returning how many
+// records were removed
+func (store *backend) DeletePrefix(bucket string, prefix string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	batch := new(leveldb.Batch)
+
+	iter := db.NewIterator(util.BytesPrefix([]byte(kvdriver.Key(bucket, prefix))), nil)
+	for iter.Next() {
+		batch.Delete(append([]byte(nil), iter.Key()...))
+	}
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+
+	if err := db.Write(batch, nil); err != nil {
+		return 0, err
+	}
+
+	return batch.Len(), nil
+}
+
 // Drop deletes a bucket (and all of its contents) from the backend
 func (store *backend) Drop(bucket string) error {
+	return store.DropCtx(context.Background(), bucket)
+}
+
+// DropCtx is Drop, returning the context's error once ctx is done
+func (store *backend) DropCtx(ctx context.Context, bucket string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	iter := db.NewIterator(util.BytesPrefix([]byte(kvdriver.Prefix(bucket))), nil)
+	defer iter.Release()
 
-	iter := db.NewIterator(util.BytesPrefix([]byte(bucket+"_")), nil)
 	for iter.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		if err := db.Delete(iter.Key(), nil); err != nil {
 			return err
 		}
 	}
-	iter.Release()
 
-	if err := iter.Error(); err != nil {
-		return err
+	return iter.Error()
+}
+
+// ForEach unmarshals every record inside of the provided bucket into model in sorted key order, calling fn with
+// each key. Iteration stops at the first error returned by fn, which is returned unless it is kvbase.ErrStopIteration.
+func (store *backend) ForEach(bucket string, model interface{}, fn func(key string) error) error {
+	fn = kvdriver.Guard(fn)
+
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
 	}
 
-	return nil
+	iter := db.NewIterator(util.BytesPrefix([]byte(kvdriver.Prefix(bucket))), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		if err := kvdriver.Unmarshal(store.Codec, iter.Value(), model); err != nil {
+			return err
+		}
+
+		if err := fn(kvdriver.TrimPrefix(bucket, string(iter.Key()))); errors.Is(err, kvbase.ErrStopIteration) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return iter.Error()
 }
 
 // Get returns all records inside of the provided bucket
 func (store *backend) Get(bucket string, model interface{}) (*map[string]interface{}, error) {
+	return store.GetCtx(context.Background(), bucket, model)
+}
+
+// GetCtx is Get, returning the context's error once ctx is done
+func (store *backend) GetCtx(ctx context.Context, bucket string, model interface{}) (*map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
 	results := make(map[string]interface{})
 
-	iter := db.NewIterator(util.BytesPrefix([]byte(bucket+"_")), nil)
+	iter := db.NewIterator(util.BytesPrefix([]byte(kvdriver.Prefix(bucket))), nil)
+	defer iter.Release()
+
 	for iter.Next() {
-		key := strings.TrimPrefix(string(iter.Key()), bucket+"_")
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		key := kvdriver.TrimPrefix(bucket, string(iter.Key()))
 
-		if err := json.Unmarshal(iter.Value(), &model); err != nil {
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(iter.Value(), &record); err != nil {
 			return nil, err
 		}
 
-		results[key] = model
+		results[key] = record
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// GetPage returns up to limit records inside of the provided bucket in sorted key order, starting after the
+// startAfter key, alongside the cursor for the next page
+func (store *backend) GetPage(bucket string, model interface{}, limit int, startAfter string) (*map[string]interface{}, string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+	cursor := ""
+
+	iter := db.NewIterator(util.BytesPrefix([]byte(kvdriver.Prefix(bucket))), nil)
+	defer iter.Release()
+
+	ok := iter.First()
+	if startAfter != "" {
+		if ok = iter.Seek([]byte(kvdriver.Key(bucket, startAfter))); ok && string(iter.Key()) == kvdriver.Key(bucket, startAfter) {
+			ok = iter.Next()
+		}
+	}
+
+	for ; ok; ok = iter.Next() {
+		if limit > 0 && len(results) == limit {
+			cursor = startAfter
+			break
+		}
+
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(iter.Value(), &record); err != nil {
+			return nil, "", err
+		}
+
+		startAfter = kvdriver.TrimPrefix(bucket, string(iter.Key()))
+		results[startAfter] = record
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, "", err
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPageReverse returns up to limit records inside of the provided bucket in reverse key order, starting before
+// the startBefore key, alongside the cursor for the next page
+func (store *backend) GetPageReverse(bucket string, model interface{}, limit int, startBefore string) (*map[string]interface{}, string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+	cursor := ""
+
+	iter := db.NewIterator(util.BytesPrefix([]byte(kvdriver.Prefix(bucket))), nil)
+	defer iter.Release()
+
+	ok := iter.Last()
+	if startBefore != "" {
+		if ok = iter.Seek([]byte(kvdriver.Key(bucket, startBefore))); ok {
+			ok = iter.Prev()
+		} else {
+			ok = iter.Last()
+		}
+	}
+
+	for ; ok; ok = iter.Prev() {
+		if limit > 0 && len(results) == limit {
+			cursor = startBefore
+			break
+		}
+
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(iter.Value(), &record); err != nil {
+			return nil, "", err
+		}
+
+		startBefore = kvdriver.TrimPrefix(bucket, string(iter.Key()))
+		results[startBefore] = record
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, "", err
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPrefix returns the records inside of the provided bucket whose keys start with prefix
+func (store *backend) GetPrefix(bucket string, prefix string, model interface{}) (*map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	iter := db.NewIterator(util.BytesPrefix([]byte(kvdriver.Key(bucket, prefix))), nil)
+	for iter.Next() {
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(iter.Value(), &value); err != nil {
+			iter.Release()
+			return nil, err
+		}
+
+		results[kvdriver.TrimPrefix(bucket, string(iter.Key()))] = value
+	}
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// GetRange returns the records inside of the provided bucket whose keys fall between startKey (inclusive) and endKey
+// (exclusive) in byte order
+func (store *backend) GetRange(bucket string, startKey string, endKey string, model interface{}) (*map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	bounds := util.BytesPrefix([]byte(kvdriver.Prefix(bucket)))
+	bounds.Start = []byte(kvdriver.Key(bucket, startKey))
+	if endKey != "" {
+		bounds.Limit = []byte(kvdriver.Key(bucket, endKey))
+	}
+
+	iter := db.NewIterator(bounds, nil)
+	for iter.Next() {
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(iter.Value(), &value); err != nil {
+			iter.Release()
+			return nil, err
+		}
+
+		results[kvdriver.TrimPrefix(bucket, string(iter.Key()))] = value
 	}
 	iter.Release()
 
@@ -146,34 +630,453 @@ func (store *backend) Get(bucket string, model interface{}) (*map[string]interfa
 	return &results, nil
 }
 
+// Keys returns the keys of every record inside of the provided bucket, in sorted order
+func (store *backend) Keys(bucket string) ([]string, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	keys := []string{}
+
+	iter := db.NewIterator(util.BytesPrefix([]byte(kvdriver.Prefix(bucket))), nil)
+	for iter.Next() {
+		keys = append(keys, kvdriver.TrimPrefix(bucket, string(iter.Key())))
+	}
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
 // Read returns a single struct from the provided bucket, using the provided key
 func (store *backend) Read(bucket string, key string, model interface{}) error {
+	return store.ReadCtx(context.Background(), bucket, key, model)
+}
+
+// ReadCtx is Read, returning the context's error once ctx is done
+func (store *backend) ReadCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	data, err := db.Get([]byte(kvdriver.Key(bucket, key)), nil)
+	if err == leveldb.ErrNotFound {
+		return kvdriver.KeyNotFound(bucket, key)
+	} else if err != nil {
+		return err
+	}
+
+	return store.Codec.Unmarshal(data, &model)
+}
+
+// RenameBucket moves every record of oldName into newName in a single write batch
+func (store *backend) RenameBucket(oldName string, newName string) error {
+	_, err := store.move(kvdriver.Prefix(oldName), newName)
+	return err
+}
+
+// Restore loads a stream written by Backup in a single write batch, deleting every record first when wipe is set
+func (store *backend) Restore(r io.Reader, wipe bool) error {
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	data, err := db.Get([]byte(bucket+"_"+key), nil)
+	records, err := kvbase.ReadBackup(r)
 	if err != nil {
 		return err
 	}
 
-	return json.Unmarshal(data, &model)
+	batch := new(leveldb.Batch)
+
+	if wipe {
+		iter := db.NewIterator(nil, nil)
+		for iter.Next() {
+			if _, _, ok := kvdriver.SplitKey(string(iter.Key())); ok {
+				batch.Delete(append([]byte{}, iter.Key()...))
+			}
+		}
+		iter.Release()
+
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+
+	for _, record := range records {
+		batch.Put([]byte(kvdriver.Key(record.Bucket, record.Key)), record.Value)
+	}
+
+	return db.Write(batch, nil)
 }
 
 // Update modifies an existing record from the backend, inside of the provided bucket, using the provided key
 func (store *backend) Update(bucket string, key string, model interface{}) error {
+	return store.UpdateCtx(context.Background(), bucket, key, model)
+}
+
+// UpdateCtx is Update, returning the context's error once ctx is done
+func (store *backend) UpdateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
 
-	if _, err := db.Get([]byte(bucket+"_"+key), nil); err != nil {
+	if _, err := db.Get([]byte(kvdriver.Key(bucket, key)), nil); err == leveldb.ErrNotFound {
+		return kvdriver.KeyNotFound(bucket, key)
+	} else if err != nil {
 		return err
 	}
 
-	data, err := json.Marshal(&model)
+	return store.write(bucket, key, model)
+}
+
+// Upsert inserts or replaces a record, regardless of whether the key already exists
+func (store *backend) Upsert(bucket string, key string, model interface{}) error {
+	return store.UpsertCtx(context.Background(), bucket, key, model)
+}
+
+// UpsertCtx is Upsert, returning the context's error once ctx is done
+func (store *backend) UpsertCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	return store.write(bucket, key, model)
+}
+
+// ReadManyInto copies the raw values of the provided keys into arena from a single snapshot
+func (store *backend) ReadManyInto(bucket string, keys []string, arena []byte) ([]kvbase.ValueRef, []byte, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, arena, kvbase.ErrClosed
+	}
+
+	refs := make([]kvbase.ValueRef, len(keys))
+	arena = arena[:0]
+
+	snapshot, err := db.GetSnapshot()
+	if err != nil {
+		return nil, arena, err
+	}
+	defer snapshot.Release()
+
+	prefix := kvdriver.Prefix(bucket)
+	buffer := []byte(prefix)
+
+	for i, key := range keys {
+		refs[i].Index = i
+
+		buffer = append(buffer[:len(prefix)], key...)
+
+		value, err := snapshot.Get(buffer, nil)
+		if err == leveldb.ErrNotFound {
+			continue
+		} else if err != nil {
+			return nil, arena, err
+		}
+
+		refs[i] = kvbase.ValueRef{Index: i, Offset: len(arena), Length: len(value), Found: true}
+		arena = append(arena, value...)
+	}
+
+	return refs, arena, nil
+}
+
+// GetFromBuckets returns the records of every provided bucket from a single snapshot
+func (store *backend) GetFromBuckets(buckets []string, model interface{}) (map[string]map[string]interface{}, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	snapshot, err := db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+
+	results := make(map[string]map[string]interface{}, len(buckets))
+
+	for _, bucket := range buckets {
+		records := make(map[string]interface{})
+		results[bucket] = records
+
+		iter := snapshot.NewIterator(util.BytesPrefix([]byte(kvdriver.Prefix(bucket))), nil)
+		for iter.Next() {
+			value := kvdriver.NewModel(model)
+			if err := store.Codec.Unmarshal(iter.Value(), &value); err != nil {
+				iter.Release()
+				return nil, err
+			}
+
+			records[kvdriver.TrimPrefix(bucket, string(iter.Key()))] = value
+		}
+		iter.Release()
+
+		if err := iter.Error(); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// Stats returns the disk usage of the backend's directory, broken down by file type, along with per-level LSM stats
+func (store *backend) Stats() (*kvbase.StoreStats, error) {
+	db := store.Connection
+	if db == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	stats := kvbase.StoreStats{
+		Driver: make(map[string]interface{}),
+	}
+
+	var sstBytes, walBytes, manifestBytes, otherBytes int64
+
+	if err := filepath.Walk(store.Source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() || info.Name() == "LOCK" {
+			return nil
+		}
+
+		switch {
+		case strings.HasSuffix(info.Name(), ".ldb"), strings.HasSuffix(info.Name(), ".sst"):
+			sstBytes += info.Size()
+		case strings.HasSuffix(info.Name(), ".log"):
+			walBytes += info.Size()
+		case strings.HasPrefix(info.Name(), "MANIFEST-"):
+			manifestBytes += info.Size()
+		default:
+			otherBytes += info.Size()
+		}
+
+		stats.DiskBytes += info.Size()
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	property, err := db.GetProperty("leveldb.stats")
+	if err != nil {
+		return nil, err
+	}
+
+	levels, err := parseLevelStats(property)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Driver["sst_bytes"] = sstBytes
+	stats.Driver["wal_bytes"] = walBytes
+	stats.Driver["manifest_bytes"] = manifestBytes
+	stats.Driver["other_bytes"] = otherBytes
+	stats.Driver["levels"] = levels
+
+	return &stats, nil
+}
+
+// MigrateBucket moves the records an earlier version stored under the unescaped name of the provided bucket to the
+// prefix current versions read, returning how many were moved
+func (store *backend) MigrateBucket(bucket string) (int, error) {
+	if kvdriver.LegacyPrefix(bucket) == kvdriver.Prefix(bucket) {
+		return 0, nil
+	}
+
+	count, err := store.move(kvdriver.LegacyPrefix(bucket), bucket)
+	if errors.Is(err, kvbase.ErrBucketNotFound) {
+		return 0, nil
+	}
+
+	return count, err
+}
+
+// Tx runs fn inside of a single transaction, blocking other writes until it commits or rolls back
+func (store *backend) Tx(fn func(tx kvbase.Transaction) error) error {
+	db := store.Connection
+	if db == nil {
+		return kvbase.ErrClosed
+	}
+
+	tr, err := db.OpenTransaction()
 	if err != nil {
 		return err
 	}
 
-	if err := db.Put([]byte(bucket+"_"+key), data, nil); err != nil {
+	// Discarding is a no-op once committed, and releases the write lock if fn fails or panics
+	defer tr.Discard()
+
+	if err := fn(&transaction{tr, store.Codec}); err != nil {
 		return err
 	}
 
-	return nil
+	return tr.Commit()
+}
+
+// move moves every record whose composite key starts with prefix into newName in a single write batch, returning how
+// many were moved
+func (store *backend) move(prefix string, newName string) (int, error) {
+	db := store.Connection
+	if db == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	batch := new(leveldb.Batch)
+
+	iter := db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	for iter.Next() {
+		batch.Put([]byte(kvdriver.Key(newName, strings.TrimPrefix(string(iter.Key()), prefix))), append([]byte{}, iter.Value()...))
+		batch.Delete(append([]byte{}, iter.Key()...))
+	}
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+
+	if batch.Len() == 0 {
+		return 0, kvbase.ErrBucketNotFound
+	}
+
+	iter = db.NewIterator(util.BytesPrefix([]byte(kvdriver.Prefix(newName))), nil)
+	exists := iter.Next()
+	iter.Release()
+
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+
+	if exists {
+		return 0, kvdriver.BucketExists(newName)
+	}
+
+	if err := db.Write(batch, nil); err != nil {
+		return 0, err
+	}
+
+	return batch.Len() / 2, nil
+}
+
+func (store *backend) write(bucket string, key string, model interface{}) error {
+	data, err := kvdriver.Marshal(store.Codec, model)
+	if err != nil {
+		return err
+	}
+
+	return store.Connection.Put([]byte(kvdriver.Key(bucket, key)), data, nil)
+}
+
+type transaction struct {
+	tr    *leveldb.Transaction
+	codec kvbase.Codec
+}
+
+// Create inserts a record within the transaction
+func (t *transaction) Create(bucket string, key string, model interface{}) error {
+	if ok, err := t.tr.Has([]byte(kvdriver.Key(bucket, key)), nil); err != nil {
+		return err
+	} else if ok {
+		return kvdriver.KeyExists(bucket, key)
+	}
+
+	return t.write(bucket, key, model)
+}
+
+// Delete removes a record within the transaction
+func (t *transaction) Delete(bucket string, key string) error {
+	if ok, err := t.tr.Has([]byte(kvdriver.Key(bucket, key)), nil); err != nil {
+		return err
+	} else if !ok {
+		return kvdriver.KeyNotFound(bucket, key)
+	}
+
+	return t.tr.Delete([]byte(kvdriver.Key(bucket, key)), nil)
+}
+
+// Read returns a single struct within the transaction
+func (t *transaction) Read(bucket string, key string, model interface{}) error {
+	data, err := t.tr.Get([]byte(kvdriver.Key(bucket, key)), nil)
+	if err == leveldb.ErrNotFound {
+		return kvdriver.KeyNotFound(bucket, key)
+	} else if err != nil {
+		return err
+	}
+
+	return t.codec.Unmarshal(data, &model)
+}
+
+// Update modifies an existing record within the transaction
+func (t *transaction) Update(bucket string, key string, model interface{}) error {
+	if ok, err := t.tr.Has([]byte(kvdriver.Key(bucket, key)), nil); err != nil {
+		return err
+	} else if !ok {
+		return kvdriver.KeyNotFound(bucket, key)
+	}
+
+	return t.write(bucket, key, model)
+}
+
+func (t *transaction) write(bucket string, key string, model interface{}) error {
+	data, err := kvdriver.Marshal(t.codec, model)
+	if err != nil {
+		return err
+	}
+
+	return t.tr.Put([]byte(kvdriver.Key(bucket, key)), data, nil)
+}
+
+func parseLevelStats(property string) ([]LevelStats, error) {
+	var levels []LevelStats
+
+	scanner := bufio.NewScanner(strings.NewReader(property))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) != 6 {
+			continue
+		}
+
+		level, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil {
+			// Header row
+			continue
+		}
+
+		stats := LevelStats{Level: level}
+
+		if stats.Tables, err = strconv.Atoi(strings.TrimSpace(fields[1])); err != nil {
+			return nil, errors.New("kvbase: unexpected leveldb.stats format: " + scanner.Text())
+		}
+
+		for i, value := range []*float64{&stats.SizeMB, &stats.TimeSec, &stats.ReadMB, &stats.WriteMB} {
+			if *value, err = strconv.ParseFloat(strings.TrimSpace(fields[i+2]), 64); err != nil {
+				return nil, errors.New("kvbase: unexpected leveldb.stats format: " + scanner.Text())
+			}
+		}
+
+		levels = append(levels, stats)
+	}
+
+	return levels, scanner.Err()
 }
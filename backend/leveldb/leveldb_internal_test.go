@@ -0,0 +1,31 @@
+package kvbaseBackendLevelDB
+
+import "testing"
+
+// Captured from goleveldb v1.0.0's leveldb.stats property
+const statsFixture = `Compactions
+ Level |   Tables   |    Size(MB)   |    Time(sec)  |    Read(MB)   |   Write(MB)
+-------+------------+---------------+---------------+---------------+---------------
+   0   |          2 |       0.00123 |       0.00000 |       0.00000 |       0.00000
+   1   |          5 |       8.25000 |       1.50000 |      12.00000 |      10.50000
+`
+
+func TestParseLevelStats(t *testing.T) {
+	levels, err := parseLevelStats(statsFixture)
+	if err != nil {
+		t.Fatal("Error on stats parse:", err)
+	}
+
+	if len(levels) != 2 {
+		t.Fatal("Expected 2 levels, got", len(levels))
+	}
+
+	expected := LevelStats{Level: 1, Tables: 5, SizeMB: 8.25, TimeSec: 1.5, ReadMB: 12, WriteMB: 10.5}
+	if levels[1] != expected {
+		t.Fatal("Expected", expected, "got", levels[1])
+	}
+
+	if _, err := parseLevelStats("   0   |        two |       0.00123 |       0.00000 |       0.00000 |       0.00000\n"); err == nil {
+		t.Fatal("Expected error for malformed stats row")
+	}
+}
@@ -1,8 +1,12 @@
 package kvbaseBackendLevelDB_test
 
 import (
+	"github.com/Wolveix/kvbase"
 	_ "github.com/Wolveix/kvbase/backend/leveldb"
 	"github.com/Wolveix/kvbase/pkg/kvbaseBackendTest"
+	"io/ioutil"
+	"os"
+	"strconv"
 	"testing"
 )
 
@@ -10,6 +14,120 @@ func Test_Disk(t *testing.T) {
 	kvbaseBackendTest.RunTests(t, "leveldb", "testdata", false)
 }
 
+func Test_Stats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := kvbase.New("leveldb", dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if err := store.Create("bucket", strconv.Itoa(i), &map[string]int{"i": i}); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	stats, err := kvbase.Stats(store)
+	if err != nil {
+		t.Fatal("Error on stats:", err)
+	}
+
+	if stats.DiskBytes == 0 || stats.Driver["wal_bytes"].(int64) == 0 {
+		t.Fatal("Expected disk usage to include the write-ahead log, got", stats.Driver)
+	}
+
+	if _, ok := stats.Driver["levels"]; !ok {
+		t.Fatal("Expected per-level stats, got", stats.Driver)
+	}
+}
+
+func Test_ReadManyInto(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := kvbase.New("leveldb", dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := store.Create("bucket", strconv.Itoa(i), &map[string]int{"i": i}); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	keys := []string{"0", "missing", "2"}
+	arena := make([]byte, 0, 8)
+
+	refs, arena, err := kvbase.ReadManyInto(store, "bucket", keys, arena)
+	if err != nil {
+		t.Fatal("Error on bulk read:", err)
+	}
+
+	if len(refs) != len(keys) || refs[1].Found || refs[1].Index != 1 {
+		t.Fatal("Expected the missing key to be reported as not found, got", refs)
+	}
+
+	if value := string(refs[2].Bytes(arena)); value != `{"i":2}` {
+		t.Fatal("Expected the stored bytes of the last key, got", value)
+	}
+
+	refs, arena, err = kvbase.ReadManyInto(store, "bucket", []string{"1"}, arena)
+	if err != nil {
+		t.Fatal("Error on bulk read:", err)
+	}
+
+	if !refs[0].Found || refs[0].Offset != 0 || string(refs[0].Bytes(arena)) != `{"i":1}` {
+		t.Fatal("Expected the arena to be reused from the start, got", refs, string(arena))
+	}
+}
+
+func Test_GetFromBuckets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := kvbase.New("leveldb", dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bucket := range []string{"events-1", "events-2"} {
+		for i := 0; i < 3; i++ {
+			if err := store.Create(bucket, strconv.Itoa(i), &map[string]string{"bucket": bucket}); err != nil {
+				t.Fatal("Error on record creation:", err)
+			}
+		}
+	}
+
+	results, err := kvbase.GetFromBuckets(store, []string{"events-1", "events-missing", "events-2"}, &map[string]string{})
+	if err != nil {
+		t.Fatal("Error on multi-bucket get:", err)
+	}
+
+	if len(results) != 3 || len(results["events-1"]) != 3 || len(results["events-2"]) != 3 {
+		t.Fatal("Expected 3 records in each existing bucket, got:", results)
+	}
+
+	if records, ok := results["events-missing"]; !ok || len(records) != 0 {
+		t.Fatal("Expected an empty map for the missing bucket, got:", records, ok)
+	}
+
+	if record := *results["events-2"]["0"].(*map[string]string); record["bucket"] != "events-2" {
+		t.Fatal("Expected each record to be decoded into its own instance, got:", record)
+	}
+}
+
 func Benchmark_Disk(b *testing.B) {
 	kvbaseBackendTest.RunBenches(b, "leveldb", "testdata", false)
 }
@@ -0,0 +1,788 @@
+package kvbaseBackendMemory
+
+import (
+	"context"
+	"errors"
+	"github.com/Wolveix/kvbase"
+	"github.com/Wolveix/kvbase/pkg/kvdriver"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type backend struct {
+	Codec      kvbase.Codec
+	Connection map[string]map[string][]byte
+	Memory     bool
+	Mux        sync.RWMutex
+	Source     string
+}
+
+var _ kvbase.BackendCtx = (*backend)(nil)
+
+func init() {
+	store := backend{
+		Codec:      kvbase.JSONCodec{},
+		Connection: nil,
+		Memory:     true,
+		Source:     "",
+	}
+
+	if err := kvbase.Register("memory", &store); err != nil {
+		panic(err)
+	}
+}
+
+// Initialize initialises a new store using the in-memory backend
+func (store *backend) Initialize(source string, memory bool) error {
+	if !memory {
+		return errors.New("kvbase: memory only supports memory-only")
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	store.Connection = make(map[string]map[string][]byte)
+	store.Memory = memory
+	store.Source = source
+
+	return nil
+}
+
+// Close releases the store's records. Closing an already closed backend is a no-op.
+func (store *backend) Close() error {
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	store.Connection = nil
+
+	return nil
+}
+
+// Configure applies the options provided to kvbase.New
+func (store *backend) Configure(options kvbase.Options) error {
+	store.Codec = options.Codec
+
+	return nil
+}
+
+// Backup writes every record of every bucket to w, holding the read lock for the whole backup
+func (store *backend) Backup(w io.Writer) error {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	writer, err := kvbase.NewBackupWriter(w)
+	if err != nil {
+		return err
+	}
+
+	buckets := make([]string, 0, len(store.Connection))
+	for bucket := range store.Connection {
+		buckets = append(buckets, bucket)
+	}
+
+	sort.Strings(buckets)
+
+	for _, bucket := range buckets {
+		keys := make([]string, 0, len(store.Connection[bucket]))
+		for key := range store.Connection[bucket] {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if err := writer.Write(bucket, key, store.Connection[bucket][key]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return writer.Close()
+}
+
+// Buckets returns the name of every bucket in sorted order
+func (store *backend) Buckets() ([]string, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	if store.Connection == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	buckets := make([]string, 0, len(store.Connection))
+	for bucket := range store.Connection {
+		buckets = append(buckets, bucket)
+	}
+
+	sort.Strings(buckets)
+
+	return buckets, nil
+}
+
+// Count returns the total number of records inside of the provided bucket
+func (store *backend) Count(bucket string) (int, error) {
+	return store.CountCtx(context.Background(), bucket)
+}
+
+// CountCtx is Count, returning the context's error once ctx is done
+func (store *backend) CountCtx(ctx context.Context, bucket string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	if store.Connection == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	return len(store.Connection[bucket]), nil
+}
+
+// CountPrefix returns the number of records inside of the provided bucket whose keys start with prefix
+func (store *backend) CountPrefix(bucket string, prefix string) (int, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	if store.Connection == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	counter := 0
+
+	for key := range store.Connection[bucket] {
+		if strings.HasPrefix(key, prefix) {
+			counter++
+		}
+	}
+
+	return counter, nil
+}
+
+// Create inserts a record into the backend
+func (store *backend) Create(bucket string, key string, model interface{}) error {
+	return store.CreateCtx(context.Background(), bucket, key, model)
+}
+
+// CreateCtx is Create, returning the context's error once ctx is done
+func (store *backend) CreateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := kvdriver.Marshal(store.Codec, model)
+	if err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	if _, ok := store.Connection[bucket][key]; ok {
+		return kvbase.ErrKeyExists
+	}
+
+	store.put(bucket, key, data)
+
+	return nil
+}
+
+// CreateBatch inserts every record in a single operation, writing nothing if any key already exists
+func (store *backend) CreateBatch(bucket string, records map[string]interface{}) error {
+	keys, values, err := kvdriver.MarshalBatch(store.Codec, records)
+	if err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	for _, key := range keys {
+		if _, ok := store.Connection[bucket][key]; ok {
+			return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyExists}
+		}
+	}
+
+	for i, key := range keys {
+		store.put(bucket, key, values[i])
+	}
+
+	return nil
+}
+
+// Delete removes a record from the backend
+func (store *backend) Delete(bucket string, key string) error {
+	return store.DeleteCtx(context.Background(), bucket, key)
+}
+
+// DeleteCtx is Delete, returning the context's error once ctx is done
+func (store *backend) DeleteCtx(ctx context.Context, bucket string, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	if _, ok := store.Connection[bucket][key]; !ok {
+		return kvbase.ErrKeyNotFound
+	}
+
+	delete(store.Connection[bucket], key)
+
+	return nil
+}
+
+// DeleteBatch removes every record in a single operation, deleting nothing if any key doesn't exist
+func (store *backend) DeleteBatch(bucket string, keys []string) error {
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	for _, key := range keys {
+		if _, ok := store.Connection[bucket][key]; !ok {
+			return &kvbase.BatchError{Key: key, Err: kvbase.ErrKeyNotFound}
+		}
+	}
+
+	for _, key := range keys {
+		delete(store.Connection[bucket], key)
+	}
+
+	return nil
+}
+
+// DeletePrefix removes every record inside of the provided bucket whose key starts with prefix, returning how many
+// records were removed
+func (store *backend) DeletePrefix(bucket string, prefix string) (int, error) {
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if store.Connection == nil {
+		return 0, kvbase.ErrClosed
+	}
+
+	counter := 0
+
+	for key := range store.Connection[bucket] {
+		if strings.HasPrefix(key, prefix) {
+			delete(store.Connection[bucket], key)
+			counter++
+		}
+	}
+
+	return counter, nil
+}
+
+// Drop deletes a bucket (and all of its contents) from the backend
+func (store *backend) Drop(bucket string) error {
+	return store.DropCtx(context.Background(), bucket)
+}
+
+// DropCtx is Drop, returning the context's error once ctx is done
+func (store *backend) DropCtx(ctx context.Context, bucket string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	delete(store.Connection, bucket)
+
+	return nil
+}
+
+// ForEach unmarshals every record inside of the provided bucket into model in sorted key order, calling fn with
+// each key. Iteration stops at the first error returned by fn, which is returned unless it is kvbase.ErrStopIteration.
+func (store *backend) ForEach(bucket string, model interface{}, fn func(key string) error) error {
+	fn = kvdriver.Guard(fn)
+
+	store.Mux.RLock()
+
+	if store.Connection == nil {
+		store.Mux.RUnlock()
+		return kvbase.ErrClosed
+	}
+
+	keys := make([]string, 0, len(store.Connection[bucket]))
+	records := make(map[string][]byte, len(store.Connection[bucket]))
+
+	for key, data := range store.Connection[bucket] {
+		keys = append(keys, key)
+		records[key] = data
+	}
+
+	store.Mux.RUnlock()
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := kvdriver.Unmarshal(store.Codec, records[key], model); err != nil {
+			return err
+		}
+
+		if err := fn(key); errors.Is(err, kvbase.ErrStopIteration) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get returns all records inside of the provided bucket
+func (store *backend) Get(bucket string, model interface{}) (*map[string]interface{}, error) {
+	return store.GetCtx(context.Background(), bucket, model)
+}
+
+// GetCtx is Get, returning the context's error once ctx is done
+func (store *backend) GetCtx(ctx context.Context, bucket string, model interface{}) (*map[string]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	if store.Connection == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	for key, data := range store.Connection[bucket] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+
+		results[key] = value
+	}
+
+	return &results, nil
+}
+
+// GetPage returns up to limit records inside of the provided bucket in sorted key order, starting after the
+// startAfter key, alongside the cursor for the next page
+func (store *backend) GetPage(bucket string, model interface{}, limit int, startAfter string) (*map[string]interface{}, string, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	if store.Connection == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	keys := make([]string, 0, len(store.Connection[bucket]))
+	for key := range store.Connection[bucket] {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	page, cursor := kvdriver.Page(keys, limit, startAfter)
+	results := make(map[string]interface{}, len(page))
+
+	for _, key := range page {
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(store.Connection[bucket][key], &record); err != nil {
+			return nil, "", err
+		}
+
+		results[key] = record
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPageReverse returns up to limit records inside of the provided bucket in reverse key order, starting before
+// the startBefore key, alongside the cursor for the next page
+func (store *backend) GetPageReverse(bucket string, model interface{}, limit int, startBefore string) (*map[string]interface{}, string, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	if store.Connection == nil {
+		return nil, "", kvbase.ErrClosed
+	}
+
+	keys := make([]string, 0, len(store.Connection[bucket]))
+	for key := range store.Connection[bucket] {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	page, cursor := kvdriver.PageReverse(keys, limit, startBefore)
+	results := make(map[string]interface{}, len(page))
+
+	for _, key := range page {
+		record := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(store.Connection[bucket][key], &record); err != nil {
+			return nil, "", err
+		}
+
+		results[key] = record
+	}
+
+	return &results, cursor, nil
+}
+
+// GetPrefix returns the records inside of the provided bucket whose keys start with prefix
+func (store *backend) GetPrefix(bucket string, prefix string, model interface{}) (*map[string]interface{}, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	if store.Connection == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	for key, data := range store.Connection[bucket] {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+
+		results[key] = value
+	}
+
+	return &results, nil
+}
+
+// GetRange returns the records inside of the provided bucket whose keys fall between startKey (inclusive) and endKey
+// (exclusive) in byte order
+func (store *backend) GetRange(bucket string, startKey string, endKey string, model interface{}) (*map[string]interface{}, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	if store.Connection == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	results := make(map[string]interface{})
+
+	for key, data := range store.Connection[bucket] {
+		if !kvdriver.InRange(key, startKey, endKey) {
+			continue
+		}
+
+		value := kvdriver.NewModel(model)
+		if err := store.Codec.Unmarshal(data, &value); err != nil {
+			return nil, err
+		}
+
+		results[key] = value
+	}
+
+	return &results, nil
+}
+
+// Keys returns the keys of every record inside of the provided bucket, in sorted order
+func (store *backend) Keys(bucket string) ([]string, error) {
+	store.Mux.RLock()
+	defer store.Mux.RUnlock()
+
+	if store.Connection == nil {
+		return nil, kvbase.ErrClosed
+	}
+
+	keys := make([]string, 0, len(store.Connection[bucket]))
+
+	for key := range store.Connection[bucket] {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Read returns a single struct from the provided bucket, using the provided key
+func (store *backend) Read(bucket string, key string, model interface{}) error {
+	return store.ReadCtx(context.Background(), bucket, key, model)
+}
+
+// ReadCtx is Read, returning the context's error once ctx is done
+func (store *backend) ReadCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	store.Mux.RLock()
+	closed := store.Connection == nil
+	data, ok := store.Connection[bucket][key]
+	store.Mux.RUnlock()
+
+	if closed {
+		return kvbase.ErrClosed
+	}
+
+	if !ok {
+		return kvbase.ErrKeyNotFound
+	}
+
+	return store.Codec.Unmarshal(data, &model)
+}
+
+// RenameBucket moves every record of oldName into newName under the write lock
+func (store *backend) RenameBucket(oldName string, newName string) error {
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	records, ok := store.Connection[oldName]
+	if !ok {
+		return kvbase.ErrBucketNotFound
+	}
+
+	if _, ok := store.Connection[newName]; ok {
+		return kvbase.ErrBucketExists
+	}
+
+	store.Connection[newName] = records
+	delete(store.Connection, oldName)
+
+	return nil
+}
+
+// Restore loads a stream written by Backup under the write lock, dropping every bucket first when wipe is set
+func (store *backend) Restore(r io.Reader, wipe bool) error {
+	records, err := kvbase.ReadBackup(r)
+	if err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	if wipe {
+		store.Connection = make(map[string]map[string][]byte)
+	}
+
+	for _, record := range records {
+		if store.Connection[record.Bucket] == nil {
+			store.Connection[record.Bucket] = make(map[string][]byte)
+		}
+
+		store.Connection[record.Bucket][record.Key] = record.Value
+	}
+
+	return nil
+}
+
+// Update modifies an existing record from the backend, inside of the provided bucket, using the provided key
+func (store *backend) Update(bucket string, key string, model interface{}) error {
+	return store.UpdateCtx(context.Background(), bucket, key, model)
+}
+
+// UpdateCtx is Update, returning the context's error once ctx is done
+func (store *backend) UpdateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := kvdriver.Marshal(store.Codec, model)
+	if err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	if _, ok := store.Connection[bucket][key]; !ok {
+		return kvbase.ErrKeyNotFound
+	}
+
+	store.put(bucket, key, data)
+
+	return nil
+}
+
+// Upsert inserts or replaces a record, regardless of whether the key already exists
+func (store *backend) Upsert(bucket string, key string, model interface{}) error {
+	return store.UpsertCtx(context.Background(), bucket, key, model)
+}
+
+// UpsertCtx is Upsert, returning the context's error once ctx is done
+func (store *backend) UpsertCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, err := kvdriver.Marshal(store.Codec, model)
+	if err != nil {
+		return err
+	}
+
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	store.put(bucket, key, data)
+
+	return nil
+}
+
+// Tx runs fn while holding the write lock, staging its writes and applying them together once fn succeeds
+func (store *backend) Tx(fn func(tx kvbase.Transaction) error) error {
+	store.Mux.Lock()
+	defer store.Mux.Unlock()
+
+	if store.Connection == nil {
+		return kvbase.ErrClosed
+	}
+
+	tx := transaction{
+		store:  store,
+		staged: make(map[string]map[string][]byte),
+	}
+
+	if err := fn(&tx); err != nil {
+		return err
+	}
+
+	for bucket, records := range tx.staged {
+		for key, data := range records {
+			if data == nil {
+				delete(store.Connection[bucket], key)
+			} else {
+				store.put(bucket, key, data)
+			}
+		}
+	}
+
+	return nil
+}
+
+// put stores a record, creating its bucket when needed. The caller must hold the write lock.
+func (store *backend) put(bucket string, key string, data []byte) {
+	if store.Connection[bucket] == nil {
+		store.Connection[bucket] = make(map[string][]byte)
+	}
+
+	store.Connection[bucket][key] = data
+}
+
+// transaction stages writes on top of the store's records. A nil staged value marks a deleted record.
+type transaction struct {
+	store  *backend
+	staged map[string]map[string][]byte
+}
+
+// Create inserts a record within the transaction
+func (t *transaction) Create(bucket string, key string, model interface{}) error {
+	if _, ok := t.get(bucket, key); ok {
+		return kvbase.ErrKeyExists
+	}
+
+	return t.write(bucket, key, model)
+}
+
+// Delete removes a record within the transaction
+func (t *transaction) Delete(bucket string, key string) error {
+	if _, ok := t.get(bucket, key); !ok {
+		return kvbase.ErrKeyNotFound
+	}
+
+	t.stage(bucket, key, nil)
+
+	return nil
+}
+
+// Read returns a single struct within the transaction
+func (t *transaction) Read(bucket string, key string, model interface{}) error {
+	data, ok := t.get(bucket, key)
+	if !ok {
+		return kvbase.ErrKeyNotFound
+	}
+
+	return t.store.Codec.Unmarshal(data, &model)
+}
+
+// Update modifies an existing record within the transaction
+func (t *transaction) Update(bucket string, key string, model interface{}) error {
+	if _, ok := t.get(bucket, key); !ok {
+		return kvbase.ErrKeyNotFound
+	}
+
+	return t.write(bucket, key, model)
+}
+
+func (t *transaction) get(bucket string, key string) ([]byte, bool) {
+	if data, ok := t.staged[bucket][key]; ok {
+		return data, data != nil
+	}
+
+	data, ok := t.store.Connection[bucket][key]
+
+	return data, ok
+}
+
+func (t *transaction) stage(bucket string, key string, data []byte) {
+	if t.staged[bucket] == nil {
+		t.staged[bucket] = make(map[string][]byte)
+	}
+
+	t.staged[bucket][key] = data
+}
+
+func (t *transaction) write(bucket string, key string, model interface{}) error {
+	data, err := kvdriver.Marshal(t.store.Codec, model)
+	if err != nil {
+		return err
+	}
+
+	t.stage(bucket, key, data)
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package kvbaseBackendMemory_test
+
+import (
+	"github.com/Wolveix/kvbase"
+	_ "github.com/Wolveix/kvbase/backend/memory"
+	"github.com/Wolveix/kvbase/pkg/kvbaseBackendTest"
+	"testing"
+)
+
+func Test_Memory(t *testing.T) {
+	kvbaseBackendTest.RunTests(t, "memory", "testdata", true)
+}
+
+func Benchmark_Memory(b *testing.B) {
+	kvbaseBackendTest.RunBenches(b, "memory", "testdata", true)
+}
+
+func Test_GetIndependentValues(t *testing.T) {
+	type user struct {
+		Name string
+	}
+
+	store, err := kvbase.New("memory", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"John", "James", "Jane"} {
+		if err := store.Create("users", name, &user{name}); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	results, err := store.Get("users", &user{})
+	if err != nil {
+		t.Fatal("Error on record get:", err)
+	}
+
+	for key, value := range *results {
+		if value.(*user).Name != key {
+			t.Fatal("Expected", key, "got", value.(*user).Name)
+		}
+	}
+}
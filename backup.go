@@ -0,0 +1,273 @@
+package kvbase
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strconv"
+)
+
+// backupMagic starts every backup stream, followed by the layout version
+var backupMagic = []byte("KVBASEBK")
+
+// BackupVersion is the backup layout written by BackupWriter
+const BackupVersion byte = 1
+
+// Record tags. A backup is a sequence of tagged records closed by a trailer holding the record count, followed by a
+// CRC-32 of everything before it, so a truncated or altered stream is always detected.
+const (
+	backupTrailer byte = 0x00
+	backupRecord  byte = 0x01
+)
+
+// maxBackupField bounds the length of a single bucket, key or value, so that a corrupt length never triggers a huge
+// allocation
+const maxBackupField = 1 << 30
+
+// BackupRecord is a single record read from a backup stream. Value holds the bytes as they were stored, encoded with
+// the source store's codec.
+type BackupRecord struct {
+	Bucket string
+	Key    string
+	Value  []byte
+}
+
+// BackupWriter writes records in the backend-agnostic backup format shared by every driver
+type BackupWriter struct {
+	count  uint64
+	hash   hash.Hash32
+	raw    io.Writer
+	writer *bufio.Writer
+}
+
+// NewBackupWriter writes the backup header to w and returns a writer for its records
+func NewBackupWriter(w io.Writer) (*BackupWriter, error) {
+	writer := &BackupWriter{
+		hash: crc32.NewIEEE(),
+		raw:  w,
+	}
+
+	writer.writer = bufio.NewWriter(io.MultiWriter(w, writer.hash))
+
+	if _, err := writer.writer.Write(append(append([]byte{}, backupMagic...), BackupVersion)); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// Write appends a record holding value as stored inside of bucket under key
+func (writer *BackupWriter) Write(bucket string, key string, value []byte) error {
+	buffer := make([]byte, 1, 1+3*binary.MaxVarintLen64+len(bucket)+len(key)+len(value))
+	buffer[0] = backupRecord
+
+	for _, field := range [][]byte{[]byte(bucket), []byte(key), value} {
+		var length [binary.MaxVarintLen64]byte
+		buffer = append(buffer, length[:binary.PutUvarint(length[:], uint64(len(field)))]...)
+		buffer = append(buffer, field...)
+	}
+
+	writer.count++
+
+	_, err := writer.writer.Write(buffer)
+
+	return err
+}
+
+// Close writes the trailer and checksum, and flushes the stream. It doesn't close the underlying writer.
+func (writer *BackupWriter) Close() error {
+	trailer := make([]byte, 9)
+	trailer[0] = backupTrailer
+	binary.BigEndian.PutUint64(trailer[1:], writer.count)
+
+	if _, err := writer.writer.Write(trailer); err != nil {
+		return err
+	}
+
+	if err := writer.writer.Flush(); err != nil {
+		return err
+	}
+
+	checksum := make([]byte, 4)
+	binary.BigEndian.PutUint32(checksum, writer.hash.Sum32())
+
+	_, err := writer.raw.Write(checksum)
+
+	return err
+}
+
+// BackupReader reads the records of a backup stream one at a time
+type BackupReader struct {
+	count  uint64
+	done   bool
+	reader *backupReader
+}
+
+// NewBackupReader reads the backup header from r and returns a reader for its records
+func NewBackupReader(r io.Reader) (*BackupReader, error) {
+	reader := &backupReader{bufio.NewReader(r), crc32.NewIEEE()}
+
+	header := make([]byte, len(backupMagic)+1)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, corruptBackup(err)
+	}
+
+	if !bytes.Equal(header[:len(backupMagic)], backupMagic) {
+		return nil, WrapError(ErrCorruptBackup, errors.New("missing backup header"))
+	}
+
+	if version := header[len(backupMagic)]; version != BackupVersion {
+		return nil, WrapError(ErrCorruptBackup, errors.New("unsupported backup version "+strconv.Itoa(int(version))))
+	}
+
+	return &BackupReader{reader: reader}, nil
+}
+
+// Next returns the next record of the stream. Once the trailer has been reached and the stream's record count and
+// checksum verified, it returns io.EOF. Problems with the stream itself are returned as ErrCorruptBackup, while errors
+// from the underlying reader are returned as they are.
+func (r *BackupReader) Next() (*BackupRecord, error) {
+	if r.done {
+		return nil, io.EOF
+	}
+
+	tag, err := r.reader.ReadByte()
+	if err != nil {
+		return nil, corruptBackup(err)
+	}
+
+	if tag == backupTrailer {
+		return nil, r.verify()
+	} else if tag != backupRecord {
+		return nil, WrapError(ErrCorruptBackup, errors.New("unknown record tag "+strconv.Itoa(int(tag))))
+	}
+
+	var fields [3][]byte
+
+	for i := range fields {
+		if fields[i], err = r.reader.field(); err != nil {
+			return nil, corruptBackup(err)
+		}
+	}
+
+	r.count++
+
+	return &BackupRecord{string(fields[0]), string(fields[1]), fields[2]}, nil
+}
+
+// verify checks the trailer, returning io.EOF when the stream is intact
+func (r *BackupReader) verify() error {
+	count := make([]byte, 8)
+	if _, err := io.ReadFull(r.reader, count); err != nil {
+		return corruptBackup(err)
+	}
+
+	if binary.BigEndian.Uint64(count) != r.count {
+		return WrapError(ErrCorruptBackup, errors.New("record count mismatch"))
+	}
+
+	expected := r.reader.hash.Sum32()
+
+	checksum := make([]byte, 4)
+	if _, err := io.ReadFull(r.reader.reader, checksum); err != nil {
+		return corruptBackup(err)
+	}
+
+	if binary.BigEndian.Uint32(checksum) != expected {
+		return WrapError(ErrCorruptBackup, errors.New("checksum mismatch"))
+	}
+
+	if _, err := r.reader.reader.ReadByte(); err != io.EOF {
+		return WrapError(ErrCorruptBackup, errors.New("trailing data after the trailer"))
+	}
+
+	r.done = true
+
+	return io.EOF
+}
+
+// ReadBackup reads and validates a whole backup stream, returning its records in the order they were written. Drivers
+// call it before touching the store, so that a corrupt stream never leaves a partial restore behind.
+func ReadBackup(r io.Reader) ([]BackupRecord, error) {
+	reader, err := NewBackupReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []BackupRecord
+
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		records = append(records, *record)
+	}
+}
+
+// backupReader hashes the bytes it consumes, so that the checksum covers exactly what precedes it
+type backupReader struct {
+	reader *bufio.Reader
+	hash   hash.Hash32
+}
+
+func (r *backupReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.hash.Write(p[:n])
+
+	return n, err
+}
+
+func (r *backupReader) ReadByte() (byte, error) {
+	b, err := r.reader.ReadByte()
+	if err == nil {
+		r.hash.Write([]byte{b})
+	}
+
+	return b, err
+}
+
+func (r *backupReader) field() ([]byte, error) {
+	var length uint64
+
+	// The length is a uvarint, read by hand so that an oversized length is rejected before it can overflow
+	for shift := uint(0); ; shift += 7 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		length |= uint64(b&0x7F) << shift
+
+		if length > maxBackupField {
+			return nil, WrapError(ErrCorruptBackup, errors.New("field length exceeds "+strconv.Itoa(maxBackupField)+" bytes"))
+		}
+
+		if b < 0x80 {
+			break
+		}
+	}
+
+	field := make([]byte, length)
+	if _, err := io.ReadFull(r, field); err != nil {
+		return nil, err
+	}
+
+	return field, nil
+}
+
+// corruptBackup reports a stream ending early as corrupt, passing other read errors through
+func corruptBackup(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return WrapError(ErrCorruptBackup, io.ErrUnexpectedEOF)
+	}
+
+	return err
+}
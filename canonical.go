@@ -0,0 +1,217 @@
+package kvbase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ErrNotCanonical is returned when a value can't be serialized in canonical form
+var ErrNotCanonical = errors.New("kvbase: value can't be canonicalized")
+
+// WithCanonicalJSON re-serializes every value in canonical form before it is written, following RFC 8785 (JCS):
+// object keys are sorted, insignificant whitespace is removed and numbers are written in their shortest form.
+// Semantically identical documents are then stored as identical bytes. It requires the JSON codec.
+func WithCanonicalJSON() Option {
+	return func(options *Options) {
+		options.CanonicalJSON = true
+	}
+}
+
+// CanonicalJSON returns the canonical form of a JSON document, as written by WithCanonicalJSON
+func CanonicalJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, WrapError(ErrNotCanonical, err)
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, WrapError(ErrNotCanonical, errors.New("trailing data after the document"))
+	}
+
+	var buffer bytes.Buffer
+	if err := writeCanonical(&buffer, value); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// canonicalCodec wraps the JSON codec, canonicalizing the values it encodes
+type canonicalCodec struct {
+	Codec
+}
+
+// Marshal encodes model as canonical JSON
+func (codec canonicalCodec) Marshal(model interface{}) ([]byte, error) {
+	data, err := codec.Codec.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+
+	return CanonicalJSON(data)
+}
+
+func writeCanonical(buffer *bytes.Buffer, value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		buffer.WriteString("null")
+	case bool:
+		buffer.WriteString(strconv.FormatBool(value))
+	case json.Number:
+		number, err := value.Float64()
+		if err != nil || math.IsInf(number, 0) || math.IsNaN(number) {
+			return WrapError(ErrNotCanonical, errors.New("number "+value.String()+" is out of range"))
+		}
+
+		// Numbers that don't survive the round trip through a float64, such as integers above 2^53, would be stored
+		// as a different value
+		formatted := formatNumber(number)
+		exact, _ := new(big.Rat).SetString(value.String())
+		rounded, _ := new(big.Rat).SetString(formatted)
+		if exact == nil || rounded == nil || exact.Cmp(rounded) != 0 {
+			return WrapError(ErrNotCanonical, errors.New("number "+value.String()+" can't be represented exactly"))
+		}
+
+		buffer.WriteString(formatted)
+	case string:
+		writeString(buffer, value)
+	case []interface{}:
+		buffer.WriteByte('[')
+
+		for i, element := range value {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+
+			if err := writeCanonical(buffer, element); err != nil {
+				return err
+			}
+		}
+
+		buffer.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+
+		// JCS orders keys by their UTF-16 code units, which only differs from byte order outside of the BMP
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := utf16.Encode([]rune(keys[i])), utf16.Encode([]rune(keys[j]))
+			for k := 0; k < len(a) && k < len(b); k++ {
+				if a[k] != b[k] {
+					return a[k] < b[k]
+				}
+			}
+
+			return len(a) < len(b)
+		})
+
+		buffer.WriteByte('{')
+
+		for i, key := range keys {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+
+			writeString(buffer, key)
+			buffer.WriteByte(':')
+
+			if err := writeCanonical(buffer, value[key]); err != nil {
+				return err
+			}
+		}
+
+		buffer.WriteByte('}')
+	}
+
+	return nil
+}
+
+// formatNumber writes a number the way ECMAScript's Number.prototype.toString does, as required by JCS
+func formatNumber(number float64) string {
+	if number == 0 {
+		return "0"
+	}
+
+	sign := ""
+	if number < 0 {
+		sign, number = "-", -number
+	}
+
+	// The shortest round-tripping digits d.ddd and exponent, as in "1.2345e+06"
+	scientific := strconv.FormatFloat(number, 'e', -1, 64)
+	mantissa, exponent := scientific[:strings.IndexByte(scientific, 'e')], scientific[strings.IndexByte(scientific, 'e')+1:]
+	digits := strings.Replace(mantissa, ".", "", 1)
+
+	n, _ := strconv.Atoi(exponent)
+	n++
+
+	k := len(digits)
+
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits
+	}
+
+	result := sign + digits[:1]
+	if k > 1 {
+		result += "." + digits[1:]
+	}
+
+	if n-1 >= 0 {
+		return result + "e+" + strconv.Itoa(n-1)
+	}
+
+	return result + "e" + strconv.Itoa(n-1)
+}
+
+func writeString(buffer *bytes.Buffer, value string) {
+	const hex = "0123456789abcdef"
+
+	buffer.WriteByte('"')
+
+	for _, r := range value {
+		switch r {
+		case '"':
+			buffer.WriteString(`\"`)
+		case '\\':
+			buffer.WriteString(`\\`)
+		case '\b':
+			buffer.WriteString(`\b`)
+		case '\f':
+			buffer.WriteString(`\f`)
+		case '\n':
+			buffer.WriteString(`\n`)
+		case '\r':
+			buffer.WriteString(`\r`)
+		case '\t':
+			buffer.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				buffer.WriteString(`\u00`)
+				buffer.WriteByte(hex[r>>4])
+				buffer.WriteByte(hex[r&0xF])
+			} else {
+				buffer.WriteRune(r)
+			}
+		}
+	}
+
+	buffer.WriteByte('"')
+}
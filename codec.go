@@ -0,0 +1,76 @@
+package kvbase
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+)
+
+// Codec serializes the records stored by a backend. Drivers pass Unmarshal a pointer to the model provided by the
+// caller, which may itself be wrapped in an interface{} pointer; codecs that can't decode into an interface should
+// unwrap it.
+type Codec interface {
+	Marshal(model interface{}) ([]byte, error)
+	Unmarshal(data []byte, model interface{}) error
+}
+
+// JSONCodec stores records as JSON. It is the default codec, and the only one able to decode records without a typed
+// model, into map[string]interface{}.
+type JSONCodec struct{}
+
+// Marshal encodes model as JSON
+func (JSONCodec) Marshal(model interface{}) ([]byte, error) {
+	return json.Marshal(model)
+}
+
+// Unmarshal decodes JSON data into model
+func (JSONCodec) Unmarshal(data []byte, model interface{}) error {
+	return json.Unmarshal(data, model)
+}
+
+// GobCodec stores records with encoding/gob. Records can only be decoded into a typed model pointer.
+type GobCodec struct{}
+
+// Marshal encodes model with encoding/gob
+func (GobCodec) Marshal(model interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := gob.NewEncoder(&buffer).Encode(model); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Unmarshal decodes gob data into model
+func (GobCodec) Unmarshal(data []byte, model interface{}) error {
+	if wrapped, ok := model.(*interface{}); ok {
+		model = *wrapped
+	}
+
+	if model == nil {
+		return errors.New("kvbase: gob codec requires a model pointer to decode into")
+	}
+
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(model)
+}
+
+// recoveringCodec wraps the store's codec, returning panics raised while encoding or decoding as a *PanicError rather
+// than letting them unwind through the driver
+type recoveringCodec struct {
+	Codec
+}
+
+// Marshal encodes model with the wrapped codec
+func (codec recoveringCodec) Marshal(model interface{}) (data []byte, err error) {
+	defer Recover(&err)
+
+	return codec.Codec.Marshal(model)
+}
+
+// Unmarshal decodes data into model with the wrapped codec
+func (codec recoveringCodec) Unmarshal(data []byte, model interface{}) (err error) {
+	defer Recover(&err)
+
+	return codec.Codec.Unmarshal(data, model)
+}
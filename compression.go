@@ -0,0 +1,119 @@
+package kvbase
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/golang/snappy"
+	"io/ioutil"
+	"strconv"
+)
+
+// Compression identifies the scheme used to compress stored values. Compressed values are enveloped (see
+// kvdriver.Envelope) with the name of their scheme as a transform, so values written with different settings can be
+// told apart on read.
+type Compression byte
+
+const (
+	// NoCompression writes values as encoded by the codec, while still reading compressed values
+	NoCompression Compression = 0x00
+
+	// Gzip compresses values with compress/gzip
+	Gzip Compression = 0x01
+
+	// Snappy compresses values with github.com/golang/snappy
+	Snappy Compression = 0x02
+
+	// Zstd compresses values with github.com/DataDog/zstd, which requires cgo. Without cgo, reading or writing values
+	// compressed with it fails.
+	Zstd Compression = 0x03
+)
+
+// ErrUnknownCompression is returned when opening a store with a compression scheme this version doesn't know, or
+// reading a value enveloped with a transform it doesn't know
+var ErrUnknownCompression = errors.New("kvbase: unknown compression")
+
+// WithCompression compresses values before they are written. Every store decompresses the values it reads, whatever
+// options it was opened with, so compression can be turned on for an existing store, and passing NoCompression (or
+// dropping the option) stops compressing new values while still reading compressed ones.
+func WithCompression(compression Compression) Option {
+	return func(options *Options) {
+		options.Compression = &compression
+	}
+}
+
+// compressions holds the transform of every compression scheme, by the name values are enveloped with
+var compressions = map[string]transform{
+	"gzip":   gzipCompression{},
+	"snappy": snappyCompression{},
+	"zstd":   zstdCompression{},
+}
+
+// compressionTransform returns the transform of the provided scheme, or nil for NoCompression
+func compressionTransform(compression Compression) (transform, error) {
+	switch compression {
+	case NoCompression:
+		return nil, nil
+	case Gzip:
+		return gzipCompression{}, nil
+	case Snappy:
+		return snappyCompression{}, nil
+	case Zstd:
+		return zstdCompression{}, nil
+	}
+
+	return nil, WrapError(ErrUnknownCompression, errors.New("compression "+strconv.Itoa(int(compression))))
+}
+
+// gzipCompression compresses values with compress/gzip
+type gzipCompression struct{}
+
+func (gzipCompression) name() string {
+	return "gzip"
+}
+
+func (gzipCompression) apply(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (gzipCompression) revert(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(reader)
+}
+
+// snappyCompression compresses values with github.com/golang/snappy
+type snappyCompression struct{}
+
+func (snappyCompression) name() string {
+	return "snappy"
+}
+
+func (snappyCompression) apply(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (snappyCompression) revert(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
+
+// zstdCompression compresses values with github.com/DataDog/zstd, see compression_cgo.go
+type zstdCompression struct{}
+
+func (zstdCompression) name() string {
+	return "zstd"
+}
@@ -0,0 +1,19 @@
+//go:build cgo
+// +build cgo
+
+package kvbase
+
+import (
+	"github.com/DataDog/zstd"
+)
+
+// zstdAvailable reports whether values can be compressed with zstd
+const zstdAvailable = true
+
+func (zstdCompression) apply(data []byte) ([]byte, error) {
+	return zstd.Compress(nil, data)
+}
+
+func (zstdCompression) revert(data []byte) ([]byte, error) {
+	return zstd.Decompress(nil, data)
+}
@@ -0,0 +1,22 @@
+//go:build !cgo
+// +build !cgo
+
+package kvbase
+
+import (
+	"errors"
+)
+
+// zstdAvailable reports whether values can be compressed with zstd
+const zstdAvailable = false
+
+// errZstdUnavailable is returned by zstd compression when built without cgo, which its only binding requires
+var errZstdUnavailable = errors.New("kvbase: zstd compression requires cgo")
+
+func (zstdCompression) apply(data []byte) ([]byte, error) {
+	return nil, errZstdUnavailable
+}
+
+func (zstdCompression) revert(data []byte) ([]byte, error) {
+	return nil, errZstdUnavailable
+}
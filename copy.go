@@ -0,0 +1,94 @@
+package kvbase
+
+import (
+	"errors"
+	"io"
+)
+
+// Copy copies every record of every bucket from src into dst, replacing records whose keys already exist in dst.
+// Records are passed from Backup to Restore, so they arrive byte for byte as src stored them and dst must be opened
+// with the same codec options. progress, when not nil, is called with each record as it is read from src. Restore
+// reads the whole stream before writing anything, so a failed copy leaves dst untouched, but every record copied is
+// held in memory at once: copying a store larger than the available memory takes one CopyBucket call per bucket.
+func Copy(src Backend, dst Backend, progress func(bucket string, key string)) error {
+	return copyRecords(src, dst, func(string) bool { return true }, progress)
+}
+
+// CopyBucket copies every record inside of the provided bucket from src into dst, as Copy does. The backup of src is
+// filtered as it's read, so only the bucket's records are held in memory.
+func CopyBucket(src Backend, dst Backend, bucket string, progress func(bucket string, key string)) error {
+	return copyRecords(src, dst, func(name string) bool { return name == bucket }, progress)
+}
+
+// copyRecords pipes a backup of src through filter into a restore of dst
+func copyRecords(src Backend, dst Backend, filter func(bucket string) bool, progress func(bucket string, key string)) error {
+	backupReader, backupWriter := io.Pipe()
+	restoreReader, restoreWriter := io.Pipe()
+
+	backedUp := make(chan struct{})
+
+	go func() {
+		backupWriter.CloseWithError(src.Backup(backupWriter))
+		close(backedUp)
+	}()
+
+	filtered := make(chan error, 1)
+
+	go func() {
+		err := filterBackup(backupReader, restoreWriter, filter, progress)
+
+		// Unblock Backup if the copy stopped early, and hand the outcome to Restore
+		backupReader.CloseWithError(err)
+		restoreWriter.CloseWithError(err)
+
+		filtered <- err
+	}()
+
+	err := dst.Restore(restoreReader, false)
+	restoreReader.Close()
+
+	<-backedUp
+
+	// Errors from reading src take precedence over the restore failing because its stream was cut short
+	if filterErr := <-filtered; filterErr != nil && !errors.Is(filterErr, io.ErrClosedPipe) {
+		return filterErr
+	}
+
+	return err
+}
+
+// filterBackup copies the records of the backup stream r accepted by filter to w, recovering panics raised by progress
+func filterBackup(r io.Reader, w io.Writer, filter func(bucket string) bool, progress func(bucket string, key string)) (err error) {
+	defer Recover(&err)
+
+	reader, err := NewBackupReader(r)
+	if err != nil {
+		return err
+	}
+
+	writer, err := NewBackupWriter(w)
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return writer.Close()
+		} else if err != nil {
+			return err
+		}
+
+		if !filter(record.Bucket) {
+			continue
+		}
+
+		if progress != nil {
+			progress(record.Bucket, record.Key)
+		}
+
+		if err := writer.Write(record.Bucket, record.Key, record.Value); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package kvbase
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// ErrDecryptionFailed is returned when reading a value that can't be decrypted with the store's key, usually because
+// the store was opened with the wrong key or the value was written without encryption
+var ErrDecryptionFailed = errors.New("kvbase: value decryption failed")
+
+// WithValueEncryption encrypts values with AES-GCM before they are written and decrypts them on read. key must be 16,
+// 24 or 32 bytes long, selecting AES-128, AES-192 or AES-256. Only values are encrypted: bucket names and record keys
+// are stored in plaintext. Encrypted values are enveloped (see kvdriver.Envelope), so stores opened without the key
+// refuse them with ErrDecryptionFailed rather than decoding ciphertext.
+func WithValueEncryption(key []byte) Option {
+	return func(options *Options) {
+		options.ValueEncryptionKey = key
+	}
+}
+
+// encryptionTransform names the transform of encrypted values in their envelope
+const encryptionTransform = "aes-gcm"
+
+// encryption encrypts values with AES-GCM. Each value is stored as a random nonce followed by its sealed ciphertext.
+type encryption struct {
+	aead cipher.AEAD
+}
+
+func newEncryption(key []byte) (encryption, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return encryption{}, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return encryption{}, err
+	}
+
+	return encryption{aead}, nil
+}
+
+func (encryption) name() string {
+	return encryptionTransform
+}
+
+// apply encrypts data under a random nonce
+func (encryption encryption) apply(data []byte) ([]byte, error) {
+	nonce := make([]byte, encryption.aead.NonceSize(), encryption.aead.NonceSize()+len(data)+encryption.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return encryption.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// revert decrypts data
+func (encryption encryption) revert(data []byte) ([]byte, error) {
+	size := encryption.aead.NonceSize()
+	if len(data) < size+encryption.aead.Overhead() {
+		return nil, ErrDecryptionFailed
+	}
+
+	plaintext, err := encryption.aead.Open(nil, data[:size], data[size:], nil)
+	if err != nil {
+		return nil, ErrDecryptionFailed
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,70 @@
+package kvbase
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+type wrappedError struct {
+	sentinel error
+	err      error
+}
+
+// WrapError returns an error matching sentinel with errors.Is, while keeping the driver-specific err reachable through
+// errors.Unwrap. Drivers use it to translate their native errors into the kvbase sentinels.
+func WrapError(sentinel error, err error) error {
+	if err == nil {
+		return sentinel
+	}
+
+	return &wrappedError{sentinel, err}
+}
+
+func (e *wrappedError) Error() string {
+	return e.sentinel.Error() + ": " + e.err.Error()
+}
+
+func (e *wrappedError) Is(target error) bool {
+	return target == e.sentinel
+}
+
+func (e *wrappedError) Unwrap() error {
+	return e.err
+}
+
+// BatchError identifies the record that caused a batch operation to be rejected. Nothing from the batch is written.
+type BatchError struct {
+	Key string
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	return "kvbase: batch rejected at key " + e.Key + ": " + e.Err.Error()
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError carries a panic recovered from user-supplied code, such as a codec or a ForEach callback, with the stack
+// it was raised from. It matches ErrCallbackPanic with errors.Is.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrCallbackPanic.Error(), e.Value)
+}
+
+func (e *PanicError) Is(target error) bool {
+	return target == ErrCallbackPanic
+}
+
+// Recover turns a panic into a *PanicError stored in err. It must be deferred directly by the function running the
+// user-supplied code, with err its named result: defer kvbase.Recover(&err).
+func Recover(err *error) {
+	if value := recover(); value != nil {
+		*err = &PanicError{value, debug.Stack()}
+	}
+}
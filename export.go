@@ -0,0 +1,247 @@
+package kvbase
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+)
+
+// ImportSummary describes the outcome of ImportBucket
+type ImportSummary struct {
+	// Imported is the number of records written
+	Imported int
+
+	// Skipped holds, in sorted order, the keys left untouched because they already existed and overwrite wasn't set
+	Skipped []string
+}
+
+// ExportBucket writes every record inside of the provided bucket to w as an indented JSON object keyed by record key,
+// in sorted key order. Values are embedded as they decode to JSON, so no model type is needed; stores opened with a
+// codec other than JSON can't be exported.
+func ExportBucket(store Backend, bucket string, w io.Writer) error {
+	writer := bufio.NewWriter(w)
+
+	if _, err := writer.WriteString("{"); err != nil {
+		return err
+	}
+
+	first := true
+
+	var value json.RawMessage
+	if err := store.ForEach(bucket, &value, func(key string) error {
+		name, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, value, "\t", "\t"); err != nil {
+			return err
+		}
+
+		if !first {
+			writer.WriteString(",")
+		}
+
+		first = false
+
+		writer.WriteString("\n\t")
+		writer.Write(name)
+		writer.WriteString(": ")
+		_, err = indented.WriteTo(writer)
+
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if !first {
+		writer.WriteString("\n")
+	}
+
+	writer.WriteString("}\n")
+
+	return writer.Flush()
+}
+
+// ImportBucket inserts every record of a JSON object keyed by record key, as written by ExportBucket, into the
+// provided bucket. Existing records are replaced when overwrite is set, and otherwise left untouched and reported in
+// the summary. The whole document is parsed before anything is written.
+func ImportBucket(store Backend, bucket string, r io.Reader, overwrite bool) (*ImportSummary, error) {
+	var records map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	summary := &ImportSummary{}
+
+	for _, key := range keys {
+		value := records[key]
+
+		if overwrite {
+			if err := store.Upsert(bucket, key, value); err != nil {
+				return summary, err
+			}
+		} else if err := store.Create(bucket, key, value); errors.Is(err, ErrKeyExists) {
+			summary.Skipped = append(summary.Skipped, key)
+			continue
+		} else if err != nil {
+			return summary, err
+		}
+
+		summary.Imported++
+	}
+
+	return summary, nil
+}
+
+// ExportAndTruncate ships the records of the provided bucket whose keys are <= upToKey (or every record when upToKey
+// is empty) to w, deleting them as it goes, and returns how many records were shipped. Records are processed in sorted
+// key order, batchSize at a time (or all at once when batchSize is zero or less), and each batch is written to w as one
+// JSON object per line, holding the record's key and value.
+//
+// Once a batch is written and flushed, ack is called with its size, and must only return nil once the caller has
+// durably stored the batch. When ack fails, the batch is left in place and the error is returned, so calling
+// ExportAndTruncate again ships it again; output for a batch that wasn't acknowledged should be discarded.
+//
+// Once ack returns nil, the shipped values are recorded under the bucket's name in stateBucket, and the batch is
+// deleted along with that record in a single transaction on backends implementing Transactor. stateBucket is an
+// ordinary bucket, listed by Buckets and included in backups, so it should be dedicated to this bookkeeping and must
+// not be dropped or renamed while a call may be pending; several exported buckets can share it. Records
+// updated after they were read are kept, to be shipped again with their new value. When the delete fails, the record
+// remains, and the next call deletes the acknowledged batch before shipping anything, so that it's never shipped
+// twice; only a crash between ack returning and that record being written ships the batch again. Backends without
+// transactions delete the batch one record at a time, and may delete a record updated concurrently with its deletion.
+// As with ExportBucket, the store must use the JSON codec.
+func ExportAndTruncate(store Backend, bucket string, stateBucket string, upToKey string, w io.Writer, batchSize int, ack func(count int) error) (int, error) {
+	if stateBucket == "" || stateBucket == bucket {
+		return 0, WrapError(ErrInvalidBucket, errors.New("the state bucket must be distinct from the exported bucket"))
+	}
+
+	var acknowledged map[string]json.RawMessage
+	if err := store.Read(stateBucket, bucket, &acknowledged); err == nil {
+		if err := truncate(store, bucket, stateBucket, acknowledged); err != nil {
+			return 0, err
+		}
+	} else if !errors.Is(err, ErrKeyNotFound) {
+		return 0, err
+	}
+
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	if upToKey != "" {
+		keys = keys[:sort.Search(len(keys), func(i int) bool {
+			return keys[i] > upToKey
+		})]
+	}
+
+	if batchSize <= 0 {
+		batchSize = len(keys)
+	}
+
+	writer := bufio.NewWriter(w)
+	shipped := 0
+
+	for len(keys) > 0 {
+		batch := keys
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+
+		keys = keys[len(batch):]
+		values := make(map[string]json.RawMessage, len(batch))
+
+		for _, key := range batch {
+			var value json.RawMessage
+			if err := store.Read(bucket, key, &value); errors.Is(err, ErrKeyNotFound) {
+				continue
+			} else if err != nil {
+				return shipped, err
+			}
+
+			line, err := json.Marshal(struct {
+				Key   string          `json:"key"`
+				Value json.RawMessage `json:"value"`
+			}{key, value})
+			if err != nil {
+				return shipped, err
+			}
+
+			writer.Write(line)
+			writer.WriteString("\n")
+
+			values[key] = value
+		}
+
+		if err := writer.Flush(); err != nil {
+			return shipped, err
+		}
+
+		if err := ack(len(values)); err != nil {
+			return shipped, err
+		}
+
+		shipped += len(values)
+
+		if err := store.Upsert(stateBucket, bucket, values); err != nil {
+			return shipped, err
+		}
+
+		if err := truncate(store, bucket, stateBucket, values); err != nil {
+			return shipped, err
+		}
+	}
+
+	return shipped, nil
+}
+
+// truncate deletes the records of an acknowledged batch that still hold the values shipped, along with the batch's
+// record in stateBucket
+func truncate(store Backend, bucket string, stateBucket string, values map[string]json.RawMessage) error {
+	if _, ok := store.(Transactor); ok {
+		return Tx(store, func(tx Transaction) error {
+			for key, value := range values {
+				var current json.RawMessage
+				if err := tx.Read(bucket, key, &current); errors.Is(err, ErrKeyNotFound) || (err == nil && !bytes.Equal(current, value)) {
+					continue
+				} else if err != nil {
+					return err
+				}
+
+				if err := tx.Delete(bucket, key); err != nil {
+					return err
+				}
+			}
+
+			return tx.Delete(stateBucket, bucket)
+		})
+	}
+
+	for key, value := range values {
+		var current json.RawMessage
+		if err := store.Read(bucket, key, &current); errors.Is(err, ErrKeyNotFound) || (err == nil && !bytes.Equal(current, value)) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		if err := store.Delete(bucket, key); err != nil && !errors.Is(err, ErrKeyNotFound) {
+			return err
+		}
+	}
+
+	return store.Delete(stateBucket, bucket)
+}
@@ -0,0 +1,4 @@
+package kvbase
+
+// ZstdAvailable reports whether the package was built with zstd compression, which requires cgo
+const ZstdAvailable = zstdAvailable
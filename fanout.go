@@ -0,0 +1,28 @@
+package kvbase
+
+// MultiBucketReader is implemented by backends able to read several buckets from a single transaction or snapshot
+type MultiBucketReader interface {
+	GetFromBuckets(buckets []string, model interface{}) (map[string]map[string]interface{}, error)
+}
+
+// GetFromBuckets returns the records of every provided bucket keyed by bucket, each unmarshalled into a new instance
+// of model. Buckets that don't exist contribute empty maps. Backends implementing MultiBucketReader read every bucket
+// from a single transaction or snapshot; other backends fall back to one GetPrefix per bucket.
+func GetFromBuckets(store Backend, buckets []string, model interface{}) (map[string]map[string]interface{}, error) {
+	if reader, ok := store.(MultiBucketReader); ok {
+		return reader.GetFromBuckets(buckets, model)
+	}
+
+	results := make(map[string]map[string]interface{}, len(buckets))
+
+	for _, bucket := range buckets {
+		records, err := store.GetPrefix(bucket, "", model)
+		if err != nil {
+			return nil, err
+		}
+
+		results[bucket] = *records
+	}
+
+	return results, nil
+}
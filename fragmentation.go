@@ -0,0 +1,48 @@
+package kvbase
+
+// BucketFragmentation describes how efficiently a single bucket uses its allocated pages
+type BucketFragmentation struct {
+	Bucket         string
+	Keys           int
+	LogicalBytes   int64
+	AllocatedBytes int64
+	InuseBytes     int64
+	FillPercent    float64
+}
+
+// FragmentationReport describes the physical layout of a file-backed store
+type FragmentationReport struct {
+	FileSize         int64
+	PageSize         int
+	FreelistPages    int
+	PendingPages     int
+	LogicalBytes     int64
+	ReclaimableBytes int64
+	Buckets          []BucketFragmentation
+}
+
+// Fragmenter is implemented by backends able to report on their on-disk fragmentation
+type Fragmenter interface {
+	FragmentationReport(fn func(stats BucketFragmentation) error) (*FragmentationReport, error)
+}
+
+// Fragmentation returns the fragmentation report of the provided store. Per-bucket statistics are streamed to fn as
+// they are computed, with a panic inside fn returned as a *PanicError; when fn is nil they are collected into the
+// report's Buckets field instead.
+func Fragmentation(store Backend, fn func(stats BucketFragmentation) error) (*FragmentationReport, error) {
+	fragmenter, ok := store.(Fragmenter)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	if fn != nil {
+		callback := fn
+		fn = func(stats BucketFragmentation) (err error) {
+			defer Recover(&err)
+
+			return callback(stats)
+		}
+	}
+
+	return fragmenter.FragmentationReport(fn)
+}
@@ -3,8 +3,10 @@ module github.com/Wolveix/kvbase
 go 1.14
 
 require (
+	github.com/DataDog/zstd v1.4.1
 	github.com/boltdb/bolt v1.3.1
 	github.com/dgraph-io/badger/v2 v2.0.3
+	github.com/golang/snappy v0.0.1
 	github.com/patrickmn/go-cache v2.1.0+incompatible
 	github.com/peterbourgon/diskv v2.0.1+incompatible
 	github.com/prologic/bitcask v0.3.5
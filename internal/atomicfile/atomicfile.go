@@ -0,0 +1,63 @@
+// Package atomicfile replaces files on disk so that a crash leaves either the previous or the new contents in place,
+// never a partially written file.
+package atomicfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Hooks used by tests to inject failures between the steps of a write
+var (
+	afterWrite  = func() error { return nil }
+	afterRename = func() error { return nil }
+)
+
+// WriteFile writes data to a temporary file next to path, syncs it and renames it over path, then syncs the parent
+// directory so that the rename itself is durable
+func WriteFile(path string, data []byte, perm os.FileMode) (err error) {
+	dir := filepath.Dir(path)
+
+	file, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+	}()
+
+	if err = file.Chmod(perm); err != nil {
+		return err
+	}
+
+	if _, err = file.Write(data); err != nil {
+		return err
+	}
+
+	if err = file.Sync(); err != nil {
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+
+	if err = afterWrite(); err != nil {
+		return err
+	}
+
+	if err = replace(file.Name(), path); err != nil {
+		return err
+	}
+
+	if err := afterRename(); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
@@ -0,0 +1,91 @@
+package atomicfile
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var errCrash = errors.New("simulated crash")
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomicfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "data.json")
+
+	if err := WriteFile(path, []byte("old"), 0600); err != nil {
+		t.Fatal("Error on initial write:", err)
+	}
+
+	tests := []struct {
+		name     string
+		hook     *func() error
+		expected string
+	}{
+		{"before rename", &afterWrite, "old"},
+		{"before directory sync", &afterRename, "new"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := WriteFile(path, []byte("old"), 0600); err != nil {
+				t.Fatal("Error on reset:", err)
+			}
+
+			original := *test.hook
+			*test.hook = func() error { return errCrash }
+			defer func() { *test.hook = original }()
+
+			if err := WriteFile(path, []byte("new"), 0600); err != errCrash {
+				t.Fatal("Expected the injected failure, got", err)
+			}
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal("Error on reopen:", err)
+			}
+
+			if string(data) != test.expected {
+				t.Fatalf("Expected %q after the crash, got %q", test.expected, data)
+			}
+
+			entries, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != 1 {
+				t.Fatal("Expected temporary files to be cleaned up, got", len(entries), "entries")
+			}
+		})
+	}
+}
+
+func TestWriteFilePermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomicfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "data.json")
+
+	if err := WriteFile(path, []byte("{}"), 0640); err != nil {
+		t.Fatal("Error on write:", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0640 {
+		t.Fatal("Expected the requested permissions, got", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package atomicfile
+
+import (
+	"os"
+)
+
+func replace(source string, destination string) error {
+	return os.Rename(source, destination)
+}
+
+func syncDir(dir string) error {
+	handle, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+
+	return handle.Sync()
+}
@@ -0,0 +1,41 @@
+//go:build windows
+// +build windows
+
+package atomicfile
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	movefileReplaceExisting = 0x1
+	movefileWriteThrough    = 0x8
+)
+
+var moveFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("MoveFileExW")
+
+// replace swaps the file in place with MoveFileEx, which replaces existing files and only returns once the move has
+// been flushed to disk
+func replace(source string, destination string) error {
+	from, err := syscall.UTF16PtrFromString(source)
+	if err != nil {
+		return err
+	}
+
+	to, err := syscall.UTF16PtrFromString(destination)
+	if err != nil {
+		return err
+	}
+
+	if ok, _, err := moveFileEx.Call(uintptr(unsafe.Pointer(from)), uintptr(unsafe.Pointer(to)), movefileReplaceExisting|movefileWriteThrough); ok == 0 {
+		return err
+	}
+
+	return nil
+}
+
+// syncDir is a no-op on Windows, where directories can't be opened for syncing; MOVEFILE_WRITE_THROUGH covers it
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,266 @@
+// Package envelope implements the versioned format wrapping stored values. It is shared by kvbase, which envelopes
+// the values it transforms, and kvdriver, which exports it to drivers.
+package envelope
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"time"
+)
+
+// Magic marks the first byte of an enveloped value. It can never start a UTF-8 encoded JSON document, so values
+// without it are read as bare legacy payloads.
+const Magic byte = 0xFF
+
+// FormatVersion is the newest envelope layout this package can decode
+const FormatVersion byte = 1
+
+// Section types. Types with the SectionCritical bit set change how the payload must be interpreted, so readers that
+// don't know them refuse the value instead of skipping the section.
+const (
+	SectionTTL        byte = 0x01
+	SectionTimestamps byte = 0x02
+	SectionChecksum   byte = 0x03
+	SectionSchema     byte = 0x04
+	SectionCodec      byte = 0x81
+	SectionTransforms byte = 0x82
+
+	SectionCritical byte = 0x80
+)
+
+var (
+	// ErrChecksumMismatch is returned when an envelope's payload doesn't match its checksum section
+	ErrChecksumMismatch = errors.New("kvbase: envelope checksum mismatch")
+
+	// ErrCorruptEnvelope is returned when an envelope can't be parsed
+	ErrCorruptEnvelope = errors.New("kvbase: corrupt envelope")
+
+	// ErrEnvelopeVersion is returned when an envelope was written with a newer, incompatible layout
+	ErrEnvelopeVersion = errors.New("kvbase: unsupported envelope version")
+
+	// ErrUnknownSection is returned when an envelope holds a critical section this package doesn't know
+	ErrUnknownSection = errors.New("kvbase: unknown critical envelope section")
+)
+
+// Section is a raw envelope section, used to carry sections written by newer versions through a decode/encode cycle
+type Section struct {
+	Type byte
+	Data []byte
+}
+
+// Envelope wraps a stored payload with optional metadata
+type Envelope struct {
+	Legacy        bool
+	Flags         uint16
+	ExpiresAt     time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Checksum      bool
+	Codec         string
+	Transforms    []string
+	SchemaVersion uint32
+	Unknown       []Section
+	Payload       []byte
+}
+
+// Encode serializes the envelope. Legacy envelopes are returned as their bare payload.
+func (envelope *Envelope) Encode() []byte {
+	if envelope.Legacy {
+		return envelope.Payload
+	}
+
+	var sections []Section
+
+	if !envelope.ExpiresAt.IsZero() {
+		sections = append(sections, Section{SectionTTL, appendTime(nil, envelope.ExpiresAt)})
+	}
+
+	if !envelope.CreatedAt.IsZero() || !envelope.UpdatedAt.IsZero() {
+		sections = append(sections, Section{SectionTimestamps, appendTime(appendTime(nil, envelope.CreatedAt), envelope.UpdatedAt)})
+	}
+
+	if envelope.Checksum {
+		sections = append(sections, Section{SectionChecksum, appendUint32(nil, crc32.ChecksumIEEE(envelope.Payload))})
+	}
+
+	if envelope.SchemaVersion != 0 {
+		sections = append(sections, Section{SectionSchema, appendUvarint(nil, uint64(envelope.SchemaVersion))})
+	}
+
+	if envelope.Codec != "" {
+		sections = append(sections, Section{SectionCodec, []byte(envelope.Codec)})
+	}
+
+	if len(envelope.Transforms) > 0 {
+		var data []byte
+		for _, transform := range envelope.Transforms {
+			data = appendBytes(data, []byte(transform))
+		}
+
+		sections = append(sections, Section{SectionTransforms, data})
+	}
+
+	sections = append(sections, envelope.Unknown...)
+
+	data := []byte{Magic, FormatVersion, byte(envelope.Flags >> 8), byte(envelope.Flags)}
+	data = appendUvarint(data, uint64(len(sections)))
+
+	for _, section := range sections {
+		data = append(data, section.Type)
+		data = appendBytes(data, section.Data)
+	}
+
+	return append(data, envelope.Payload...)
+}
+
+// Decode parses an enveloped value. Values without the magic byte are returned as legacy envelopes holding the whole
+// value as their payload. Unknown non-critical sections are skipped and kept in Unknown.
+func Decode(data []byte) (*Envelope, error) {
+	if len(data) == 0 || data[0] != Magic {
+		return &Envelope{Legacy: true, Payload: data}, nil
+	}
+
+	if len(data) < 4 {
+		return nil, ErrCorruptEnvelope
+	}
+
+	if data[1] > FormatVersion {
+		return nil, ErrEnvelopeVersion
+	}
+
+	envelope := Envelope{Flags: binary.BigEndian.Uint16(data[2:4])}
+	data = data[4:]
+
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, ErrCorruptEnvelope
+	}
+	data = data[n:]
+
+	var checksum []byte
+
+	for i := uint64(0); i < count; i++ {
+		if len(data) == 0 {
+			return nil, ErrCorruptEnvelope
+		}
+
+		sectionType := data[0]
+
+		section, rest, err := readBytes(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		data = rest
+
+		switch sectionType {
+		case SectionTTL:
+			if envelope.ExpiresAt, _, err = readTime(section); err != nil {
+				return nil, err
+			}
+		case SectionTimestamps:
+			if envelope.CreatedAt, section, err = readTime(section); err != nil {
+				return nil, err
+			}
+
+			if envelope.UpdatedAt, _, err = readTime(section); err != nil {
+				return nil, err
+			}
+		case SectionChecksum:
+			if len(section) != 4 {
+				return nil, ErrCorruptEnvelope
+			}
+
+			checksum = section
+		case SectionSchema:
+			version, n := binary.Uvarint(section)
+			if n <= 0 {
+				return nil, ErrCorruptEnvelope
+			}
+
+			envelope.SchemaVersion = uint32(version)
+		case SectionCodec:
+			envelope.Codec = string(section)
+		case SectionTransforms:
+			for len(section) > 0 {
+				var transform []byte
+				if transform, section, err = readBytes(section); err != nil {
+					return nil, err
+				}
+
+				envelope.Transforms = append(envelope.Transforms, string(transform))
+			}
+		default:
+			if sectionType&SectionCritical != 0 {
+				return nil, ErrUnknownSection
+			}
+
+			envelope.Unknown = append(envelope.Unknown, Section{sectionType, section})
+		}
+	}
+
+	envelope.Payload = data
+
+	if checksum != nil {
+		if binary.BigEndian.Uint32(checksum) != crc32.ChecksumIEEE(envelope.Payload) {
+			return nil, ErrChecksumMismatch
+		}
+
+		envelope.Checksum = true
+	}
+
+	return &envelope, nil
+}
+
+func appendUint32(data []byte, value uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], value)
+
+	return append(data, buf[:]...)
+}
+
+func appendUvarint(data []byte, value uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+
+	return append(data, buf[:binary.PutUvarint(buf[:], value)]...)
+}
+
+func appendVarint(data []byte, value int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+
+	return append(data, buf[:binary.PutVarint(buf[:], value)]...)
+}
+
+func appendBytes(data []byte, value []byte) []byte {
+	return append(appendUvarint(data, uint64(len(value))), value...)
+}
+
+func appendTime(data []byte, value time.Time) []byte {
+	if value.IsZero() {
+		return appendVarint(data, 0)
+	}
+
+	return appendVarint(data, value.UnixNano())
+}
+
+func readBytes(data []byte) ([]byte, []byte, error) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < length {
+		return nil, nil, ErrCorruptEnvelope
+	}
+
+	return data[n : n+int(length)], data[n+int(length):], nil
+}
+
+func readTime(data []byte) (time.Time, []byte, error) {
+	nanos, n := binary.Varint(data)
+	if n <= 0 {
+		return time.Time{}, nil, ErrCorruptEnvelope
+	}
+
+	if nanos == 0 {
+		return time.Time{}, data[n:], nil
+	}
+
+	return time.Unix(0, nanos), data[n:], nil
+}
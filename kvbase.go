@@ -1,24 +1,182 @@
 package kvbase
 
 import (
+	"context"
 	"errors"
+	"io"
 	"sort"
+	"time"
 )
 
-// Backend implements all common backend functions
+// Backend is the method set every driver implements. Drivers should assert that they satisfy it at compile time with
+// var _ kvbase.Backend = (*backend)(nil) (or kvbase.BackendCtx, which embeds it), so that a method added here breaks
+// the build of incomplete drivers. Drivers without native buckets store each record under its escaped bucket name and
+// key joined by a separator (see kvdriver.Key), so that every bucket name is kept apart from every other.
 type Backend interface {
+	// Backup writes every record of every bucket to w in a backend-agnostic format, so that a backup taken from one
+	// driver can be restored into another. Drivers with transactions or snapshots read from a single consistent view.
+	// Values are written as stored, so the backup must be restored into a store opened with the same codec options.
+	Backup(w io.Writer) error
+
+	// Buckets returns the name of every bucket in sorted order, or an empty slice when there are none. Drivers without
+	// native buckets only report buckets holding at least one record.
+	Buckets() ([]string, error)
+
+	// Close releases the underlying database handles. Closing an already closed backend returns nil, and any other
+	// method called after Close returns ErrClosed.
+	Close() error
+
+	// Count returns the total number of records inside of the provided bucket
 	Count(bucket string) (int, error)
+
+	// CountPrefix returns the number of records inside of the provided bucket whose keys start with prefix
+	CountPrefix(bucket string, prefix string) (int, error)
+
+	// Create inserts a record into the backend, failing if the key already exists
 	Create(bucket string, key string, model interface{}) error
+
+	// CreateBatch inserts every record, keyed by record key, as one all-or-nothing operation. If any key already
+	// exists or any record fails to marshal, nothing is written and a *BatchError names the offending key.
+	CreateBatch(bucket string, records map[string]interface{}) error
+
+	// Delete removes a record from the backend, failing if the key doesn't exist
 	Delete(bucket string, key string) error
+
+	// DeleteBatch removes every provided key as one all-or-nothing operation. If any key doesn't exist, nothing is
+	// deleted and a *BatchError names the offending key.
+	DeleteBatch(bucket string, keys []string) error
+
+	// DeletePrefix removes every record inside of the provided bucket whose key starts with prefix, returning how many
+	// records were removed. An empty prefix removes every record while leaving the bucket itself in place.
+	DeletePrefix(bucket string, prefix string) (int, error)
+
+	// Drop deletes a bucket (and all of its contents) from the backend
 	Drop(bucket string) error
+
+	// ForEach unmarshals every record inside of the provided bucket into model, which must be a pointer, and calls fn
+	// with its key, in sorted key order. Records are streamed rather than collected into a map. Returning an error from
+	// fn stops the iteration and returns that error, except for ErrStopIteration, which stops it and returns nil, and a
+	// panic inside fn is returned as a *PanicError. fn must not write to the store, as some drivers hold a read
+	// transaction open for the whole iteration.
+	ForEach(bucket string, model interface{}, fn func(key string) error) error
+
+	// Get returns all records inside of the provided bucket, unmarshalling each into a new instance of model
 	Get(bucket string, model interface{}) (*map[string]interface{}, error)
+
+	// GetPage returns up to limit records inside of the provided bucket in byte-sorted key order, starting after the
+	// startAfter key (or from the first key when empty). The returned cursor is the last key of the page, to be passed
+	// as startAfter for the next page, or empty once the bucket is exhausted. A limit of zero or less returns every
+	// remaining record.
+	GetPage(bucket string, model interface{}, limit int, startAfter string) (*map[string]interface{}, string, error)
+
+	// GetPageReverse mirrors GetPage, walking the bucket from the highest key downward. It returns up to limit records
+	// before the startBefore key (or from the last key when empty), and the cursor is the lowest key of the page, to be
+	// passed as startBefore for the next page, or empty once the bucket is exhausted.
+	GetPageReverse(bucket string, model interface{}, limit int, startBefore string) (*map[string]interface{}, string, error)
+
+	// GetPrefix returns the records inside of the provided bucket whose keys start with prefix, unmarshalling each into a
+	// new instance of model.
+	GetPrefix(bucket string, prefix string, model interface{}) (*map[string]interface{}, error)
+
+	// GetRange returns the records inside of the provided bucket whose keys are >= startKey and < endKey in byte order,
+	// unmarshalling each into a new instance of model. An empty startKey reads from the first key and an empty endKey
+	// reads to the last.
+	GetRange(bucket string, startKey string, endKey string, model interface{}) (*map[string]interface{}, error)
+
+	// Initialize opens the store at source, or in memory when memory is set and the driver supports it
 	Initialize(source string, memory bool) error
+
+	// Keys returns the keys of every record inside of the provided bucket in sorted order, without reading their values
+	Keys(bucket string) ([]string, error)
+
+	// Read unmarshals a single record from the provided bucket into model, using the provided key
 	Read(bucket string, key string, model interface{}) error
+
+	// RenameBucket moves every record of oldName into newName, failing with ErrBucketNotFound if oldName doesn't exist
+	// and ErrBucketExists if newName already does. Drivers with transactions rename atomically.
+	RenameBucket(oldName string, newName string) error
+
+	// Restore loads a stream written by Backup, replacing records whose keys already exist. When wipe is set, every
+	// existing bucket is dropped first. The whole stream is validated before anything is written, so a corrupt stream
+	// returns ErrCorruptBackup, and a bucket name the driver can't store returns ErrInvalidBucket, leaving the store
+	// untouched.
+	Restore(r io.Reader, wipe bool) error
+
+	// Update modifies an existing record from the backend, failing if the key doesn't exist
 	Update(bucket string, key string, model interface{}) error
+
+	// Upsert inserts or replaces a record in a single operation, regardless of whether the key already exists
+	Upsert(bucket string, key string, model interface{}) error
+}
+
+// BackendCtx extends Backend with context-aware variants of its core operations, and is implemented by every bundled
+// driver. The variants return ctx.Err() once ctx is done: point operations check it before touching the store, and
+// iterations (CountCtx, DropCtx and GetCtx) check it between records, so a cancelled request stops partway through
+// a large bucket. The plain methods call these with context.Background().
+type BackendCtx interface {
+	Backend
+
+	// CountCtx is Count, returning the context's error once ctx is done
+	CountCtx(ctx context.Context, bucket string) (int, error)
+
+	// CreateCtx is Create, returning the context's error once ctx is done
+	CreateCtx(ctx context.Context, bucket string, key string, model interface{}) error
+
+	// DeleteCtx is Delete, returning the context's error once ctx is done
+	DeleteCtx(ctx context.Context, bucket string, key string) error
+
+	// DropCtx is Drop, returning the context's error once ctx is done. Records removed before ctx was done stay
+	// removed on drivers without native buckets.
+	DropCtx(ctx context.Context, bucket string) error
+
+	// GetCtx is Get, returning the context's error once ctx is done
+	GetCtx(ctx context.Context, bucket string, model interface{}) (*map[string]interface{}, error)
+
+	// ReadCtx is Read, returning the context's error once ctx is done
+	ReadCtx(ctx context.Context, bucket string, key string, model interface{}) error
+
+	// UpdateCtx is Update, returning the context's error once ctx is done
+	UpdateCtx(ctx context.Context, bucket string, key string, model interface{}) error
+
+	// UpsertCtx is Upsert, returning the context's error once ctx is done
+	UpsertCtx(ctx context.Context, bucket string, key string, model interface{}) error
 }
 
 var (
 	backends = make(map[string]Backend)
+
+	// ErrBucketExists is returned when renaming a bucket to a name that is already in use
+	ErrBucketExists = errors.New("kvbase: bucket already exists")
+
+	// ErrBucketNotFound is returned when the requested bucket doesn't exist
+	ErrBucketNotFound = errors.New("kvbase: bucket does not exist")
+
+	// ErrClosed is returned when a backend is used after it has been closed
+	ErrClosed = errors.New("kvbase: database is closed")
+
+	// ErrCallbackPanic is matched by the *PanicError returned when user-supplied code panics
+	ErrCallbackPanic = errors.New("kvbase: callback panicked")
+
+	// ErrCorruptBackup is returned by Restore when the backup stream is truncated, altered or not a backup at all
+	ErrCorruptBackup = errors.New("kvbase: corrupt backup stream")
+
+	// ErrInvalidBucket is returned for bucket names the operation can't be given, such as an empty name
+	ErrInvalidBucket = errors.New("kvbase: invalid bucket name")
+
+	// ErrKeyExists is returned when creating a record whose key is already in use
+	ErrKeyExists = errors.New("kvbase: key already exists")
+
+	// ErrKeyNotFound is returned when the requested record doesn't exist
+	ErrKeyNotFound = errors.New("kvbase: key does not exist")
+
+	// ErrNotArray is returned by the array helpers when the stored value isn't a JSON array
+	ErrNotArray = errors.New("kvbase: stored value is not an array")
+
+	// ErrStopIteration can be returned from a ForEach callback to stop iterating without ForEach returning an error
+	ErrStopIteration = errors.New("kvbase: stop iteration")
+
+	// ErrNotSupported is returned when the backend doesn't support the requested operation
+	ErrNotSupported = errors.New("kvbase: operation not supported by backend")
 )
 
 func Backends() []string {
@@ -32,17 +190,98 @@ func Backends() []string {
 	return list
 }
 
-func New(backend string, source string, memory bool) (Backend, error) {
+// Options holds the settings applied to a backend by New
+type Options struct {
+	CanonicalJSON      bool
+	Codec              Codec
+	Compression        *Compression
+	MetadataCacheClock func() time.Time
+	MetadataCacheTTL   time.Duration
+	ValueEncryptionKey []byte
+}
+
+// Option configures a backend opened with New
+type Option func(*Options)
+
+// WithCodec stores records with the provided codec instead of JSON. Stores must be reopened with the codec they were
+// written with.
+func WithCodec(codec Codec) Option {
+	return func(options *Options) {
+		options.Codec = codec
+	}
+}
+
+// Configurable is implemented by backends accepting Options. New configures them before every Initialize, resetting
+// any option that isn't provided to its default; backends that aren't Configurable can only be opened without options.
+type Configurable interface {
+	Configure(options Options) error
+}
+
+func New(backend string, source string, memory bool, opts ...Option) (Backend, error) {
 	store := backends[backend]
 
 	if store == nil {
 		return nil, errors.New("kvbase: " + backend + " backend not registered")
 	}
 
+	options := Options{
+		Codec: JSONCodec{},
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.CanonicalJSON {
+		if _, ok := options.Codec.(JSONCodec); !ok {
+			return nil, errors.New("kvbase: canonical JSON requires the JSON codec")
+		}
+
+		options.Codec = canonicalCodec{options.Codec}
+	}
+
+	codec := envelopeCodec{Codec: options.Codec, codec: codecName(options.Codec)}
+
+	if options.Compression != nil {
+		compression, err := compressionTransform(*options.Compression)
+		if err != nil {
+			return nil, err
+		}
+
+		if compression != nil {
+			codec.transforms = append(codec.transforms, compression)
+		}
+	}
+
+	// Values are encrypted last, as ciphertext doesn't compress
+	if options.ValueEncryptionKey != nil {
+		encryption, err := newEncryption(options.ValueEncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+
+		codec.transforms = append(codec.transforms, encryption)
+		codec.encrypted = true
+	}
+
+	options.Codec = recoveringCodec{codec}
+
+	if configurable, ok := store.(Configurable); ok {
+		if err := configurable.Configure(options); err != nil {
+			return nil, err
+		}
+	} else if len(opts) > 0 {
+		return nil, ErrNotSupported
+	}
+
 	if err := store.Initialize(source, memory); err != nil {
 		return nil, err
 	}
 
+	if options.MetadataCacheTTL > 0 {
+		return NewMetadataCache(store, options.MetadataCacheTTL, opts...), nil
+	}
+
 	return store, nil
 }
 
@@ -1,16 +1,33 @@
 package kvbase_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/Wolveix/kvbase"
 	_ "github.com/Wolveix/kvbase/backend/badgerdb"
 	_ "github.com/Wolveix/kvbase/backend/bboltdb"
 	_ "github.com/Wolveix/kvbase/backend/bitcask"
 	_ "github.com/Wolveix/kvbase/backend/boltdb"
 	_ "github.com/Wolveix/kvbase/backend/diskv"
+	_ "github.com/Wolveix/kvbase/backend/file"
 	_ "github.com/Wolveix/kvbase/backend/go-cache"
 	_ "github.com/Wolveix/kvbase/backend/leveldb"
+	_ "github.com/Wolveix/kvbase/backend/memory"
 	"github.com/Wolveix/kvbase/pkg/kvbaseBackendTest"
+	"github.com/Wolveix/kvbase/pkg/kvbasetest"
+	"github.com/Wolveix/kvbase/pkg/kvdriver"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type backend struct {
@@ -22,14 +39,724 @@ type backend struct {
 
 func TestBackends(t *testing.T) {
 	for _, backend := range kvbase.Backends() {
-		kvbaseBackendTest.RunTests(t, backend, "testData", false)
+		kvbaseBackendTest.RunTests(t, backend, "testData", backend == "memory")
+	}
+}
+
+func TestBackupAcrossBackends(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source, err := kvbase.New("bboltdb", filepath.Join(dir, "data.db"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	for _, bucket := range []string{"orders", "users"} {
+		for _, key := range []string{"a", "b"} {
+			if err := source.Create(bucket, key, map[string]string{"Name": bucket + key}); err != nil {
+				t.Fatal("Error on record creation:", err)
+			}
+		}
+	}
+
+	var backup bytes.Buffer
+	if err := source.Backup(&backup); err != nil {
+		t.Fatal("Error on backup:", err)
+	}
+
+	destination, err := kvbase.New("leveldb", filepath.Join(dir, "leveldb"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer destination.Close()
+
+	if err := destination.Restore(&backup, false); err != nil {
+		t.Fatal("Error on restore:", err)
+	}
+
+	for _, bucket := range []string{"orders", "users"} {
+		records, err := destination.Get(bucket, &map[string]string{})
+		if err != nil {
+			t.Fatal("Error on store get:", err)
+		}
+
+		if len(*records) != 2 || (*(*records)["b"].(*map[string]string))["Name"] != bucket+"b" {
+			t.Fatal("Expected the bbolt backup to be restored into leveldb, got:", *records)
+		}
+	}
+
+	// LevelDB escapes bucket names containing its separator, keeping order_items apart from orders
+	if err := source.Create("order_items", "a", map[string]string{"Name": "item"}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	backup.Reset()
+	if err := source.Backup(&backup); err != nil {
+		t.Fatal("Error on backup:", err)
+	}
+
+	if err := destination.Restore(&backup, true); err != nil {
+		t.Fatal("Error on restore:", err)
+	}
+
+	if buckets, err := destination.Buckets(); err != nil || strings.Join(buckets, ",") != "order_items,orders,users" {
+		t.Fatal("Expected every bucket to be restored, got:", buckets, err)
+	}
+
+	if keys, err := destination.Keys("orders"); err != nil || strings.Join(keys, ",") != "a,b" {
+		t.Fatal("Expected only the keys of orders, got:", keys, err)
+	}
+
+	// Restoring through a metadata cache invalidates its cached counts
+	cache := kvbase.NewMetadataCache(source, time.Minute)
+	if counter, err := cache.Count("orders"); err != nil || counter != 2 {
+		t.Fatal("Expected 2 from counter, got:", counter, err)
+	}
+
+	var orders bytes.Buffer
+	if err := destination.Backup(&orders); err != nil {
+		t.Fatal("Error on backup:", err)
+	}
+
+	if err := source.Drop("orders"); err != nil {
+		t.Fatal("Error on bucket drop:", err)
+	}
+
+	if err := source.Create("orders", "c", map[string]string{"Name": "ordersc"}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if err := cache.Restore(&orders, false); err != nil {
+		t.Fatal("Error on restore:", err)
+	}
+
+	if counter, err := cache.Count("orders"); err != nil || counter != 3 {
+		t.Fatal("Expected the restore to invalidate the cached count, got:", counter, err)
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	type inner struct {
+		Values []int
+	}
+
+	type record struct {
+		Created time.Time
+		Data    []byte
+		Inner   inner
+		Tags    map[string]string
+	}
+
+	original := record{time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC), []byte("bytes"), inner{[]int{1, 2, 3}}, map[string]string{"a": "b"}}
+
+	for _, codec := range []kvbase.Codec{kvbase.JSONCodec{}, kvbase.GobCodec{}} {
+		data, err := codec.Marshal(&original)
+		if err != nil {
+			t.Fatal("Error on marshal:", err)
+		}
+
+		// Drivers decode into an interface{} wrapping the model pointer
+		var decoded record
+		var model interface{} = &decoded
+		if err := codec.Unmarshal(data, &model); err != nil {
+			t.Fatal("Error on unmarshal:", err)
+		}
+
+		if !decoded.Created.Equal(original.Created) || string(decoded.Data) != "bytes" || fmt.Sprint(decoded.Inner.Values) != "[1 2 3]" || decoded.Tags["a"] != "b" {
+			t.Fatalf("Expected %T to round-trip the record, got: %+v", codec, decoded)
+		}
+	}
+
+	var untyped interface{}
+	if err := (kvbase.GobCodec{}).Unmarshal([]byte{}, &untyped); err == nil {
+		t.Fatal("Expected the gob codec to require a model pointer")
+	}
+}
+
+func TestCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	document := map[string]string{"Body": strings.Repeat("repetitive ", 1000)}
+
+	open := func(opts ...kvbase.Option) kvbase.Backend {
+		store, err := kvbase.New("leveldb", dir, false, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { _ = store.Close() })
+
+		return store
+	}
+
+	raw := func(store kvbase.Backend, key string) []byte {
+		refs, arena, err := kvbase.ReadManyInto(store, "bucket", []string{key}, nil)
+		if err != nil || !refs[0].Found {
+			t.Fatal("Error on raw read:", err)
+		}
+
+		return refs[0].Bytes(arena)
+	}
+
+	steps := []struct {
+		key         string
+		compression *kvbase.Compression
+		transform   string
+	}{
+		{"plain", nil, ""},
+		{"gzip", compression(kvbase.Gzip), "gzip"},
+		{"snappy", compression(kvbase.Snappy), "snappy"},
+		{"zstd", compression(kvbase.Zstd), "zstd"},
+		{"none", compression(kvbase.NoCompression), ""},
+		{"reopened", nil, ""},
+	}
+
+	if !kvbase.ZstdAvailable {
+		steps = append(steps[:3], steps[4:]...)
+	}
+
+	for i, step := range steps {
+		var opts []kvbase.Option
+		if step.compression != nil {
+			opts = append(opts, kvbase.WithCompression(*step.compression))
+		}
+
+		store := open(opts...)
+
+		if err := store.Create("bucket", step.key, &document); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+
+		data := raw(store, step.key)
+		if step.transform == "" && data[0] != '{' {
+			t.Fatalf("Expected %s to be stored bare, got %#x", step.key, data[0])
+		}
+
+		if step.transform != "" {
+			envelope, err := kvdriver.Decode(data)
+			if err != nil || envelope.Codec != "json" || len(envelope.Transforms) != 1 || envelope.Transforms[0] != step.transform || len(data) > 1000 {
+				t.Fatalf("Expected %s to be enveloped with its transform and compressed, got %v (%d bytes): %v", step.key, envelope, len(data), err)
+			}
+		}
+
+		// Every value written so far stays readable, whatever it was written and read with
+		for _, previous := range steps[:i+1] {
+			record := map[string]string{}
+			if err := store.Read("bucket", previous.key, &record); err != nil {
+				t.Fatal("Error on store read of", previous.key, "with", step.key, "settings:", err)
+			}
+
+			if record["Body"] != document["Body"] {
+				t.Fatal("Expected the document to round-trip for", previous.key)
+			}
+		}
+
+		if err := store.Close(); err != nil {
+			t.Fatal("Error on store close:", err)
+		}
+	}
+
+	if _, err := kvbase.New("leveldb", dir, false, kvbase.WithCompression(kvbase.Compression(0x7f))); !errors.Is(err, kvbase.ErrUnknownCompression) {
+		t.Fatal("Expected ErrUnknownCompression for an unknown scheme, got:", err)
+	}
+
+	unknown := kvdriver.Envelope{Codec: "json", Transforms: []string{"lz4"}, Payload: []byte("x")}
+
+	store := open(kvbase.WithCodec(rawCodec{}))
+	if err := store.Create("bucket", "unknown", unknown.Encode()); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal("Error on store close:", err)
+	}
+
+	store = open()
+	if err := store.Read("bucket", "unknown", &map[string]string{}); !errors.Is(err, kvbase.ErrUnknownCompression) {
+		t.Fatal("Expected ErrUnknownCompression, got:", err)
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	cases := map[string]string{
+		`{"b": [1.0, 2e0, -0], "a": {"z": null, "y": true}}`: `{"a":{"y":true,"z":null},"b":[1,2,0]}`,
+		`[1e21, 1e20, 0.000001, 1e-7, 123.456, -1.5e-10]`:    `[1e+21,100000000000000000000,0.000001,1e-7,123.456,-1.5e-10]`,
+		`"escapes\t\u0001\u00e9\u2028"`:                      "\"escapes\\t\\u0001\u00e9\u2028\"",
+		`{"\ud83d\ude00": 1, "\ufb33": 2}`:                   "{\"\U0001f600\":1,\"\ufb33\":2}",
+	}
+
+	for input, expected := range cases {
+		output, err := kvbase.CanonicalJSON([]byte(input))
+		if err != nil {
+			t.Fatal("Error on canonicalization of", input, ":", err)
+		}
+
+		if string(output) != expected {
+			t.Fatalf("Expected %s to canonicalize to %s, got: %s", input, expected, output)
+		}
+	}
+
+	for _, input := range []string{`1e400`, `{"a": 1} {}`, `{"a": }`, `{"id": 9007199254740993}`} {
+		if _, err := kvbase.CanonicalJSON([]byte(input)); !errors.Is(err, kvbase.ErrNotCanonical) {
+			t.Fatal("Expected ErrNotCanonical for", input, "got:", err)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := kvbase.New("leveldb", dir, false, kvbase.WithCodec(kvbase.GobCodec{}), kvbase.WithCanonicalJSON()); err == nil {
+		t.Fatal("Expected canonical JSON to require the JSON codec")
+	}
+
+	store, err := kvbase.New("leveldb", dir, false, kvbase.WithCanonicalJSON())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if err := store.Create("bucket", "id", map[string]int64{"ID": 9007199254740993}); !errors.Is(err, kvbase.ErrNotCanonical) {
+		t.Fatal("Expected ErrNotCanonical for an int64 ID above 2^53, got:", err)
+	}
+
+	type forward struct {
+		A string
+		B float64
+	}
+
+	type backward struct {
+		B float64
+		A string
+	}
+
+	if err := store.Create("bucket", "forward", &forward{"value", 10}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if err := store.Create("bucket", "backward", &backward{10.0, "value"}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	refs, arena, err := kvbase.ReadManyInto(store, "bucket", []string{"forward", "backward"}, nil)
+	if err != nil {
+		t.Fatal("Error on raw read:", err)
+	}
+
+	if first, second := string(refs[0].Bytes(arena)), string(refs[1].Bytes(arena)); first != second || first != `{"A":"value","B":10}` {
+		t.Fatalf("Expected both documents to be stored identically, got: %s and %s", first, second)
+	}
+
+	if err := store.Create("bucket", "nan", map[string]interface{}{"a": math.NaN()}); err == nil {
+		t.Fatal("Expected a NaN value to be rejected")
+	}
+}
+
+func TestValueEncryption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := []byte("0123456789abcdef0123456789abcdef")
+	document := map[string]string{"Email": "john.smith@example.com"}
+
+	if _, err := kvbase.New("leveldb", dir, false, kvbase.WithValueEncryption([]byte("short"))); err == nil {
+		t.Fatal("Expected an invalid key length to be rejected")
+	}
+
+	store, err := kvbase.New("leveldb", dir, false, kvbase.WithValueEncryption(key), kvbase.WithCompression(kvbase.Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Create("bucket", "john", &document); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if err := store.Create("bucket", "jane", &document); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	refs, arena, err := kvbase.ReadManyInto(store, "bucket", []string{"john", "jane"}, nil)
+	if err != nil {
+		t.Fatal("Error on raw read:", err)
+	}
+
+	if first, second := refs[0].Bytes(arena), refs[1].Bytes(arena); strings.Contains(string(first), "example.com") || string(first) == string(second) {
+		t.Fatal("Expected values to be stored encrypted under distinct nonces")
+	}
+
+	envelope, err := kvdriver.Decode(refs[0].Bytes(arena))
+	if err != nil || envelope.Legacy || envelope.Codec != "json" || len(envelope.Transforms) == 0 || envelope.Transforms[len(envelope.Transforms)-1] != "aes-gcm" {
+		t.Fatal("Expected the value to be enveloped with the json codec and encrypted last, got:", envelope, err)
+	}
+
+	record := map[string]string{}
+	if err := store.Read("bucket", "john", &record); err != nil || record["Email"] != document["Email"] {
+		t.Fatal("Expected the document to round-trip, got:", record, err)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal("Error on store close:", err)
+	}
+
+	wrong := []byte("fedcba9876543210fedcba9876543210")
+	for _, opts := range [][]kvbase.Option{{kvbase.WithValueEncryption(wrong)}, nil} {
+		store, err := kvbase.New("leveldb", dir, false, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := store.Read("bucket", "john", &record); !errors.Is(err, kvbase.ErrDecryptionFailed) {
+			t.Fatal("Expected ErrDecryptionFailed without the right key, got:", err)
+		}
+
+		if opts == nil {
+			if err := store.Create("bucket", "plain", &document); err != nil {
+				t.Fatal("Error on record creation:", err)
+			}
+		}
+
+		if err := store.Close(); err != nil {
+			t.Fatal("Error on store close:", err)
+		}
+	}
+
+	// Values written without encryption can't be passed off as the store's own
+	store, err = kvbase.New("leveldb", dir, false, kvbase.WithValueEncryption(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if err := store.Read("bucket", "plain", &record); !errors.Is(err, kvbase.ErrDecryptionFailed) {
+		t.Fatal("Expected ErrDecryptionFailed for a plaintext value, got:", err)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src, err := kvbase.New("leveldb", filepath.Join(dir, "leveldb"), false, kvbase.WithCompression(kvbase.Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	for _, bucket := range []string{"orders", "users"} {
+		for _, key := range []string{"a", "b"} {
+			if err := src.Create(bucket, key, map[string]string{"Name": bucket + key}); err != nil {
+				t.Fatal("Error on record creation:", err)
+			}
+		}
+	}
+
+	dst, err := kvbase.New("bboltdb", filepath.Join(dir, "data.db"), false, kvbase.WithCompression(kvbase.Gzip))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	if err := dst.Create("users", "a", map[string]string{"Name": "stale"}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	var copied []string
+	if err := kvbase.CopyBucket(src, dst, "users", func(bucket string, key string) {
+		copied = append(copied, bucket+"/"+key)
+	}); err != nil {
+		t.Fatal("Error on bucket copy:", err)
+	}
+
+	if strings.Join(copied, ",") != "users/a,users/b" {
+		t.Fatal("Expected progress for every record of the bucket, got:", copied)
+	}
+
+	if counter, _ := dst.Count("orders"); counter != 0 {
+		t.Fatal("Expected other buckets to be left out, got:", counter)
+	}
+
+	record := map[string]string{}
+	if err := dst.Read("users", "a", &record); err != nil || record["Name"] != "usersa" {
+		t.Fatal("Expected the existing record to be replaced, got:", record, err)
+	}
+
+	if err := kvbase.Copy(src, dst, nil); err != nil {
+		t.Fatal("Error on copy:", err)
+	}
+
+	for _, bucket := range []string{"orders", "users"} {
+		original, arena, err := kvbase.ReadManyInto(src, bucket, []string{"a", "b"}, nil)
+		if err != nil {
+			t.Fatal("Error on raw read:", err)
+		}
+
+		copies, copiesArena, err := kvbase.ReadManyInto(dst, bucket, []string{"a", "b"}, nil)
+		if err != nil {
+			t.Fatal("Error on raw read:", err)
+		}
+
+		for i := range original {
+			if !copies[i].Found || !bytes.Equal(original[i].Bytes(arena), copies[i].Bytes(copiesArena)) {
+				t.Fatal("Expected the records of", bucket, "to be copied byte for byte")
+			}
+		}
+	}
+
+	if err := kvbase.Copy(src, dst, func(bucket string, key string) {
+		panic("progress failure")
+	}); !errors.Is(err, kvbase.ErrCallbackPanic) {
+		t.Fatal("Expected ErrCallbackPanic, got:", err)
+	}
+
+	if err := src.Close(); err != nil {
+		t.Fatal("Error on store close:", err)
+	}
+
+	if err := kvbase.Copy(src, dst, nil); !errors.Is(err, kvbase.ErrClosed) {
+		t.Fatal("Expected ErrClosed from a closed source, got:", err)
+	}
+
+	if counter, _ := dst.Count("users"); counter != 2 {
+		t.Fatal("Expected a failed copy to leave the destination untouched, got:", counter)
+	}
+}
+
+func TestExportImportBucket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := kvbase.New("leveldb", dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for key, name := range map[string]string{"jane": "Jane Doe", "john": "John Smith"} {
+		if err := store.Create("users", key, map[string]interface{}{"Name": name, "Tags": []string{"a"}}); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	var export bytes.Buffer
+	if err := kvbase.ExportBucket(store, "users", &export); err != nil {
+		t.Fatal("Error on bucket export:", err)
+	}
+
+	expected := "{\n\t\"jane\": {\n\t\t\"Name\": \"Jane Doe\",\n\t\t\"Tags\": [\n\t\t\t\"a\"\n\t\t]\n\t},\n\t\"john\": {\n\t\t\"Name\": \"John Smith\",\n\t\t\"Tags\": [\n\t\t\t\"a\"\n\t\t]\n\t}\n}\n"
+	if export.String() != expected {
+		t.Fatal("Expected an indented JSON object keyed by record key, got:", export.String())
+	}
+
+	if err := store.Update("users", "john", map[string]string{"Name": "Changed"}); err != nil {
+		t.Fatal("Error on record update:", err)
+	}
+
+	if err := store.Delete("users", "jane"); err != nil {
+		t.Fatal("Error on record deletion:", err)
+	}
+
+	summary, err := kvbase.ImportBucket(store, "users", bytes.NewReader(export.Bytes()), false)
+	if err != nil {
+		t.Fatal("Error on bucket import:", err)
+	}
+
+	if summary.Imported != 1 || strings.Join(summary.Skipped, ",") != "john" {
+		t.Fatalf("Expected jane to be imported and john to be skipped, got: %+v", summary)
+	}
+
+	record := map[string]interface{}{}
+	if err := store.Read("users", "john", &record); err != nil || record["Name"] != "Changed" {
+		t.Fatal("Expected a skipped record to be left untouched, got:", record, err)
+	}
+
+	if summary, err = kvbase.ImportBucket(store, "users", bytes.NewReader(export.Bytes()), true); err != nil || summary.Imported != 2 || len(summary.Skipped) != 0 {
+		t.Fatalf("Expected every record to be imported with overwrite, got: %+v %v", summary, err)
+	}
+
+	if err := store.Read("users", "john", &record); err != nil || record["Name"] != "John Smith" {
+		t.Fatal("Expected the record to be overwritten, got:", record, err)
+	}
+
+	var empty bytes.Buffer
+	if err := kvbase.ExportBucket(store, "missing", &empty); err != nil || empty.String() != "{}\n" {
+		t.Fatal("Expected an empty bucket to export as an empty object, got:", empty.String(), err)
+	}
+
+	if _, err := kvbase.ImportBucket(store, "users", strings.NewReader("[1, 2]"), true); err == nil {
+		t.Fatal("Expected a document that isn't an object to be rejected")
+	}
+}
+
+func TestExportAndTruncate(t *testing.T) {
+	for _, backend := range []string{"bboltdb", "diskv", "leveldb"} {
+		dir, err := ioutil.TempDir("", "kvbase")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		opened, err := kvbase.New(backend, filepath.Join(dir, backend), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Deletes fail while failing is set, through transactions on backends that have them
+		var store interface {
+			kvbase.Backend
+			fail(err error)
+		} = &failingDelete{Backend: opened}
+
+		if _, ok := opened.(kvbase.Transactor); ok {
+			store = &failingTx{failingDelete{Backend: opened}}
+		}
+
+		for i := 1; i <= 9; i++ {
+			if err := store.Create("events", fmt.Sprintf("e%02d", i), map[string]int{"Seq": i, "Version": 1}); err != nil {
+				t.Fatal("Error on record creation:", err)
+			}
+		}
+
+		// The warehouse only keeps the output of acknowledged batches, as a crash discards whatever wasn't confirmed
+		var warehouse, pending bytes.Buffer
+
+		crash := errors.New("crash")
+		ship := func(crashAt int) func(count int) error {
+			batches := 0
+
+			return func(count int) error {
+				if batches++; batches == crashAt {
+					pending.Reset()
+					return crash
+				}
+
+				pending.WriteTo(&warehouse)
+				return nil
+			}
+		}
+
+		if _, err := kvbase.ExportAndTruncate(store, "events", "events", "e07", &pending, 3, ship(0)); !errors.Is(err, kvbase.ErrInvalidBucket) {
+			t.Fatal(backend, "expected the exported bucket to be refused as the state bucket, got:", err)
+		}
+
+		// Crash before the second batch is acknowledged
+		if shipped, err := kvbase.ExportAndTruncate(store, "events", "exports", "e07", &pending, 3, ship(2)); !errors.Is(err, crash) || shipped != 3 {
+			t.Fatal(backend, "expected the first batch to be shipped before the crash, got:", shipped, err)
+		}
+
+		if keys, _ := store.Keys("events"); strings.Join(keys, ",") != "e04,e05,e06,e07,e08,e09" {
+			t.Fatal(backend, "expected only the acknowledged batch to be deleted, got:", keys)
+		}
+
+		// Crash after the second batch is acknowledged, before it's deleted, while one of its records is updated
+		store.fail(crash)
+
+		acknowledge := ship(0)
+		if shipped, err := kvbase.ExportAndTruncate(store, "events", "exports", "e07", &pending, 3, func(count int) error {
+			if err := store.Update("events", "e05", map[string]int{"Seq": 5, "Version": 2}); err != nil {
+				t.Fatal("Error on record update:", err)
+			}
+
+			return acknowledge(count)
+		}); !errors.Is(err, crash) || shipped != 3 {
+			t.Fatal(backend, "expected the second batch to be shipped before the crash, got:", shipped, err)
+		}
+
+		store.fail(nil)
+
+		// The acknowledged batch is deleted without being shipped again, except for the record updated since
+		if shipped, err := kvbase.ExportAndTruncate(store, "events", "exports", "e07", &pending, 3, ship(0)); err != nil || shipped != 2 {
+			t.Fatal(backend, "expected the updated and remaining records to be shipped, got:", shipped, err)
+		}
+
+		var keys []string
+
+		for _, line := range strings.Split(strings.TrimSuffix(warehouse.String(), "\n"), "\n") {
+			var record struct {
+				Key   string
+				Value struct{ Seq, Version int }
+			}
+
+			if err := json.Unmarshal([]byte(line), &record); err != nil || record.Key != fmt.Sprintf("e%02d", record.Value.Seq) {
+				t.Fatal(backend, "expected a record per line, got:", line, err)
+			}
+
+			keys = append(keys, record.Key+"v"+strconv.Itoa(record.Value.Version))
+		}
+
+		if strings.Join(keys, ",") != "e01v1,e02v1,e03v1,e04v1,e05v1,e06v1,e05v2,e07v1" {
+			t.Fatal(backend, "expected every version of every record up to e07 to be shipped exactly once, got:", keys)
+		}
+
+		if keys, _ := store.Keys("events"); strings.Join(keys, ",") != "e08,e09" {
+			t.Fatal(backend, "expected records after upToKey to be kept, got:", keys)
+		}
+
+		if keys, _ := store.Keys("exports"); len(keys) != 0 {
+			t.Fatal(backend, "expected no acknowledged batch to be left, got:", keys)
+		}
+
+		store.Close()
+	}
+}
+
+func TestCodecPanic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := kvbase.New("bboltdb", filepath.Join(dir, "data.db"), false, kvbase.WithCodec(panicCodec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	err = store.Create("bucket", "panic", "value")
+
+	var panicErr *kvbase.PanicError
+	if !errors.As(err, &panicErr) || panicErr.Value != "marshal failure" || len(panicErr.Stack) == 0 {
+		t.Fatal("Expected a *PanicError carrying the panic, got:", err)
+	}
+
+	if err := store.Create("bucket", "key", "ok"); err != nil {
+		t.Fatal("Expected the store to remain writable after a panic, got:", err)
+	}
+
+	if _, err := store.Get("bucket", nil); !errors.Is(err, kvbase.ErrCallbackPanic) {
+		t.Fatal("Expected ErrCallbackPanic while decoding, got:", err)
+	}
+
+	if counter, err := store.Count("bucket"); err != nil || counter != 1 {
+		t.Fatal("Expected 1 record after the panics, got:", counter, err)
 	}
 }
 
 func TestGetBackends(t *testing.T) {
 	backends := kvbase.Backends()
-	if len(backends) != 7 {
-		t.Fatal("Expected 1 backend")
+	if len(backends) != 9 {
+		t.Fatal("Expected 9 backends, got", len(backends))
 	}
 }
 
@@ -54,3 +781,452 @@ func TestRegister(t *testing.T) {
 		t.Fatal("Expected 'backend already registered' error")
 	}
 }
+
+func TestNewWithOptions(t *testing.T) {
+	// kvbasetestdb is registered by TestRegister and doesn't implement Configure
+	if _, err := kvbase.New("kvbasetestdb", "data", false, kvbase.WithCodec(kvbase.GobCodec{})); err != kvbase.ErrNotSupported {
+		t.Fatal("Expected ErrNotSupported for a backend without Configure, got:", err)
+	}
+}
+
+func TestAppendToArray(t *testing.T) {
+	store := kvbasetest.New(t)
+
+	for i := 1; i <= 5; i++ {
+		if err := kvbase.AppendToArray(store, "arrays", "untrimmed", i, 0); err != nil {
+			t.Fatal("Error on array append:", err)
+		}
+
+		if err := kvbase.AppendToArray(store, "arrays", "trimmed", i, 3); err != nil {
+			t.Fatal("Error on array append:", err)
+		}
+	}
+
+	var untrimmed []int
+	if err := store.Read("arrays", "untrimmed", &untrimmed); err != nil {
+		t.Fatal("Error on store read:", err)
+	}
+
+	if fmt.Sprint(untrimmed) != "[1 2 3 4 5]" {
+		t.Fatal("Expected [1 2 3 4 5], got", untrimmed)
+	}
+
+	var trimmed []int
+	if err := store.Read("arrays", "trimmed", &trimmed); err != nil {
+		t.Fatal("Error on store read:", err)
+	}
+
+	if fmt.Sprint(trimmed) != "[3 4 5]" {
+		t.Fatal("Expected [3 4 5], got", trimmed)
+	}
+
+	var window []int
+	if err := kvbase.ReadArraySlice(store, "arrays", "untrimmed", 1, 2, &window); err != nil {
+		t.Fatal("Error on array slice read:", err)
+	}
+
+	if fmt.Sprint(window) != "[2 3]" {
+		t.Fatal("Expected [2 3], got", window)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := kvbase.AppendToArray(store, "arrays", "concurrent", i, 0); err != nil {
+				t.Error("Error on array append:", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var concurrent []int
+	if err := store.Read("arrays", "concurrent", &concurrent); err != nil {
+		t.Fatal("Error on store read:", err)
+	}
+
+	if len(concurrent) != 20 {
+		t.Fatal("Expected 20 elements, got", len(concurrent))
+	}
+
+	if err := store.Create("arrays", "object", map[string]int{"a": 1}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if err := kvbase.AppendToArray(store, "arrays", "object", 1, 0); err != kvbase.ErrNotArray {
+		t.Fatal("Expected ErrNotArray, got", err)
+	}
+
+	failure := errors.New("read failure")
+	if err := kvbase.AppendToArray(failingReader{store, failure}, "arrays", "unreadable", 1, 0); err != failure {
+		t.Fatal("Expected the read error to be returned, got", err)
+	}
+
+	if counter, err := store.Count("arrays"); err != nil || counter != 4 {
+		t.Fatal("Expected a failed read not to create the record, got:", counter, err)
+	}
+}
+
+func TestParseOperation(t *testing.T) {
+	for _, op := range []kvbase.Operation{
+		kvbase.OpBackup, kvbase.OpBuckets, kvbase.OpCount, kvbase.OpCountPrefix, kvbase.OpCreate, kvbase.OpCreateBatch,
+		kvbase.OpDelete, kvbase.OpDeleteBatch, kvbase.OpDeletePrefix, kvbase.OpDrop, kvbase.OpForEach, kvbase.OpGet,
+		kvbase.OpGetPage, kvbase.OpGetPageReverse, kvbase.OpGetPrefix, kvbase.OpGetRange, kvbase.OpKeys, kvbase.OpRead,
+		kvbase.OpRenameBucket, kvbase.OpRestore, kvbase.OpUpdate, kvbase.OpUpsert,
+	} {
+		parsed, err := kvbase.ParseOperation(op.String())
+		if err != nil {
+			t.Fatal("Error on operation parse:", err)
+		}
+
+		if parsed != op {
+			t.Fatal("Expected", op, "got", parsed)
+		}
+	}
+
+	for _, name := range []string{"Create", "unknown"} {
+		if op, err := kvbase.ParseOperation(name); err == nil || op != kvbase.OpUnknown {
+			t.Fatal("Expected OpUnknown and an error for", name, "got:", op, err)
+		}
+	}
+}
+
+func TestUnionReader(t *testing.T) {
+	primary := kvbasetest.New(t, kvbasetest.WithDriver("go-cache"), kvbasetest.WithFixture(map[string]map[string]interface{}{
+		"bucket": {"both": map[string]string{"Name": "primary"}, "primaryOnly": map[string]string{"Name": "primary"}},
+	}))
+
+	secondary := kvbasetest.New(t, kvbasetest.WithDriver("badgerdb"), kvbasetest.WithFixture(map[string]map[string]interface{}{
+		"bucket": {"both": map[string]string{"Name": "secondary"}, "secondaryOnly": map[string]string{"Name": "secondary"}},
+	}))
+
+	fallbacks := 0
+	union := kvbase.NewUnionReader(primary, secondary, func(bucket string, key string) {
+		fallbacks++
+	})
+
+	counter, err := union.Count("bucket")
+	if err != nil {
+		t.Fatal("Error on record count:", err)
+	}
+
+	if counter != 3 {
+		t.Fatal("Expected 3 from counter, got", counter)
+	}
+
+	results, err := union.Get("bucket", nil)
+	if err != nil {
+		t.Fatal("Error on record get:", err)
+	}
+
+	if name := (*results)["both"].(map[string]interface{})["Name"]; name != "primary" {
+		t.Fatal("Expected primary to win the key conflict, got", name)
+	}
+
+	record := map[string]string{}
+	if err := union.Read("bucket", "secondaryOnly", &record); err != nil {
+		t.Fatal("Error on store read:", err)
+	}
+
+	if record["Name"] != "secondary" || fallbacks != 1 {
+		t.Fatal("Expected a single fallback read from secondary, got", record["Name"], fallbacks)
+	}
+
+	if err := union.Read("bucket", "missing", &record); !errors.Is(err, kvbase.ErrKeyNotFound) {
+		t.Fatal("Expected ErrKeyNotFound for missing key, got:", err)
+	}
+
+	keys, err := union.Keys("bucket")
+	if err != nil {
+		t.Fatal("Error on key listing:", err)
+	}
+
+	if strings.Join(keys, ",") != "both,primaryOnly,secondaryOnly" {
+		t.Fatal("Expected the sorted union of both readers' keys, got:", keys)
+	}
+
+	for key, expected := range map[string]bool{"both": true, "primaryOnly": true, "secondaryOnly": true, "missing": false} {
+		if exists, err := union.Exists("bucket", key); err != nil || exists != expected {
+			t.Fatal("Expected", expected, "for the existence of", key, "got:", exists, err)
+		}
+	}
+
+	// Errors other than a missing key must not be hidden by falling back to secondary
+	failure := errors.New("read failure")
+	failing := kvbase.NewUnionReader(failingReader{primary, failure}, secondary, func(bucket string, key string) {
+		fallbacks++
+	})
+
+	if err := failing.Read("bucket", "secondaryOnly", &record); err != failure || fallbacks != 1 {
+		t.Fatal("Expected the primary's error without a fallback, got:", err, fallbacks)
+	}
+
+	if exists, err := failing.Exists("bucket", "secondaryOnly"); err != failure || exists {
+		t.Fatal("Expected the primary's error when checking for existence, got:", exists, err)
+	}
+}
+
+func TestGetFromBuckets(t *testing.T) {
+	store := kvbasetest.New(t, kvbasetest.WithFixture(map[string]map[string]interface{}{
+		"events-1": {"a": map[string]string{"Name": "a"}, "b": map[string]string{"Name": "b"}},
+		"events-2": {"c": map[string]string{"Name": "c"}},
+	}))
+
+	results, err := kvbase.GetFromBuckets(store, []string{"events-1", "events-2", "events-3"}, nil)
+	if err != nil {
+		t.Fatal("Error on multi-bucket get:", err)
+	}
+
+	if len(results["events-1"]) != 2 || len(results["events-2"]) != 1 {
+		t.Fatal("Expected the records of each bucket, got:", results)
+	}
+
+	if records, ok := results["events-3"]; !ok || len(records) != 0 {
+		t.Fatal("Expected an empty map for the missing bucket, got:", records, ok)
+	}
+}
+
+func TestMetadataCache(t *testing.T) {
+	store := &statsCounter{Backend: kvbasetest.New(t)}
+
+	now := time.Now()
+	cache := kvbase.NewMetadataCache(store, time.Minute, kvbase.WithMetadataCacheClock(func() time.Time {
+		return now
+	}))
+
+	if err := cache.Create("bucket", "k0", &map[string]string{}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if counter, _ := cache.Count("bucket"); counter != 1 {
+		t.Fatal("Expected 1 from counter, got", counter)
+	}
+
+	if counter, _ := cache.Count("other"); counter != 0 {
+		t.Fatal("Expected 0 from counter, got", counter)
+	}
+
+	if keys, _ := cache.Keys("bucket"); strings.Join(keys, ",") != "k0" {
+		t.Fatal("Expected k0 from keys, got", keys)
+	}
+
+	if stats, _ := cache.Stats(); stats.DiskBytes != 1 {
+		t.Fatal("Expected the first stats report, got", stats.DiskBytes)
+	}
+
+	if err := cache.Create("bucket", "k1", &map[string]string{}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if counter, _ := cache.Count("bucket"); counter != 2 {
+		t.Fatal("Expected write to invalidate the cached count, got", counter)
+	}
+
+	if keys, _ := cache.Keys("bucket"); strings.Join(keys, ",") != "k0,k1" {
+		t.Fatal("Expected write to invalidate the cached keys, got", keys)
+	}
+
+	if stats, _ := kvbase.Stats(cache); stats.DiskBytes != 2 {
+		t.Fatal("Expected write to invalidate the cached stats, got", stats.DiskBytes)
+	}
+
+	// Writes bypassing the cache are only seen once the cached results expire
+	if err := store.Create("bucket", "k2", &map[string]string{}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if counter, _ := cache.Count("bucket"); counter != 2 {
+		t.Fatal("Expected cached count of 2, got", counter)
+	}
+
+	keys, _ := cache.Keys("bucket")
+	if strings.Join(keys, ",") != "k0,k1" {
+		t.Fatal("Expected cached keys, got", keys)
+	}
+
+	keys[0] = "modified"
+
+	if stats, _ := cache.Stats(); stats.DiskBytes != 2 {
+		t.Fatal("Expected cached stats, got", stats.DiskBytes)
+	}
+
+	if counter, _ := cache.CountFresh("bucket"); counter != 3 {
+		t.Fatal("Expected fresh count of 3, got", counter)
+	}
+
+	now = now.Add(time.Minute)
+
+	if counter, _ := cache.Count("bucket"); counter != 3 {
+		t.Fatal("Expected expired cache to recount 3, got", counter)
+	}
+
+	if keys, _ := cache.Keys("bucket"); strings.Join(keys, ",") != "k0,k1,k2" {
+		t.Fatal("Expected expired cache to list the keys again, got", keys)
+	}
+
+	if stats, _ := cache.Stats(); stats.DiskBytes != 3 {
+		t.Fatal("Expected expired cache to report stats again, got", stats.DiskBytes)
+	}
+}
+
+func TestMetadataCacheOption(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	store, err := kvbase.New("leveldb", dir, false, kvbase.WithMetadataCacheTTL(time.Minute), kvbase.WithMetadataCacheClock(func() time.Time {
+		return now
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	cache, ok := store.(*kvbase.MetadataCache)
+	if !ok {
+		t.Fatalf("Expected New to return a *kvbase.MetadataCache, got %T", store)
+	}
+
+	if counter, _ := store.Count("bucket"); counter != 0 {
+		t.Fatal("Expected 0 from counter, got", counter)
+	}
+
+	// Every write goes through the cache, so none of them can leave a stale result behind
+	if err := store.Create("bucket", "k0", &map[string]string{}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if counter, _ := store.Count("bucket"); counter != 1 {
+		t.Fatal("Expected write to invalidate the cached count, got", counter)
+	}
+
+	if err := cache.Backend.Create("bucket", "k1", &map[string]string{}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if counter, _ := store.Count("bucket"); counter != 1 {
+		t.Fatal("Expected cached count of 1, got", counter)
+	}
+
+	now = now.Add(time.Minute)
+
+	if counter, _ := store.Count("bucket"); counter != 2 {
+		t.Fatal("Expected expired cache to recount 2, got", counter)
+	}
+
+	// The wrapped backend's optional interfaces remain reachable through the cache
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := store.(kvbase.BackendCtx).CreateCtx(ctx, "bucket", "k2", &map[string]string{}); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected context.Canceled from a cancelled create, got:", err)
+	}
+
+	if refs, _, err := kvbase.ReadManyInto(store, "bucket", []string{"k0", "k1"}, nil); err != nil || len(refs) != 2 {
+		t.Fatal("Expected ReadManyInto to reach the wrapped backend, got:", refs, err)
+	}
+}
+
+func TestWrapError(t *testing.T) {
+	native := errors.New("leveldb: not found")
+	err := kvbase.WrapError(kvbase.ErrKeyNotFound, native)
+
+	if !errors.Is(err, kvbase.ErrKeyNotFound) {
+		t.Fatal("Expected the wrapped error to match ErrKeyNotFound")
+	}
+
+	if errors.Unwrap(err) != native {
+		t.Fatal("Expected the native error to be reachable, got", errors.Unwrap(err))
+	}
+
+	if errors.Is(err, kvbase.ErrKeyExists) {
+		t.Fatal("Expected the wrapped error to only match its own sentinel")
+	}
+}
+
+func compression(compression kvbase.Compression) *kvbase.Compression {
+	return &compression
+}
+
+// rawCodec stores byte slices as they are
+type rawCodec struct{}
+
+func (rawCodec) Marshal(model interface{}) ([]byte, error) {
+	return model.([]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, model interface{}) error {
+	return errors.New("rawCodec can't decode")
+}
+
+// panicCodec panics on every value except "ok", and on every decode
+type panicCodec struct{}
+
+func (panicCodec) Marshal(model interface{}) ([]byte, error) {
+	if model != "ok" {
+		panic("marshal failure")
+	}
+
+	return []byte(`"ok"`), nil
+}
+
+func (panicCodec) Unmarshal(data []byte, model interface{}) error {
+	panic("unmarshal failure")
+}
+
+// failingReader fails every Read with err
+type failingReader struct {
+	kvbase.Backend
+	err error
+}
+
+func (reader failingReader) Read(bucket string, key string, model interface{}) error {
+	return reader.err
+}
+
+// statsCounter reports the number of times Stats has been called as DiskBytes
+type statsCounter struct {
+	kvbase.Backend
+	calls int64
+}
+
+func (counter *statsCounter) Stats() (*kvbase.StoreStats, error) {
+	counter.calls++
+
+	return &kvbase.StoreStats{DiskBytes: counter.calls}, nil
+}
+
+// failingDelete fails every delete while its error is set
+type failingDelete struct {
+	kvbase.Backend
+	err error
+}
+
+func (store *failingDelete) fail(err error) {
+	store.err = err
+}
+
+func (store *failingDelete) Delete(bucket string, key string) error {
+	if store.err != nil {
+		return store.err
+	}
+
+	return store.Backend.Delete(bucket, key)
+}
+
+// failingTx fails every transaction while its error is set
+type failingTx struct {
+	failingDelete
+}
+
+func (store *failingTx) Tx(fn func(tx kvbase.Transaction) error) error {
+	if store.err != nil {
+		return store.err
+	}
+
+	return kvbase.Tx(store.Backend, fn)
+}
@@ -0,0 +1,383 @@
+package kvbase
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+const maxCachedEntries = 1024
+
+var _ BackendCtx = (*MetadataCache)(nil)
+
+// cacheKey identifies a cached result: the kind of metadata and, except for store-wide Stats, its bucket
+type cacheKey struct {
+	kind   string
+	bucket string
+}
+
+type cachedValue struct {
+	value   interface{}
+	expires time.Time
+}
+
+// MetadataCache wraps a backend, memoizing Count, Keys and Stats results for a short time. Writes made through the
+// cache invalidate the cached results of their bucket, along with the store's Stats, immediately; writes made directly
+// to the wrapped backend are only picked up once the cached result expires. It forwards the wrapped backend's optional
+// interfaces, so that wrapping a store doesn't change which operations the package-level helpers support.
+type MetadataCache struct {
+	Backend
+	entries    map[cacheKey]cachedValue
+	generation uint64
+	mux        sync.Mutex
+	now        func() time.Time
+	ttl        time.Duration
+}
+
+// NewMetadataCache returns a backend serving Count and Keys results for each bucket, and Stats results for the whole
+// store, from memory for up to ttl. Of the provided options, only WithMetadataCacheClock applies.
+func NewMetadataCache(store Backend, ttl time.Duration, opts ...Option) *MetadataCache {
+	options := Options{
+		MetadataCacheClock: time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &MetadataCache{
+		Backend: store,
+		entries: make(map[cacheKey]cachedValue),
+		now:     options.MetadataCacheClock,
+		ttl:     ttl,
+	}
+}
+
+// WithMetadataCacheTTL wraps the store returned by New in a MetadataCache holding results for up to ttl. As every
+// write then goes through the cache, cached results only go stale when other processes write to the same store.
+func WithMetadataCacheTTL(ttl time.Duration) Option {
+	return func(options *Options) {
+		options.MetadataCacheTTL = ttl
+	}
+}
+
+// WithMetadataCacheClock replaces the clock a MetadataCache expires its results with, such as to control it in tests
+func WithMetadataCacheClock(now func() time.Time) Option {
+	return func(options *Options) {
+		options.MetadataCacheClock = now
+	}
+}
+
+// Count returns the total number of records inside of the provided bucket, served from memory when possible
+func (cache *MetadataCache) Count(bucket string) (int, error) {
+	return cache.CountCtx(context.Background(), bucket)
+}
+
+// CountCtx is Count, returning the context's error once ctx is done
+func (cache *MetadataCache) CountCtx(ctx context.Context, bucket string) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	value, err := cache.load(cacheKey{"count", bucket}, func() (interface{}, error) {
+		if store, ok := cache.Backend.(BackendCtx); ok {
+			return store.CountCtx(ctx, bucket)
+		}
+
+		return cache.Backend.Count(bucket)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return value.(int), nil
+}
+
+// CountFresh returns the total number of records inside of the provided bucket, bypassing the cache
+func (cache *MetadataCache) CountFresh(bucket string) (int, error) {
+	return cache.Backend.Count(bucket)
+}
+
+// Create inserts a record into the backend
+func (cache *MetadataCache) Create(bucket string, key string, model interface{}) error {
+	return cache.CreateCtx(context.Background(), bucket, key, model)
+}
+
+// CreateCtx is Create, returning the context's error once ctx is done
+func (cache *MetadataCache) CreateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	defer cache.invalidate(bucket)
+
+	if store, ok := cache.Backend.(BackendCtx); ok {
+		return store.CreateCtx(ctx, bucket, key, model)
+	} else if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return cache.Backend.Create(bucket, key, model)
+}
+
+// CreateBatch inserts every record as one all-or-nothing operation
+func (cache *MetadataCache) CreateBatch(bucket string, records map[string]interface{}) error {
+	defer cache.invalidate(bucket)
+
+	return cache.Backend.CreateBatch(bucket, records)
+}
+
+// Delete removes a record from the backend
+func (cache *MetadataCache) Delete(bucket string, key string) error {
+	return cache.DeleteCtx(context.Background(), bucket, key)
+}
+
+// DeleteCtx is Delete, returning the context's error once ctx is done
+func (cache *MetadataCache) DeleteCtx(ctx context.Context, bucket string, key string) error {
+	defer cache.invalidate(bucket)
+
+	if store, ok := cache.Backend.(BackendCtx); ok {
+		return store.DeleteCtx(ctx, bucket, key)
+	} else if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return cache.Backend.Delete(bucket, key)
+}
+
+// DeleteBatch removes every provided key as one all-or-nothing operation
+func (cache *MetadataCache) DeleteBatch(bucket string, keys []string) error {
+	defer cache.invalidate(bucket)
+
+	return cache.Backend.DeleteBatch(bucket, keys)
+}
+
+// DeletePrefix removes every matching record and invalidates the bucket's cached count
+func (cache *MetadataCache) DeletePrefix(bucket string, prefix string) (int, error) {
+	defer cache.invalidate(bucket)
+
+	return cache.Backend.DeletePrefix(bucket, prefix)
+}
+
+// Drop deletes a bucket (and all of its contents) from the backend
+func (cache *MetadataCache) Drop(bucket string) error {
+	return cache.DropCtx(context.Background(), bucket)
+}
+
+// DropCtx is Drop, returning the context's error once ctx is done
+func (cache *MetadataCache) DropCtx(ctx context.Context, bucket string) error {
+	defer cache.invalidate(bucket)
+
+	if store, ok := cache.Backend.(BackendCtx); ok {
+		return store.DropCtx(ctx, bucket)
+	} else if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return cache.Backend.Drop(bucket)
+}
+
+// FragmentationReport forwards to the wrapped backend's report when it has one
+func (cache *MetadataCache) FragmentationReport(fn func(stats BucketFragmentation) error) (*FragmentationReport, error) {
+	return Fragmentation(cache.Backend, fn)
+}
+
+// GetCtx is Get, returning the context's error once ctx is done
+func (cache *MetadataCache) GetCtx(ctx context.Context, bucket string, model interface{}) (*map[string]interface{}, error) {
+	if store, ok := cache.Backend.(BackendCtx); ok {
+		return store.GetCtx(ctx, bucket, model)
+	} else if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return cache.Backend.Get(bucket, model)
+}
+
+// GetFromBuckets returns the records of every provided bucket, from a single transaction or snapshot when the wrapped
+// backend supports it
+func (cache *MetadataCache) GetFromBuckets(buckets []string, model interface{}) (map[string]map[string]interface{}, error) {
+	return GetFromBuckets(cache.Backend, buckets, model)
+}
+
+// Keys returns the keys of every record inside of the provided bucket in sorted order, served from memory when possible
+func (cache *MetadataCache) Keys(bucket string) ([]string, error) {
+	value, err := cache.load(cacheKey{"keys", bucket}, func() (interface{}, error) {
+		return cache.Backend.Keys(bucket)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers get their own copy, so that modifying it can't corrupt the cached result
+	return append([]string{}, value.([]string)...), nil
+}
+
+// MigrateBucket moves the records an earlier version wrote into bucket to where current versions read them,
+// invalidating every cached result as the legacy records were reported under another bucket
+func (cache *MetadataCache) MigrateBucket(bucket string) (int, error) {
+	defer cache.invalidateAll()
+
+	return MigrateBucket(cache.Backend, bucket)
+}
+
+// ReadCtx is Read, returning the context's error once ctx is done
+func (cache *MetadataCache) ReadCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	if store, ok := cache.Backend.(BackendCtx); ok {
+		return store.ReadCtx(ctx, bucket, key, model)
+	} else if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return cache.Backend.Read(bucket, key, model)
+}
+
+// ReadManyInto forwards to the wrapped backend, returning ErrNotSupported when it can't copy values into an arena
+func (cache *MetadataCache) ReadManyInto(bucket string, keys []string, arena []byte) ([]ValueRef, []byte, error) {
+	return ReadManyInto(cache.Backend, bucket, keys, arena)
+}
+
+// RenameBucket moves every record of oldName into newName and invalidates both buckets' cached counts
+func (cache *MetadataCache) RenameBucket(oldName string, newName string) error {
+	defer cache.invalidate(oldName)
+	defer cache.invalidate(newName)
+
+	return cache.Backend.RenameBucket(oldName, newName)
+}
+
+// Restore loads a stream written by Backup, invalidating every cached result as the restore may write to any bucket
+func (cache *MetadataCache) Restore(r io.Reader, wipe bool) error {
+	defer cache.invalidateAll()
+
+	return cache.Backend.Restore(r, wipe)
+}
+
+// Stats returns the disk usage statistics of the wrapped backend, served from memory when possible
+func (cache *MetadataCache) Stats() (*StoreStats, error) {
+	value, err := cache.load(cacheKey{"stats", ""}, func() (interface{}, error) {
+		return Stats(cache.Backend)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cached := value.(*StoreStats)
+	stats := *cached
+
+	if cached.Driver != nil {
+		stats.Driver = make(map[string]interface{}, len(cached.Driver))
+		for name, figure := range cached.Driver {
+			stats.Driver[name] = figure
+		}
+	}
+
+	return &stats, nil
+}
+
+// Update modifies an existing record from the backend, inside of the provided bucket, using the provided key
+func (cache *MetadataCache) Update(bucket string, key string, model interface{}) error {
+	return cache.UpdateCtx(context.Background(), bucket, key, model)
+}
+
+// UpdateCtx is Update, returning the context's error once ctx is done
+func (cache *MetadataCache) UpdateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	defer cache.invalidate(bucket)
+
+	if store, ok := cache.Backend.(BackendCtx); ok {
+		return store.UpdateCtx(ctx, bucket, key, model)
+	} else if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return cache.Backend.Update(bucket, key, model)
+}
+
+// Upsert inserts or replaces a record, regardless of whether the key already exists
+func (cache *MetadataCache) Upsert(bucket string, key string, model interface{}) error {
+	return cache.UpsertCtx(context.Background(), bucket, key, model)
+}
+
+// UpsertCtx is Upsert, returning the context's error once ctx is done
+func (cache *MetadataCache) UpsertCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	defer cache.invalidate(bucket)
+
+	if store, ok := cache.Backend.(BackendCtx); ok {
+		return store.UpsertCtx(ctx, bucket, key, model)
+	} else if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return cache.Backend.Upsert(bucket, key, model)
+}
+
+// Tx runs fn inside of a single transaction of the wrapped backend, invalidating every cached result afterwards as
+// the transaction may have written to any bucket
+func (cache *MetadataCache) Tx(fn func(tx Transaction) error) error {
+	defer cache.invalidateAll()
+
+	return Tx(cache.Backend, fn)
+}
+
+func (cache *MetadataCache) evict() {
+	now := cache.now()
+
+	for key, cached := range cache.entries {
+		if !now.Before(cached.expires) {
+			delete(cache.entries, key)
+		}
+	}
+
+	if len(cache.entries) >= maxCachedEntries {
+		cache.entries = make(map[cacheKey]cachedValue)
+	}
+}
+
+func (cache *MetadataCache) invalidate(bucket string) {
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+
+	delete(cache.entries, cacheKey{"count", bucket})
+	delete(cache.entries, cacheKey{"keys", bucket})
+	delete(cache.entries, cacheKey{"stats", ""})
+	cache.generation++
+}
+
+func (cache *MetadataCache) invalidateAll() {
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+
+	cache.entries = make(map[cacheKey]cachedValue)
+	cache.generation++
+}
+
+// load returns the cached result for key, calling fetch and caching its result when there is none or it has expired
+func (cache *MetadataCache) load(key cacheKey, fetch func() (interface{}, error)) (interface{}, error) {
+	cache.mux.Lock()
+	cached, ok := cache.entries[key]
+	generation := cache.generation
+	cache.mux.Unlock()
+
+	if ok && cache.now().Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mux.Lock()
+	defer cache.mux.Unlock()
+
+	// A write went through the cache while fetching, so the result may already be stale
+	if cache.generation != generation {
+		return value, nil
+	}
+
+	if len(cache.entries) >= maxCachedEntries {
+		cache.evict()
+	}
+
+	cache.entries[key] = cachedValue{
+		value:   value,
+		expires: cache.now().Add(cache.ttl),
+	}
+
+	return value, nil
+}
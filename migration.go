@@ -0,0 +1,23 @@
+package kvbase
+
+// BucketMigrator is implemented by backends without native buckets, which store bucket names escaped (see
+// kvdriver.Key) where earlier versions stored them as they were
+type BucketMigrator interface {
+	MigrateBucket(bucket string) (int, error)
+}
+
+// MigrateBucket moves the records an earlier version of the provided store wrote into bucket to where current versions
+// read them, returning how many were moved. Only the records of bucket names containing "_" or "%" need moving, and
+// until they are, they're reported under the bucket named by their name up to the first "_". Earlier versions stored
+// bucket "a" with key "b_c" and bucket "a_b" with key "c" under the same key, so migrating "a_b" also moves the
+// records of bucket "a" whose keys start with "b_"; migrate the names the application actually used. Migrating fails
+// with ErrBucketExists once bucket holds records written by a current version. Stores with native buckets never need
+// migrating, and return 0.
+func MigrateBucket(store Backend, bucket string) (int, error) {
+	migrator, ok := store.(BucketMigrator)
+	if !ok {
+		return 0, nil
+	}
+
+	return migrator.MigrateBucket(bucket)
+}
@@ -0,0 +1,79 @@
+package kvbase
+
+import "errors"
+
+// Operation identifies a backend operation
+type Operation int
+
+const (
+	// OpUnknown is the zero value, returned alongside the error of ParseOperation so that it never aliases a real
+	// operation
+	OpUnknown Operation = iota
+	OpCount
+	OpCreate
+	OpDelete
+	OpDrop
+	OpGet
+	OpRead
+	OpUpdate
+	OpUpsert
+	OpDeletePrefix
+	OpRenameBucket
+	OpBackup
+	OpBuckets
+	OpCountPrefix
+	OpCreateBatch
+	OpDeleteBatch
+	OpForEach
+	OpGetPage
+	OpGetPageReverse
+	OpGetPrefix
+	OpGetRange
+	OpKeys
+	OpRestore
+)
+
+var operationNames = map[Operation]string{
+	OpCount:          "count",
+	OpCreate:         "create",
+	OpDelete:         "delete",
+	OpDrop:           "drop",
+	OpGet:            "get",
+	OpRead:           "read",
+	OpUpdate:         "update",
+	OpUpsert:         "upsert",
+	OpDeletePrefix:   "delete_prefix",
+	OpRenameBucket:   "rename_bucket",
+	OpBackup:         "backup",
+	OpBuckets:        "buckets",
+	OpCountPrefix:    "count_prefix",
+	OpCreateBatch:    "create_batch",
+	OpDeleteBatch:    "delete_batch",
+	OpForEach:        "for_each",
+	OpGetPage:        "get_page",
+	OpGetPageReverse: "get_page_reverse",
+	OpGetPrefix:      "get_prefix",
+	OpGetRange:       "get_range",
+	OpKeys:           "keys",
+	OpRestore:        "restore",
+}
+
+// String returns the lowercase name of the operation
+func (op Operation) String() string {
+	if name, ok := operationNames[op]; ok {
+		return name
+	}
+
+	return "unknown"
+}
+
+// ParseOperation returns the operation matching the provided name
+func ParseOperation(name string) (Operation, error) {
+	for op, opName := range operationNames {
+		if opName == name {
+			return op, nil
+		}
+	}
+
+	return OpUnknown, errors.New("kvbase: unknown operation " + name)
+}
@@ -1,11 +1,19 @@
 package kvbaseBackendTest
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/Wolveix/kvbase"
 	_ "github.com/Wolveix/kvbase/backend/badgerdb"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 type model struct {
@@ -21,6 +29,12 @@ var (
 )
 
 func reset(backend string, source string, memory bool) {
+	if store != nil {
+		if err = store.Close(); err != nil {
+			panic(err)
+		}
+	}
+
 	if !memory {
 		if err = os.RemoveAll(source); err != nil {
 			panic(err)
@@ -33,41 +47,150 @@ func reset(backend string, source string, memory bool) {
 }
 
 func RunTests(t *testing.T, backend string, source string, memory bool) {
+	t.Run(backend+"_Backup", func(t *testing.T) {
+		reset(backend, source, memory)
+		testBackup(t)
+	})
+
+	t.Run(backend+"_BucketIsolation", func(t *testing.T) {
+		reset(backend, source, memory)
+		testBucketIsolation(t)
+	})
+
+	t.Run(backend+"_Buckets", func(t *testing.T) {
+		reset(backend, source, memory)
+		testBuckets(t)
+	})
+
+	t.Run(backend+"_Close", func(t *testing.T) {
+		reset(backend, source, memory)
+		testClose(t, backend, source, memory)
+	})
+
+	t.Run(backend+"_Codec", func(t *testing.T) {
+		reset(backend, source, memory)
+		testCodec(t, backend, source, memory)
+	})
+
+	t.Run(backend+"_Context", func(t *testing.T) {
+		reset(backend, source, memory)
+		testContext(t)
+	})
+
 	t.Run(backend+"_Count", func(t *testing.T) {
 		reset(backend, source, memory)
 		testCount(t)
 	})
 
+	t.Run(backend+"_CountPrefix", func(t *testing.T) {
+		reset(backend, source, memory)
+		testCountPrefix(t)
+	})
+
 	t.Run(backend+"_Create", func(t *testing.T) {
 		reset(backend, source, memory)
 		testCreate(t)
 	})
 
+	t.Run(backend+"_CreateBatch", func(t *testing.T) {
+		reset(backend, source, memory)
+		testCreateBatch(t)
+	})
+
 	t.Run(backend+"_Delete", func(t *testing.T) {
 		reset(backend, source, memory)
 		testDelete(t)
 	})
 
+	t.Run(backend+"_DeleteBatch", func(t *testing.T) {
+		reset(backend, source, memory)
+		testDeleteBatch(t)
+	})
+
+	t.Run(backend+"_DeletePrefix", func(t *testing.T) {
+		reset(backend, source, memory)
+		testDeletePrefix(t)
+	})
+
 	t.Run(backend+"_Drop", func(t *testing.T) {
 		reset(backend, source, memory)
 		testDrop(t)
 	})
 
+	t.Run(backend+"_EmptyBucket", func(t *testing.T) {
+		reset(backend, source, memory)
+		testEmptyBucket(t)
+	})
+
+	t.Run(backend+"_ForEach", func(t *testing.T) {
+		reset(backend, source, memory)
+		testForEach(t)
+	})
+
 	t.Run(backend+"_Get", func(t *testing.T) {
 		reset(backend, source, memory)
 		testGet(t)
 	})
 
+	t.Run(backend+"_GetPage", func(t *testing.T) {
+		reset(backend, source, memory)
+		testGetPage(t)
+	})
+
+	t.Run(backend+"_GetPageReverse", func(t *testing.T) {
+		reset(backend, source, memory)
+		testGetPageReverse(t)
+	})
+
+	t.Run(backend+"_GetPrefix", func(t *testing.T) {
+		reset(backend, source, memory)
+		testGetPrefix(t)
+	})
+
+	t.Run(backend+"_GetRange", func(t *testing.T) {
+		reset(backend, source, memory)
+		testGetRange(t)
+	})
+
+	t.Run(backend+"_Keys", func(t *testing.T) {
+		reset(backend, source, memory)
+		testKeys(t)
+	})
+
+	t.Run(backend+"_MigrateBucket", func(t *testing.T) {
+		reset(backend, source, memory)
+		testMigrateBucket(t)
+	})
+
 	t.Run(backend+"_Read", func(t *testing.T) {
 		reset(backend, source, memory)
 		testRead(t)
 	})
 
+	t.Run(backend+"_RenameBucket", func(t *testing.T) {
+		reset(backend, source, memory)
+		testRenameBucket(t)
+	})
+
+	t.Run(backend+"_Tx", func(t *testing.T) {
+		reset(backend, source, memory)
+		testTx(t)
+	})
+
 	t.Run(backend+"_Update", func(t *testing.T) {
 		reset(backend, source, memory)
 		testUpdate(t)
 	})
 
+	t.Run(backend+"_Upsert", func(t *testing.T) {
+		reset(backend, source, memory)
+		testUpsert(t)
+	})
+
+	if err = store.Close(); err != nil {
+		panic(err)
+	}
+
 	if !memory {
 		if err = os.RemoveAll(source); err != nil {
 			panic(err)
@@ -86,148 +209,1169 @@ func RunBenches(b *testing.B, backend string, source string, memory bool) {
 		benchmarkCreate(b)
 	})
 
-	b.Run(backend+"_Delete", func(b *testing.B) {
-		reset(backend, source, memory)
-		benchmarkDelete(b)
-	})
+	b.Run(backend+"_CreateBatch", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkCreateBatch(b)
+	})
+
+	b.Run(backend+"_Delete", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkDelete(b)
+	})
+
+	b.Run(backend+"_Drop", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkDrop(b)
+	})
+
+	b.Run(backend+"_ForEach", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkForEach(b)
+	})
+
+	b.Run(backend+"_Get", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkGet(b)
+	})
+
+	b.Run(backend+"_GetPage", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkGetPage(b)
+	})
+
+	b.Run(backend+"_GetPageReverse", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkGetPageReverse(b)
+	})
+
+	b.Run(backend+"_GetPrefix", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkGetPrefix(b)
+	})
+
+	b.Run(backend+"_GetRange", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkGetRange(b)
+	})
+
+	b.Run(backend+"_Keys", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkKeys(b)
+	})
+
+	b.Run(backend+"_Read", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkRead(b)
+	})
+
+	b.Run(backend+"_Update", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkUpdate(b)
+	})
+
+	b.Run(backend+"_Upsert", func(b *testing.B) {
+		reset(backend, source, memory)
+		benchmarkUpsert(b)
+	})
+
+	if err = store.Close(); err != nil {
+		panic(err)
+	}
+
+	if !memory {
+		if err = os.RemoveAll(source); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func testBackup(t *testing.T) {
+	createPrefixFixture(t)
+
+	var buffer bytes.Buffer
+	if err := store.Backup(&buffer); err != nil {
+		t.Fatal("Error on backup:", err)
+	}
+
+	backup := buffer.Bytes()
+
+	if err := store.Create("extra", "key", &exampleModel); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	corrupted := append([]byte{}, backup...)
+	corrupted[len(corrupted)/2] ^= 0xFF
+
+	for _, stream := range [][]byte{backup[:len(backup)-1], corrupted, []byte("not a backup")} {
+		if err := store.Restore(bytes.NewReader(stream), true); !errors.Is(err, kvbase.ErrCorruptBackup) {
+			t.Fatal("Expected ErrCorruptBackup, got:", err)
+		}
+	}
+
+	if counter, _ := store.Count("extra"); counter != 1 {
+		t.Fatal("Expected a corrupt stream to leave the store untouched")
+	}
+
+	if err := store.Restore(bytes.NewReader(backup), false); err != nil {
+		t.Fatal("Error on restore:", err)
+	}
+
+	if counter, _ := store.Count("extra"); counter != 1 {
+		t.Fatal("Expected records missing from the backup to be kept without wipe")
+	}
+
+	if err := store.Restore(bytes.NewReader(backup), true); err != nil {
+		t.Fatal("Error on restore:", err)
+	}
+
+	if counter, _ := store.Count("extra"); counter != 0 {
+		t.Fatal("Expected existing buckets to be dropped with wipe, got:", counter)
+	}
+
+	keys, err := store.Keys("bucket")
+	if err != nil {
+		t.Fatal("Error on record keys:", err)
+	}
+
+	if strings.Join(keys, ",") != "other,user:12:order:1,user:1:order:1,user:1:order:2,user_1" {
+		t.Fatal("Expected every record to be restored, got:", keys)
+	}
+
+	record := model{}
+	if err := store.Read("bucket2", "user:1:order:3", &record); err != nil || record != exampleModel {
+		t.Fatal("Expected the record to be restored intact, got:", record, err)
+	}
+
+	buffer.Reset()
+	if err := store.Backup(&buffer); err != nil {
+		t.Fatal("Error on backup:", err)
+	}
+
+	if !bytes.Equal(buffer.Bytes(), backup) {
+		t.Fatal("Expected a backup of the restored store to match the original")
+	}
+}
+
+func testBucketIsolation(t *testing.T) {
+	for _, key := range []string{"b_c", "x"} {
+		if err := store.Create("a", key, &exampleModel); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	// "a" + "b_c" and "a_b" + "c" must remain distinct records, as must buckets differing by their escaping
+	for _, bucket := range []string{"a_b", "a%5Fb", "a%"} {
+		if err := store.Create(bucket, "c", &exampleModel); err != nil {
+			t.Fatal("Error on record creation in bucket", bucket+":", err)
+		}
+	}
+
+	if buckets, err := store.Buckets(); err != nil || strings.Join(buckets, ",") != "a,a%,a%5Fb,a_b" {
+		t.Fatal("Expected buckets a, a%, a%5Fb and a_b, got:", buckets, err)
+	}
+
+	if keys, err := store.Keys("a"); err != nil || strings.Join(keys, ",") != "b_c,x" {
+		t.Fatal("Expected only the keys of bucket a, got:", keys, err)
+	}
+
+	if results, err := store.GetPrefix("a", "b", nil); err != nil || len(*results) != 1 || (*results)["b_c"] == nil {
+		t.Fatal("Expected only b_c from bucket a, got:", results, err)
+	}
+
+	if counter, err := store.CountPrefix("a_b", ""); err != nil || counter != 1 {
+		t.Fatal("Expected only the record of bucket a_b to be counted, got:", counter, err)
+	}
+
+	if counter, err := store.DeletePrefix("a", ""); err != nil || counter != 2 {
+		t.Fatal("Expected only the records of bucket a to be deleted, got:", counter, err)
+	}
+
+	for _, bucket := range []string{"a_b", "a%5Fb", "a%"} {
+		if counter, err := store.Count(bucket); err != nil || counter != 1 {
+			t.Fatal("Expected bucket", bucket, "to keep its record, got:", counter, err)
+		}
+	}
+
+	if err := store.Drop("a_b"); err != nil {
+		t.Fatal("Error on bucket drop:", err)
+	}
+
+	if counter, err := store.Count("a%5Fb"); err != nil || counter != 1 {
+		t.Fatal("Expected bucket a%5Fb to keep its record, got:", counter, err)
+	}
+}
+
+func testBuckets(t *testing.T) {
+	buckets, err := store.Buckets()
+	if err != nil {
+		t.Fatal("Error on bucket listing:", err)
+	}
+
+	if buckets == nil || len(buckets) != 0 {
+		t.Fatal("Expected an empty slice for an empty store, got:", buckets)
+	}
+
+	createPrefixFixture(t)
+
+	if err := store.Create("another", "key", &exampleModel); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if buckets, err = store.Buckets(); err != nil {
+		t.Fatal("Error on bucket listing:", err)
+	}
+
+	if strings.Join(buckets, ",") != "another,bucket,bucket2" {
+		t.Fatal("Expected every bucket once in sorted order, got:", buckets)
+	}
+
+	if err := store.Drop("bucket"); err != nil {
+		t.Fatal("Error on bucket drop:", err)
+	}
+
+	if buckets, err = store.Buckets(); err != nil {
+		t.Fatal("Error on bucket listing:", err)
+	}
+
+	if strings.Join(buckets, ",") != "another,bucket2" {
+		t.Fatal("Expected the dropped bucket to be gone, got:", buckets)
+	}
+}
+
+func testClose(t *testing.T, backend string, source string, memory bool) {
+	if err := store.Create("bucket", "key", &exampleModel); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal("Error on close:", err)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal("Expected double close to be a no-op, got:", err)
+	}
+
+	if _, err := store.Count("bucket"); !errors.Is(err, kvbase.ErrClosed) {
+		t.Fatal("Expected ErrClosed from count, got:", err)
+	}
+
+	if err := store.Create("bucket", "other", &exampleModel); !errors.Is(err, kvbase.ErrClosed) {
+		t.Fatal("Expected ErrClosed from create, got:", err)
+	}
+
+	if _, err := store.Get("bucket", model{}); !errors.Is(err, kvbase.ErrClosed) {
+		t.Fatal("Expected ErrClosed from get, got:", err)
+	}
+
+	if err := store.Read("bucket", "key", &model{}); !errors.Is(err, kvbase.ErrClosed) {
+		t.Fatal("Expected ErrClosed from read, got:", err)
+	}
+
+	if store, err = kvbase.New(backend, source, memory); err != nil {
+		t.Fatal("Error on reopen:", err)
+	}
+
+	if memory {
+		return
+	}
+
+	emptyModel := model{}
+	if err := store.Read("bucket", "key", &emptyModel); err != nil {
+		t.Fatal("Error on store read after reopen:", err)
+	}
+
+	if emptyModel.Name != "John Smith" {
+		t.Fatal("Expected John Smith after reopen, got:", emptyModel.Name)
+	}
+}
+
+func testCodec(t *testing.T, backend string, source string, memory bool) {
+	if err := store.Close(); err != nil {
+		t.Fatal("Error on store close:", err)
+	}
+
+	if store, err = kvbase.New(backend, source, memory, kvbase.WithCodec(kvbase.GobCodec{})); err != nil {
+		t.Fatal("Error on store open with the gob codec:", err)
+	}
+
+	type nested struct {
+		Created time.Time
+		Data    []byte
+		Inner   model
+	}
+
+	original := nested{time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC), []byte{0, 1, 2}, exampleModel}
+
+	if err := store.Create("bucket", "key", &original); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if err := store.CreateBatch("bucket", map[string]interface{}{"batch": &original}); err != nil {
+		t.Fatal("Error on batch creation:", err)
+	}
+
+	decoded := nested{}
+	if err := store.Read("bucket", "key", &decoded); err != nil {
+		t.Fatal("Error on record read:", err)
+	}
+
+	if !decoded.Created.Equal(original.Created) || string(decoded.Data) != string(original.Data) || decoded.Inner != original.Inner {
+		t.Fatal("Expected the record to round-trip through the gob codec, got:", decoded)
+	}
+
+	results, err := store.Get("bucket", &nested{})
+	if err != nil {
+		t.Fatal("Error on record get:", err)
+	}
+
+	if record, ok := (*results)["batch"].(*nested); !ok || record.Inner != original.Inner {
+		t.Fatal("Expected batch records to be decoded through the gob codec, got:", (*results)["batch"])
+	}
+
+	var raw json.RawMessage
+	if err := store.Read("bucket", "key", &raw); err == nil && json.Valid(raw) {
+		t.Fatal("Expected the record not to be stored as JSON, got:", string(raw))
+	}
+}
+
+func testContext(t *testing.T) {
+	ctxStore, ok := store.(kvbase.BackendCtx)
+	if !ok {
+		t.Fatal("Expected the backend to implement kvbase.BackendCtx")
+	}
+
+	for i := 0; i < 10; i++ {
+		if err := ctxStore.CreateCtx(context.Background(), "bucket", strconv.Itoa(i), &exampleModel); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := ctxStore.CreateCtx(cancelled, "bucket", "cancelled", &exampleModel); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected context.Canceled on record creation, got:", err)
+	}
+
+	if err := ctxStore.ReadCtx(cancelled, "bucket", "0", &model{}); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected context.Canceled on record read, got:", err)
+	}
+
+	if err := ctxStore.DropCtx(cancelled, "bucket"); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected context.Canceled on bucket drop, got:", err)
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	if _, err := ctxStore.CountCtx(expired, "bucket"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("Expected context.DeadlineExceeded on record count, got:", err)
+	}
+
+	// Iterations check the context between records, so cancelling partway through a bucket stops them
+	if _, err := ctxStore.GetCtx(&countdownContext{Context: context.Background(), remaining: 3}, "bucket", &model{}); !errors.Is(err, context.Canceled) {
+		t.Fatal("Expected context.Canceled partway through record get, got:", err)
+	}
+
+	if counter, err := store.Count("bucket"); err != nil || counter != 10 {
+		t.Fatal("Expected cancelled operations to leave the 10 records in place, got:", counter, err)
+	}
+
+	results, err := ctxStore.GetCtx(context.Background(), "bucket", &model{})
+	if err != nil {
+		t.Fatal("Error on record get:", err)
+	}
+
+	if len(*results) != 10 {
+		t.Fatal("Expected 10 records, got:", len(*results))
+	}
+}
+
+func testCount(t *testing.T) {
+	if err := store.Create("bucket", "key", &exampleModel); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	counter, err := store.Count("bucket")
+	if err != nil {
+		t.Fatal("Error on record count:", err)
+	}
+
+	if counter != 1 {
+		t.Fatal("Expected 1 from counter, got", counter)
+	}
+}
+
+func testCountPrefix(t *testing.T) {
+	createPrefixFixture(t)
+
+	for prefix, expected := range map[string]int{"": 5, "user:1": 3, "user:1:": 2, "user_": 1, "missing": 0} {
+		counter, err := store.CountPrefix("bucket", prefix)
+		if err != nil {
+			t.Fatal("Error on prefix count:", err)
+		}
+
+		if counter != expected {
+			t.Fatalf("Expected %d from counter for prefix %q, got: %d", expected, prefix, counter)
+		}
+	}
+}
+
+func testCreate(t *testing.T) {
+	if err := store.Create("bucket", "key", &exampleModel); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if err := store.Create("bucket", "key", &exampleModel); !errors.Is(err, kvbase.ErrKeyExists) {
+		t.Fatal("Expected ErrKeyExists for existing key, got:", err)
+	}
+}
+
+func testCreateBatch(t *testing.T) {
+	if err := store.Create("bucket", "k1", &exampleModel); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	err := store.CreateBatch("bucket", map[string]interface{}{
+		"k0": &exampleModel,
+		"k1": &exampleModel,
+		"k2": &exampleModel,
+	})
+
+	var batchErr *kvbase.BatchError
+	if !errors.Is(err, kvbase.ErrKeyExists) || !errors.As(err, &batchErr) || batchErr.Key != "k1" {
+		t.Fatal("Expected a batch error for existing key k1, got:", err)
+	}
+
+	if err := store.CreateBatch("bucket", map[string]interface{}{"k0": &exampleModel, "k2": make(chan int)}); !errors.As(err, &batchErr) || batchErr.Key != "k2" {
+		t.Fatal("Expected a batch error for unmarshallable key k2, got:", err)
+	}
+
+	if counter, err := store.Count("bucket"); err != nil || counter != 1 {
+		t.Fatal("Expected rejected batches to write nothing, got", counter, err)
+	}
+
+	if err := store.CreateBatch("bucket", map[string]interface{}{"k0": &exampleModel, "k2": &exampleModel}); err != nil {
+		t.Fatal("Error on batch creation:", err)
+	}
+
+	if counter, err := store.Count("bucket"); err != nil || counter != 3 {
+		t.Fatal("Expected 3 from counter, got", counter, err)
+	}
+}
+
+func testDelete(t *testing.T) {
+	if err := store.Create("bucket", "key", &exampleModel); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if err := store.Delete("bucket", "key"); err != nil {
+		t.Fatal("Error on record deletion:", err)
+	}
+
+	if err := store.Delete("bucket", "key"); !errors.Is(err, kvbase.ErrKeyNotFound) {
+		t.Fatal("Expected ErrKeyNotFound for missing key, got:", err)
+	}
+}
+
+func testDeleteBatch(t *testing.T) {
+	for _, key := range []string{"k0", "k1", "k2"} {
+		if err := store.Create("bucket", key, &exampleModel); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	err := store.DeleteBatch("bucket", []string{"k0", "missing"})
+
+	var batchErr *kvbase.BatchError
+	if !errors.Is(err, kvbase.ErrKeyNotFound) || !errors.As(err, &batchErr) || batchErr.Key != "missing" {
+		t.Fatal("Expected a batch error for missing key, got:", err)
+	}
+
+	if counter, err := store.Count("bucket"); err != nil || counter != 3 {
+		t.Fatal("Expected a rejected batch to delete nothing, got", counter, err)
+	}
+
+	if err := store.DeleteBatch("bucket", []string{"k0", "k1"}); err != nil {
+		t.Fatal("Error on batch deletion:", err)
+	}
+
+	if counter, err := store.Count("bucket"); err != nil || counter != 1 {
+		t.Fatal("Expected 1 from counter, got", counter, err)
+	}
+}
+
+func testDeletePrefix(t *testing.T) {
+	createPrefixFixture(t)
+
+	removed, err := store.DeletePrefix("bucket", "user:1:")
+	if err != nil {
+		t.Fatal("Error on prefix delete:", err)
+	}
+
+	if removed != 2 {
+		t.Fatal("Expected 2 records to be removed, got:", removed)
+	}
+
+	keys, err := store.Keys("bucket")
+	if err != nil {
+		t.Fatal("Error on record keys:", err)
+	}
+
+	if strings.Join(keys, ",") != "other,user:12:order:1,user_1" {
+		t.Fatal("Expected only the matching records to be removed, got:", keys)
+	}
+
+	if counter, _ := store.Count("bucket2"); counter != 1 {
+		t.Fatal("Expected other buckets to be left untouched, got:", counter)
+	}
+
+	if removed, err = store.DeletePrefix("bucket", "user:1:"); err != nil || removed != 0 {
+		t.Fatal("Expected nothing left to remove, got:", removed, err)
+	}
+}
+
+func testDrop(t *testing.T) {
+	newModel := exampleModel
+	newModel.Name = "Updated John Smith"
+
+	if err := store.Create("bucket", "k0", &exampleModel); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if err := store.Create("bucket", "k1", &newModel); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if err := store.Drop("bucket"); err != nil {
+		t.Fatal("Error on bucket drop:", err)
+	}
+
+	if err := store.Read("bucket", "k0", &model{}); !errors.Is(err, kvbase.ErrKeyNotFound) {
+		t.Fatal("Expected ErrKeyNotFound for missing key, got:", err)
+	}
+
+	if err := store.Read("bucket", "k1", &model{}); !errors.Is(err, kvbase.ErrKeyNotFound) {
+		t.Fatal("Expected ErrKeyNotFound for missing key, got:", err)
+	}
+}
+
+func testEmptyBucket(t *testing.T) {
+	if err := store.Create("bucket", "k0", &exampleModel); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	// Backends may refuse an empty bucket name, but it must never address the whole store
+	_ = store.Drop("")
+
+	if err := store.Read("bucket", "k0", &model{}); err != nil {
+		t.Fatal("Expected dropping the empty bucket to leave other buckets intact, got:", err)
+	}
+
+	if results, err := store.Get("", &model{}); err == nil && len(*results) != 0 {
+		t.Fatal("Expected no records in the empty bucket, got:", *results)
+	}
+
+	if counter, err := store.Count(""); err == nil && counter != 0 {
+		t.Fatal("Expected no records in the empty bucket, got:", counter)
+	}
+}
+
+func testForEach(t *testing.T) {
+	record := model{}
+
+	if err := store.ForEach("bucket", &record, func(key string) error {
+		t.Fatal("Expected no records in an empty bucket, got:", key)
+		return nil
+	}); err != nil {
+		t.Fatal("Error on empty bucket iteration:", err)
+	}
+
+	for _, key := range []string{"keyC", "keyA", "keyB"} {
+		if err := store.Create("bucket", key, &model{key}); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	var seen []string
+	if err := store.ForEach("bucket", &record, func(key string) error {
+		if record.Name != key {
+			t.Fatal("Expected the model to hold the record for", key, "got:", record.Name)
+		}
+
+		seen = append(seen, key)
+
+		return nil
+	}); err != nil {
+		t.Fatal("Error on record iteration:", err)
+	}
+
+	if strings.Join(seen, ",") != "keyA,keyB,keyC" {
+		t.Fatal("Expected every key in sorted order, got:", seen)
+	}
+
+	// Models are reset between records, so fields never carry over from the previous one
+	fields := map[string]interface{}{}
+	if err := store.Upsert("bucket", "keyB", &map[string]string{"Other": "value"}); err != nil {
+		t.Fatal("Error on record upsert:", err)
+	}
+
+	seen = nil
+	if err := store.ForEach("bucket", &fields, func(key string) error {
+		seen = append(seen, key)
+
+		if len(seen) == 2 {
+			if _, ok := fields["Name"]; ok || len(fields) != 1 {
+				t.Fatal("Expected only the fields of keyB, got:", fields)
+			}
+
+			return kvbase.ErrStopIteration
+		}
+
+		return nil
+	}); err != nil {
+		t.Fatal("Expected ErrStopIteration to stop without an error, got:", err)
+	}
+
+	if len(seen) != 2 {
+		t.Fatal("Expected iteration to stop after 2 records, got:", seen)
+	}
+
+	stop := errors.New("stop")
+	if err := store.ForEach("bucket", &record, func(key string) error {
+		return stop
+	}); err != stop {
+		t.Fatal("Expected the callback's error to be returned, got:", err)
+	}
+
+	if err := store.ForEach("bucket", record, func(key string) error {
+		return nil
+	}); err == nil {
+		t.Fatal("Expected an error for a non-pointer model")
+	}
+
+	// A panicking callback is returned as an error, leaving the store usable
+	for i := 0; i < 2; i++ {
+		if err := store.ForEach("bucket", &record, func(key string) error {
+			panic("callback failure")
+		}); !errors.Is(err, kvbase.ErrCallbackPanic) {
+			t.Fatal("Expected ErrCallbackPanic, got:", err)
+		}
+	}
+
+	if err := store.Upsert("bucket", "keyD", &model{"keyD"}); err != nil {
+		t.Fatal("Expected the store to remain writable after a panic, got:", err)
+	}
+
+	if counter, err := store.Count("bucket"); err != nil || counter != 4 {
+		t.Fatal("Expected 4 records after a panic, got:", counter, err)
+	}
+}
+
+func testGet(t *testing.T) {
+	kO := model{
+		"John Smith",
+	}
+
+	k1 := model{
+		"James Green",
+	}
+
+	if err := store.Create("bucket", "keyOne", &kO); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if err := store.Create("bucket", "keyTwo", &k1); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	results, err := store.Get("bucket", model{})
+	if err != nil {
+		t.Fatal("Error on record get:", err)
+	}
+
+	for key, value := range *results {
+		if key != "keyOne" && key != "keyTwo" {
+			t.Fatal("Expected keyOne or keyTwo, got:", key)
+		}
+
+		if value == nil {
+			t.Fatal("Expected non-nil value, got:", value)
+		}
+	}
+
+	// Every record must be unmarshalled into its own instance of a pointer model, rather than into the model itself
+	if err := store.Create("bucket", "keyThree", &model{"Jane Doe"}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if results, err = store.Get("bucket", &model{}); err != nil {
+		t.Fatal("Error on record get:", err)
+	}
+
+	names := make(map[string]bool)
+
+	for key, value := range *results {
+		record, ok := value.(*model)
+		if !ok {
+			t.Fatal("Expected a *model for "+key+", got:", value)
+		}
+
+		names[record.Name] = true
+	}
+
+	if len(names) != 3 || !names["John Smith"] || !names["James Green"] || !names["Jane Doe"] {
+		t.Fatal("Expected three distinct records, got:", names)
+	}
+}
+
+func testGetPage(t *testing.T) {
+	results, cursor, err := store.GetPage("bucket", nil, 2, "")
+	if err != nil {
+		t.Fatal("Error on empty bucket page:", err)
+	}
+
+	if len(*results) != 0 || cursor != "" {
+		t.Fatal("Expected an empty final page for an empty bucket, got:", *results, cursor)
+	}
+
+	for _, key := range []string{"keyE", "keyC", "keyA", "keyD", "keyB"} {
+		if err := store.Create("bucket", key, &exampleModel); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	var seen []string
+	for page := 0; ; page++ {
+		if page > 3 {
+			t.Fatal("Expected paging to finish within 3 pages, got cursor:", cursor)
+		}
+
+		if results, cursor, err = store.GetPage("bucket", nil, 2, cursor); err != nil {
+			t.Fatal("Error on record page:", err)
+		}
+
+		if len(*results) > 2 {
+			t.Fatal("Expected at most 2 records per page, got:", len(*results))
+		}
+
+		keys := make([]string, 0, len(*results))
+		for key := range *results {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+		seen = append(seen, keys...)
+
+		if cursor == "" {
+			break
+		}
+
+		if cursor != keys[len(keys)-1] {
+			t.Fatal("Expected the cursor to be the last key of the page, got:", cursor)
+		}
+
+		// Writes made between pages must not cause keys to be repeated or skipped
+		if page == 0 {
+			if err := store.Create("bucket", "keyC0", &exampleModel); err != nil {
+				t.Fatal("Error on record creation:", err)
+			}
+		}
+	}
+
+	if strings.Join(seen, ",") != "keyA,keyB,keyC,keyC0,keyD,keyE" {
+		t.Fatal("Expected every key exactly once in sorted order, got:", seen)
+	}
+
+	results, cursor, err = store.GetPage("bucket", &exampleModel, 0, "keyC")
+	if err != nil {
+		t.Fatal("Error on record page:", err)
+	}
+
+	if len(*results) != 3 || cursor != "" {
+		t.Fatal("Expected the remaining 3 records without a cursor, got:", *results, cursor)
+	}
+
+	if _, ok := (*results)["keyD"].(*model); !ok {
+		t.Fatal("Expected records to be decoded into the provided model, got:", (*results)["keyD"])
+	}
+}
+
+func testGetPageReverse(t *testing.T) {
+	results, cursor, err := store.GetPageReverse("bucket", nil, 2, "")
+	if err != nil {
+		t.Fatal("Error on empty bucket page:", err)
+	}
+
+	if len(*results) != 0 || cursor != "" {
+		t.Fatal("Expected an empty final page for an empty bucket, got:", *results, cursor)
+	}
+
+	for _, key := range []string{"keyE", "keyC", "keyA", "keyD", "keyB"} {
+		if err := store.Create("bucket", key, &exampleModel); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	if err := store.Create("bucket2", "keyF", &exampleModel); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	var pages []string
+	for page := 0; ; page++ {
+		if page > 3 {
+			t.Fatal("Expected paging to finish within 3 pages, got cursor:", cursor)
+		}
+
+		if results, cursor, err = store.GetPageReverse("bucket", nil, 2, cursor); err != nil {
+			t.Fatal("Error on record page:", err)
+		}
+
+		keys := make([]string, 0, len(*results))
+		for key := range *results {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+		pages = append(pages, strings.Join(keys, ","))
+
+		if cursor == "" {
+			break
+		}
+
+		if cursor != keys[0] {
+			t.Fatal("Expected the cursor to be the lowest key of the page, got:", cursor)
+		}
+	}
+
+	if strings.Join(pages, " ") != "keyD,keyE keyB,keyC keyA" {
+		t.Fatal("Expected pages from the highest key down, got:", pages)
+	}
+
+	// A forward page's first key steps back to the previous page
+	if results, _, err = store.GetPageReverse("bucket", &exampleModel, 2, "keyC"); err != nil {
+		t.Fatal("Error on record page:", err)
+	}
+
+	if len(*results) != 2 || (*results)["keyA"] == nil || (*results)["keyB"] == nil {
+		t.Fatal("Expected keyA and keyB before keyC, got:", *results)
+	}
+
+	if _, ok := (*results)["keyA"].(*model); !ok {
+		t.Fatal("Expected records to be decoded into the provided model, got:", (*results)["keyA"])
+	}
+
+	if results, cursor, err = store.GetPageReverse("bucket", nil, 0, "keyZ"); err != nil {
+		t.Fatal("Error on record page:", err)
+	}
+
+	if len(*results) != 5 || cursor != "" {
+		t.Fatal("Expected every record without a cursor, got:", *results, cursor)
+	}
+}
+
+func testGetPrefix(t *testing.T) {
+	createPrefixFixture(t)
+
+	results, err := store.GetPrefix("bucket", "user:1:", &exampleModel)
+	if err != nil {
+		t.Fatal("Error on prefix get:", err)
+	}
+
+	if len(*results) != 2 || (*results)["user:1:order:1"] == nil || (*results)["user:1:order:2"] == nil {
+		t.Fatal("Expected the 2 records under user:1:, got:", *results)
+	}
+
+	if _, ok := (*results)["user:1:order:1"].(*model); !ok {
+		t.Fatal("Expected records to be decoded into the provided model, got:", (*results)["user:1:order:1"])
+	}
+
+	// A key prefix containing the bucket separator must match keys, not neighbouring buckets
+	if results, err = store.GetPrefix("bucket", "user_", nil); err != nil {
+		t.Fatal("Error on prefix get:", err)
+	}
+
+	if len(*results) != 1 || (*results)["user_1"] == nil {
+		t.Fatal("Expected only user_1, got:", *results)
+	}
+
+	if results, err = store.GetPrefix("missing", "user", nil); err != nil {
+		t.Fatal("Error on prefix get of a missing bucket:", err)
+	}
+
+	if len(*results) != 0 {
+		t.Fatal("Expected no records, got:", *results)
+	}
+}
+
+func testGetRange(t *testing.T) {
+	for _, key := range []string{"2020-01-01T00:00:00Z", "2020-01-15T00:00:00Z", "2020-02-01T00:00:00Z", "2020-03-01T00:00:00Z"} {
+		if err := store.Create("bucket", key, &exampleModel); err != nil {
+			t.Fatal("Error on record creation:", err)
+		}
+	}
+
+	if err := store.Create("bucket2", "2020-01-20T00:00:00Z", &exampleModel); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	ranges := []struct {
+		startKey string
+		endKey   string
+		expected string
+	}{
+		{"2020-01-01T00:00:00Z", "2020-02-01T00:00:00Z", "2020-01-01T00:00:00Z,2020-01-15T00:00:00Z"},
+		{"2020-01-10", "2020-02-01T00:00:00Z", "2020-01-15T00:00:00Z"},
+		{"", "2020-01-15T00:00:00Z", "2020-01-01T00:00:00Z"},
+		{"2020-02-01T00:00:00Z", "", "2020-02-01T00:00:00Z,2020-03-01T00:00:00Z"},
+		{"", "", "2020-01-01T00:00:00Z,2020-01-15T00:00:00Z,2020-02-01T00:00:00Z,2020-03-01T00:00:00Z"},
+		{"2020-03-01T00:00:00Z", "2020-01-01T00:00:00Z", ""},
+	}
+
+	for _, r := range ranges {
+		results, err := store.GetRange("bucket", r.startKey, r.endKey, &exampleModel)
+		if err != nil {
+			t.Fatal("Error on range get:", err)
+		}
+
+		keys := make([]string, 0, len(*results))
+		for key, value := range *results {
+			if _, ok := value.(*model); !ok {
+				t.Fatal("Expected records to be decoded into the provided model, got:", value)
+			}
 
-	b.Run(backend+"_Drop", func(b *testing.B) {
-		reset(backend, source, memory)
-		benchmarkDrop(b)
-	})
+			keys = append(keys, key)
+		}
 
-	b.Run(backend+"_Get", func(b *testing.B) {
-		reset(backend, source, memory)
-		benchmarkGet(b)
-	})
+		sort.Strings(keys)
 
-	b.Run(backend+"_Read", func(b *testing.B) {
-		reset(backend, source, memory)
-		benchmarkRead(b)
-	})
+		if actual := strings.Join(keys, ","); actual != r.expected {
+			t.Fatalf("Expected [%s] for range %q to %q, got: [%s]", r.expected, r.startKey, r.endKey, actual)
+		}
+	}
+}
 
-	b.Run(backend+"_Update", func(b *testing.B) {
-		reset(backend, source, memory)
-		benchmarkUpdate(b)
-	})
+func testKeys(t *testing.T) {
+	keys, err := store.Keys("bucket")
+	if err != nil {
+		t.Fatal("Error on empty bucket keys:", err)
+	}
 
-	if !memory {
-		if err = os.RemoveAll(source); err != nil {
-			panic(err)
+	if keys == nil || len(keys) != 0 {
+		t.Fatal("Expected an empty slice for an empty bucket, got:", keys)
+	}
+
+	for _, key := range []string{"keyC", "keyA", "keyB"} {
+		if err := store.Create("bucket", key, &exampleModel); err != nil {
+			t.Fatal("Error on record creation:", err)
 		}
 	}
+
+	if keys, err = store.Keys("bucket"); err != nil {
+		t.Fatal("Error on record keys:", err)
+	}
+
+	if len(keys) != 3 || keys[0] != "keyA" || keys[1] != "keyB" || keys[2] != "keyC" {
+		t.Fatal("Expected [keyA keyB keyC], got:", keys)
+	}
 }
 
-func testCount(t *testing.T) {
-	if err := store.Create("bucket", "key", &exampleModel); err != nil {
+func testMigrateBucket(t *testing.T) {
+	if _, ok := store.(kvbase.BucketMigrator); !ok {
+		if counter, err := kvbase.MigrateBucket(store, "a_b"); err != nil || counter != 0 {
+			t.Fatal("Expected nothing to migrate, got:", counter, err)
+		}
+
+		t.Skip("Backend has native buckets")
+	}
+
+	// Earlier versions stored bucket "a_b" with key "c" under the composite key of bucket "a" with key "b_c"
+	if err := store.Create("a", "b_c", &exampleModel); err != nil {
 		t.Fatal("Error on record creation:", err)
 	}
 
-	counter, err := store.Count("bucket")
-	if err != nil {
-		t.Fatal("Error on record count:", err)
+	if counter, err := kvbase.MigrateBucket(store, "a_b"); err != nil || counter != 1 {
+		t.Fatal("Expected 1 record to be migrated, got:", counter, err)
 	}
 
-	if counter != 1 {
-		t.Fatal("Expected 1 from counter, got", counter)
+	var record model
+	if err := store.Read("a_b", "c", &record); err != nil || record != exampleModel {
+		t.Fatal("Expected the migrated record, got:", record, err)
 	}
-}
 
-func testCreate(t *testing.T) {
-	if err := store.Create("bucket", "key", &exampleModel); err != nil {
+	if counter, err := store.Count("a"); err != nil || counter != 0 {
+		t.Fatal("Expected bucket a to be empty, got:", counter, err)
+	}
+
+	if counter, err := kvbase.MigrateBucket(store, "a_b"); err != nil || counter != 0 {
+		t.Fatal("Expected nothing left to migrate, got:", counter, err)
+	}
+
+	if counter, err := kvbase.MigrateBucket(store, "users"); err != nil || counter != 0 {
+		t.Fatal("Expected bucket names without _ or % to never need migrating, got:", counter, err)
+	}
+
+	if err := store.Create("a", "b_d", &exampleModel); err != nil {
 		t.Fatal("Error on record creation:", err)
 	}
 
-	if err := store.Create("bucket", "key", &exampleModel); err == nil {
-		t.Fatal("Error expected for existing key.")
+	if _, err := kvbase.MigrateBucket(store, "a_b"); !errors.Is(err, kvbase.ErrBucketExists) {
+		t.Fatal("Expected ErrBucketExists once the bucket holds current records, got:", err)
 	}
 }
 
-func testDelete(t *testing.T) {
+func testRead(t *testing.T) {
+	emptyModel := model{}
+
+	if err := store.Read("bucket", "key", &emptyModel); !errors.Is(err, kvbase.ErrKeyNotFound) {
+		t.Fatal("Expected ErrKeyNotFound for missing key, got:", err)
+	}
+
 	if err := store.Create("bucket", "key", &exampleModel); err != nil {
 		t.Fatal("Error on record creation:", err)
 	}
 
-	if err := store.Delete("bucket", "key"); err != nil {
-		t.Fatal("Error on record deletion:", err)
+	if err := store.Read("bucket", "key", &emptyModel); err != nil {
+		t.Fatal("Error on store read:", err)
 	}
 
-	if err := store.Delete("bucket", "key"); err == nil {
-		t.Fatal("Error expected for missing key.")
+	if emptyModel.Name != "John Smith" {
+		t.Fatal("Expected John Smith for returned struct.Name, got:", emptyModel.Name)
 	}
 }
 
-func testDrop(t *testing.T) {
-	newModel := exampleModel
-	newModel.Name = "Updated John Smith"
+func testRenameBucket(t *testing.T) {
+	if err := store.RenameBucket("bucket", "renamed"); !errors.Is(err, kvbase.ErrBucketNotFound) {
+		t.Fatal("Expected ErrBucketNotFound for a missing bucket, got:", err)
+	}
 
-	if err := store.Create("bucket", "k0", &exampleModel); err != nil {
-		t.Fatal("Error on record creation:", err)
+	createPrefixFixture(t)
+
+	if err := store.RenameBucket("bucket", "bucket2"); !errors.Is(err, kvbase.ErrBucketExists) {
+		t.Fatal("Expected ErrBucketExists for a bucket name in use, got:", err)
 	}
 
-	if err := store.Create("bucket", "k1", &newModel); err != nil {
-		t.Fatal("Error on record creation:", err)
+	if err := store.RenameBucket("bucket", "renamed"); err != nil {
+		t.Fatal("Error on bucket rename:", err)
 	}
 
-	if err := store.Drop("bucket"); err != nil {
-		t.Fatal("Error on bucket drop:", err)
+	buckets, err := store.Buckets()
+	if err != nil {
+		t.Fatal("Error on bucket listing:", err)
 	}
 
-	if err := store.Read("bucket", "k0", &model{}); err == nil {
-		t.Fatal("Error expected for missing key.")
+	if strings.Join(buckets, ",") != "bucket2,renamed" {
+		t.Fatal("Expected the old bucket to be replaced by the new one, got:", buckets)
 	}
 
-	if err := store.Read("bucket", "k1", &model{}); err == nil {
-		t.Fatal("Error expected for missing key.")
+	keys, err := store.Keys("renamed")
+	if err != nil {
+		t.Fatal("Error on key listing:", err)
 	}
-}
 
-func testGet(t *testing.T) {
-	kO := model{
-		"John Smith",
+	if strings.Join(keys, ",") != "other,user:12:order:1,user:1:order:1,user:1:order:2,user_1" {
+		t.Fatal("Expected every record to be moved, got:", keys)
 	}
 
-	k1 := model{
-		"James Green",
+	emptyModel := model{}
+
+	if err := store.Read("renamed", "other", &emptyModel); err != nil {
+		t.Fatal("Error on store read:", err)
 	}
 
-	if err := store.Create("bucket", "keyOne", &kO); err != nil {
-		t.Fatal("Error on record creation:", err)
+	if emptyModel.Name != "John Smith" {
+		t.Fatal("Expected John Smith for returned struct.Name, got:", emptyModel.Name)
 	}
+}
 
-	if err := store.Create("bucket", "keyTwo", &k1); err != nil {
+func testTx(t *testing.T) {
+	errAbort := errors.New("abort")
+
+	if err := store.Create("from", "account", &exampleModel); err != nil {
 		t.Fatal("Error on record creation:", err)
 	}
 
-	results, err := store.Get("bucket", model{})
-	if err != nil {
-		t.Fatal("Error on record get:", err)
+	err := kvbase.Tx(store, func(tx kvbase.Transaction) error {
+		return nil
+	})
+	if err == kvbase.ErrTransactionsUnsupported {
+		t.Skip("Backend doesn't support transactions")
+	} else if err != nil {
+		t.Fatal("Error on empty transaction:", err)
 	}
 
-	for key, value := range *results {
-		if key != "keyOne" && key != "keyTwo" {
-			t.Fatal("Expected keyOne or keyTwo, got:", key)
+	if err := kvbase.Tx(store, func(tx kvbase.Transaction) error {
+		if err := tx.Delete("from", "account"); err != nil {
+			return err
 		}
 
-		if value == nil {
-			t.Fatal("Expected non-nil value, got:", value)
+		if err := tx.Create("to", "account", &exampleModel); err != nil {
+			return err
 		}
+
+		return errAbort
+	}); err != errAbort {
+		t.Fatal("Expected the callback's error, got:", err)
 	}
-}
 
-func testRead(t *testing.T) {
-	emptyModel := model{}
+	if err := store.Read("from", "account", &model{}); err != nil {
+		t.Fatal("Expected a rolled back delete to leave the record, got:", err)
+	}
 
-	if err := store.Create("bucket", "key", &exampleModel); err != nil {
-		t.Fatal("Error on record creation:", err)
+	if err := store.Read("to", "account", &model{}); !errors.Is(err, kvbase.ErrKeyNotFound) {
+		t.Fatal("Expected a rolled back create to leave nothing, got:", err)
 	}
 
-	if err := store.Read("bucket", "key", &emptyModel); err != nil {
+	if err := kvbase.Tx(store, func(tx kvbase.Transaction) error {
+		if err := tx.Create("to", "account", &exampleModel); err != nil {
+			return err
+		}
+
+		panic("transaction failure")
+	}); !errors.Is(err, kvbase.ErrCallbackPanic) {
+		t.Fatal("Expected ErrCallbackPanic, got:", err)
+	}
+
+	if err := store.Read("to", "account", &model{}); !errors.Is(err, kvbase.ErrKeyNotFound) {
+		t.Fatal("Expected a panicking transaction to be rolled back, got:", err)
+	}
+
+	if err := kvbase.Tx(store, func(tx kvbase.Transaction) error {
+		moved := model{}
+		if err := tx.Read("from", "account", &moved); err != nil {
+			return err
+		}
+
+		if err := tx.Delete("from", "account"); err != nil {
+			return err
+		}
+
+		if err := tx.Read("from", "account", &model{}); !errors.Is(err, kvbase.ErrKeyNotFound) {
+			t.Error("Expected the transaction to observe its own delete, got:", err)
+		}
+
+		if err := tx.Create("to", "account", &moved); err != nil {
+			return err
+		}
+
+		moved.Name = "Updated John Smith"
+
+		return tx.Update("to", "account", &moved)
+	}); err != nil {
+		t.Fatal("Error on transaction:", err)
+	}
+
+	if err := store.Read("from", "account", &model{}); !errors.Is(err, kvbase.ErrKeyNotFound) {
+		t.Fatal("Expected the committed delete to apply, got:", err)
+	}
+
+	emptyModel := model{}
+	if err := store.Read("to", "account", &emptyModel); err != nil {
 		t.Fatal("Error on store read:", err)
 	}
 
-	if emptyModel.Name != "John Smith" {
-		t.Fatal("Expected John Smith for returned struct.Name, got:", emptyModel.Name)
+	if emptyModel.Name != "Updated John Smith" {
+		t.Fatal("Expected Updated John Smith for returned struct.Name, got:", emptyModel.Name)
 	}
 }
 
@@ -236,8 +1380,8 @@ func testUpdate(t *testing.T) {
 	newModel := exampleModel
 	newModel.Name = "Updated John Smith"
 
-	if err := store.Update("bucket", "key", &exampleModel); err == nil {
-		t.Fatal("Error expected for missing key.")
+	if err := store.Update("bucket", "key", &exampleModel); !errors.Is(err, kvbase.ErrKeyNotFound) {
+		t.Fatal("Expected ErrKeyNotFound for missing key, got:", err)
 	}
 
 	if err := store.Create("bucket", "key", &exampleModel); err != nil {
@@ -257,6 +1401,37 @@ func testUpdate(t *testing.T) {
 	}
 }
 
+func testUpsert(t *testing.T) {
+	emptyModel := model{}
+	newModel := exampleModel
+	newModel.Name = "Updated John Smith"
+
+	if err := store.Upsert("bucket", "key", &exampleModel); err != nil {
+		t.Fatal("Error on record upsert of missing key:", err)
+	}
+
+	if err := store.Upsert("bucket", "key", &newModel); err != nil {
+		t.Fatal("Error on record upsert of existing key:", err)
+	}
+
+	if err := store.Read("bucket", "key", &emptyModel); err != nil {
+		t.Fatal("Error on store read:", err)
+	}
+
+	if emptyModel.Name != "Updated John Smith" {
+		t.Fatal("Expected Updated John Smith for returned struct.Name, got:", emptyModel.Name)
+	}
+
+	counter, err := store.Count("bucket")
+	if err != nil {
+		t.Fatal("Error on record count:", err)
+	}
+
+	if counter != 1 {
+		t.Fatal("Expected 1 from counter, got", counter)
+	}
+}
+
 func benchmarkCount(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		if err := store.Create("bucket", strconv.Itoa(i), &exampleModel); err != nil {
@@ -280,6 +1455,20 @@ func benchmarkCreate(b *testing.B) {
 	}
 }
 
+func benchmarkCreateBatch(b *testing.B) {
+	records := make(map[string]interface{}, 100)
+	for i := 0; i < 100; i++ {
+		records[strconv.Itoa(i)] = &exampleModel
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store.CreateBatch("bucket"+strconv.Itoa(i), records); err != nil {
+			b.Error("Error on batch creation:", err)
+		}
+	}
+}
+
 func benchmarkDelete(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		if err := store.Create("bucket", strconv.Itoa(i), &exampleModel); err != nil {
@@ -310,6 +1499,23 @@ func benchmarkDrop(b *testing.B) {
 	}
 }
 
+func benchmarkForEach(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if err := store.Create("bucket", strconv.Itoa(i), &exampleModel); err != nil {
+			b.Error("Error on record creation:", err)
+		}
+	}
+
+	record := model{}
+
+	b.ResetTimer()
+	if err := store.ForEach("bucket", &record, func(key string) error {
+		return nil
+	}); err != nil {
+		b.Error("Error on record iteration:", err)
+	}
+}
+
 func benchmarkGet(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		if err := store.Create("bucket", strconv.Itoa(i), &exampleModel); err != nil {
@@ -326,6 +1532,86 @@ func benchmarkGet(b *testing.B) {
 	}
 }
 
+func benchmarkGetPage(b *testing.B) {
+	for i := 0; i < 1000; i++ {
+		if err := store.Create("bucket", strconv.Itoa(i), &exampleModel); err != nil {
+			b.Error("Error on record creation:", err)
+		}
+	}
+
+	b.ResetTimer()
+	cursor := ""
+	for i := 0; i < b.N; i++ {
+		var err error
+		if _, cursor, err = store.GetPage("bucket", nil, 100, cursor); err != nil {
+			b.Error("Error on record page:", err)
+		}
+	}
+}
+
+func benchmarkGetPageReverse(b *testing.B) {
+	for i := 0; i < 1000; i++ {
+		if err := store.Create("bucket", strconv.Itoa(i), &exampleModel); err != nil {
+			b.Error("Error on record creation:", err)
+		}
+	}
+
+	b.ResetTimer()
+	cursor := ""
+	for i := 0; i < b.N; i++ {
+		var err error
+		if _, cursor, err = store.GetPageReverse("bucket", nil, 100, cursor); err != nil {
+			b.Error("Error on record page:", err)
+		}
+	}
+}
+
+func benchmarkGetPrefix(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if err := store.Create("bucket", "user:"+strconv.Itoa(i%100)+":"+strconv.Itoa(i), &exampleModel); err != nil {
+			b.Error("Error on record creation:", err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.GetPrefix("bucket", "user:"+strconv.Itoa(i%100)+":", nil); err != nil {
+			b.Error("Error on prefix get:", err)
+		}
+	}
+}
+
+func benchmarkGetRange(b *testing.B) {
+	for i := 0; i < 1000; i++ {
+		if err := store.Create("bucket", fmt.Sprintf("%04d", i), &exampleModel); err != nil {
+			b.Error("Error on record creation:", err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := i % 900
+		if _, err := store.GetRange("bucket", fmt.Sprintf("%04d", start), fmt.Sprintf("%04d", start+100), nil); err != nil {
+			b.Error("Error on range get:", err)
+		}
+	}
+}
+
+func benchmarkKeys(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if err := store.Create("bucket", strconv.Itoa(i), &exampleModel); err != nil {
+			b.Error("Error on record creation:", err)
+		}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.Keys("bucket"); err != nil {
+			b.Error("Error on record keys:", err)
+		}
+	}
+}
+
 func benchmarkRead(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		if err := store.Create("bucket", strconv.Itoa(i), &exampleModel); err != nil {
@@ -358,3 +1644,43 @@ func benchmarkUpdate(b *testing.B) {
 		}
 	}
 }
+
+func benchmarkUpsert(b *testing.B) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store.Upsert("bucket", strconv.Itoa(i%100), &exampleModel); err != nil {
+			b.Error("Error on record upsert:", err)
+		}
+	}
+}
+
+func createPrefixFixture(t *testing.T) {
+	fixture := map[string][]string{
+		"bucket":  {"other", "user:1:order:1", "user:1:order:2", "user:12:order:1", "user_1"},
+		"bucket2": {"user:1:order:3"},
+	}
+
+	for bucket, keys := range fixture {
+		for _, key := range keys {
+			if err := store.Create(bucket, key, &exampleModel); err != nil {
+				t.Fatal("Error on record creation:", err)
+			}
+		}
+	}
+}
+
+// countdownContext reports itself as cancelled once Err has been called more than remaining times
+type countdownContext struct {
+	context.Context
+	remaining int
+}
+
+func (ctx *countdownContext) Err() error {
+	if ctx.remaining <= 0 {
+		return context.Canceled
+	}
+
+	ctx.remaining--
+
+	return nil
+}
@@ -0,0 +1,103 @@
+// Package kvbasetest provides ready-to-use backends for tests.
+//
+// New returns an in-memory backend by default. Set the KVBASE_TEST_DRIVER environment variable (or pass WithDriver)
+// to run the same tests against a real driver, stored in a temporary directory that's removed once the test finishes.
+//
+// Backends are registered as singletons, so two calls to New for the same driver within one test share a store.
+package kvbasetest
+
+import (
+	"github.com/Wolveix/kvbase"
+	_ "github.com/Wolveix/kvbase/backend/badgerdb"
+	_ "github.com/Wolveix/kvbase/backend/bboltdb"
+	_ "github.com/Wolveix/kvbase/backend/bitcask"
+	_ "github.com/Wolveix/kvbase/backend/boltdb"
+	_ "github.com/Wolveix/kvbase/backend/diskv"
+	_ "github.com/Wolveix/kvbase/backend/file"
+	_ "github.com/Wolveix/kvbase/backend/go-cache"
+	_ "github.com/Wolveix/kvbase/backend/leveldb"
+	_ "github.com/Wolveix/kvbase/backend/memory"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// DefaultDriver is used when neither WithDriver nor KVBASE_TEST_DRIVER select a driver
+const DefaultDriver = "memory"
+
+type options struct {
+	driver  string
+	fixture map[string]map[string]interface{}
+}
+
+// Option configures the backend returned by New
+type Option func(*options)
+
+// WithDriver selects the driver to test against, overriding KVBASE_TEST_DRIVER
+func WithDriver(driver string) Option {
+	return func(opts *options) {
+		opts.driver = driver
+	}
+}
+
+// WithFixture preloads the backend with the provided records, keyed by bucket and then by key
+func WithFixture(fixture map[string]map[string]interface{}) Option {
+	return func(opts *options) {
+		opts.fixture = fixture
+	}
+}
+
+// New returns a ready backend for the duration of the test, failing the test if it can't be created
+func New(t testing.TB, opts ...Option) kvbase.Backend {
+	t.Helper()
+
+	config := options{
+		driver: os.Getenv("KVBASE_TEST_DRIVER"),
+	}
+
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if config.driver == "" {
+		config.driver = DefaultDriver
+	}
+
+	var store kvbase.Backend
+	var err error
+
+	if config.driver == DefaultDriver {
+		store, err = kvbase.New(config.driver, "", true)
+	} else {
+		dir, dirErr := ioutil.TempDir("", "kvbasetest")
+		if dirErr != nil {
+			t.Fatal("kvbasetest: unable to create temporary directory:", dirErr)
+		}
+
+		t.Cleanup(func() {
+			_ = os.RemoveAll(dir)
+		})
+
+		store, err = kvbase.New(config.driver, filepath.Join(dir, "data"), false)
+	}
+
+	if err != nil {
+		t.Fatalf("kvbasetest: unable to create %s backend (registered: %v): %v", config.driver, kvbase.Backends(), err)
+	}
+
+	// Registered after the directory cleanup so that it runs first, releasing file locks before removal
+	t.Cleanup(func() {
+		_ = store.Close()
+	})
+
+	for bucket, records := range config.fixture {
+		for key, model := range records {
+			if err := store.Create(bucket, key, model); err != nil {
+				t.Fatalf("kvbasetest: unable to load fixture %s/%s: %v", bucket, key, err)
+			}
+		}
+	}
+
+	return store
+}
@@ -0,0 +1,40 @@
+package kvbasetest_test
+
+import (
+	"github.com/Wolveix/kvbase/pkg/kvbasetest"
+	"os"
+	"testing"
+)
+
+type model struct {
+	Name string
+}
+
+func TestNew(t *testing.T) {
+	for _, driver := range []string{kvbasetest.DefaultDriver, "bboltdb", "leveldb"} {
+		store := kvbasetest.New(t, kvbasetest.WithDriver(driver), kvbasetest.WithFixture(map[string]map[string]interface{}{
+			"users": {"john": &model{"John Smith"}},
+		}))
+
+		result := model{}
+		if err := store.Read("users", "john", &result); err != nil {
+			t.Fatal("Error on store read:", err)
+		}
+
+		if result.Name != "John Smith" {
+			t.Fatal("Expected John Smith for returned struct.Name, got:", result.Name)
+		}
+	}
+}
+
+func TestNewFromEnvironment(t *testing.T) {
+	if err := os.Setenv("KVBASE_TEST_DRIVER", "leveldb"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("KVBASE_TEST_DRIVER")
+
+	store := kvbasetest.New(t)
+	if err := store.Create("users", "john", &model{"John Smith"}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+}
@@ -0,0 +1,83 @@
+package kvdriver
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/Wolveix/kvbase"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// BaseBackend derives convenience methods from the core methods of a driver. Drivers embed it, pointing Driver at
+// themselves from their init function, and override whichever derived methods their store can implement more
+// efficiently:
+//
+//	store := backend{...}
+//	store.BaseBackend = kvdriver.BaseBackend{Driver: &store}
+type BaseBackend struct {
+	Driver kvbase.Backend
+}
+
+// Exists reports whether the provided bucket holds a record under key, by reading it. Records that can't be decoded
+// as JSON, such as those of stores opened with another codec, are looked up among the bucket's keys instead.
+func (base BaseBackend) Exists(bucket string, key string) (bool, error) {
+	var raw json.RawMessage
+
+	err := base.Driver.Read(bucket, key, &raw)
+	if err == nil {
+		return true, nil
+	} else if errors.Is(err, kvbase.ErrKeyNotFound) {
+		return false, nil
+	}
+
+	keys, err := base.Driver.Keys(bucket)
+	if err != nil {
+		return false, err
+	}
+
+	i := sort.SearchStrings(keys, key)
+
+	return i < len(keys) && keys[i] == key, nil
+}
+
+// GetPrefix returns the records inside of the provided bucket whose keys start with prefix, walking the bucket with
+// ForEach until its keys pass the prefix. Each record is decoded into a new instance of model, or into a generic value
+// when model isn't a pointer.
+func (base BaseBackend) GetPrefix(bucket string, prefix string, model interface{}) (*map[string]interface{}, error) {
+	results := make(map[string]interface{})
+
+	var generic interface{}
+
+	typed := NewModel(model) != nil
+
+	target := model
+	if !typed {
+		target = &generic
+	}
+
+	err := base.Driver.ForEach(bucket, target, func(key string) error {
+		if !strings.HasPrefix(key, prefix) {
+			if key > prefix {
+				return kvbase.ErrStopIteration
+			}
+
+			return nil
+		}
+
+		if typed {
+			value := reflect.New(reflect.TypeOf(model).Elem())
+			value.Elem().Set(reflect.ValueOf(model).Elem())
+			results[key] = value.Interface()
+		} else {
+			results[key] = generic
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
@@ -0,0 +1,52 @@
+package kvdriver
+
+import (
+	"github.com/Wolveix/kvbase/internal/envelope"
+)
+
+// Magic marks the first byte of an enveloped value. It can never start a UTF-8 encoded JSON document, so values
+// without it are read as bare legacy payloads.
+const Magic = envelope.Magic
+
+// FormatVersion is the newest envelope layout this package can decode
+const FormatVersion = envelope.FormatVersion
+
+// Section types. Types with the SectionCritical bit set change how the payload must be interpreted, so readers that
+// don't know them refuse the value instead of skipping the section.
+const (
+	SectionTTL        = envelope.SectionTTL
+	SectionTimestamps = envelope.SectionTimestamps
+	SectionChecksum   = envelope.SectionChecksum
+	SectionSchema     = envelope.SectionSchema
+	SectionCodec      = envelope.SectionCodec
+	SectionTransforms = envelope.SectionTransforms
+
+	SectionCritical = envelope.SectionCritical
+)
+
+var (
+	// ErrChecksumMismatch is returned when an envelope's payload doesn't match its checksum section
+	ErrChecksumMismatch = envelope.ErrChecksumMismatch
+
+	// ErrCorruptEnvelope is returned when an envelope can't be parsed
+	ErrCorruptEnvelope = envelope.ErrCorruptEnvelope
+
+	// ErrEnvelopeVersion is returned when an envelope was written with a newer, incompatible layout
+	ErrEnvelopeVersion = envelope.ErrEnvelopeVersion
+
+	// ErrUnknownSection is returned when an envelope holds a critical section this package doesn't know
+	ErrUnknownSection = envelope.ErrUnknownSection
+)
+
+// Section is a raw envelope section, used to carry sections written by newer versions through a decode/encode cycle
+type Section = envelope.Section
+
+// Envelope wraps a stored payload with optional metadata. kvbase writes the codec and transform sections of the values
+// it compresses or encrypts, so drivers reading stored bytes directly can tell them apart from bare payloads.
+type Envelope = envelope.Envelope
+
+// Decode parses an enveloped value. Values without the magic byte are returned as legacy envelopes holding the whole
+// value as their payload. Unknown non-critical sections are skipped and kept in Unknown.
+func Decode(data []byte) (*Envelope, error) {
+	return envelope.Decode(data)
+}
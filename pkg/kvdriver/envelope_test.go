@@ -0,0 +1,99 @@
+package kvdriver_test
+
+import (
+	"bytes"
+	"github.com/Wolveix/kvbase/pkg/kvdriver"
+	"testing"
+	"time"
+)
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	now := time.Unix(1700000000, 123)
+	envelope := kvdriver.Envelope{
+		Flags:         3,
+		ExpiresAt:     now.Add(time.Hour),
+		CreatedAt:     now,
+		UpdatedAt:     now.Add(time.Minute),
+		Checksum:      true,
+		Codec:         "json",
+		Transforms:    []string{"gzip", "aes-gcm"},
+		SchemaVersion: 7,
+		Payload:       []byte(`{"Name":"John Smith"}`),
+	}
+
+	decoded, err := kvdriver.Decode(envelope.Encode())
+	if err != nil {
+		t.Fatal("Error on envelope decode:", err)
+	}
+
+	if decoded.Legacy || decoded.Flags != 3 || !decoded.Checksum || decoded.Codec != "json" || decoded.SchemaVersion != 7 {
+		t.Fatal("Unexpected decoded header:", decoded)
+	}
+
+	if !decoded.ExpiresAt.Equal(envelope.ExpiresAt) || !decoded.CreatedAt.Equal(now) || !decoded.UpdatedAt.Equal(envelope.UpdatedAt) {
+		t.Fatal("Unexpected decoded timestamps:", decoded.ExpiresAt, decoded.CreatedAt, decoded.UpdatedAt)
+	}
+
+	if len(decoded.Transforms) != 2 || decoded.Transforms[0] != "gzip" || decoded.Transforms[1] != "aes-gcm" {
+		t.Fatal("Unexpected decoded transforms:", decoded.Transforms)
+	}
+
+	if !bytes.Equal(decoded.Payload, envelope.Payload) {
+		t.Fatal("Expected payload", string(envelope.Payload), "got", string(decoded.Payload))
+	}
+
+	if !bytes.Equal(decoded.Encode(), envelope.Encode()) {
+		t.Fatal("Expected re-encoding to be stable")
+	}
+}
+
+func TestEnvelopeLegacy(t *testing.T) {
+	payload := []byte(`{"Name":"John Smith"}`)
+
+	decoded, err := kvdriver.Decode(payload)
+	if err != nil {
+		t.Fatal("Error on envelope decode:", err)
+	}
+
+	if !decoded.Legacy || !bytes.Equal(decoded.Payload, payload) || !bytes.Equal(decoded.Encode(), payload) {
+		t.Fatal("Expected bare legacy payload, got", decoded)
+	}
+}
+
+func TestEnvelopeForwardCompatibility(t *testing.T) {
+	// Written by a hypothetical newer version: one checksum section plus an unknown non-critical section 0x10
+	fixture := []byte{kvdriver.Magic, 1, 0, 0, 2, 0x10, 3, 'n', 'e', 'w', kvdriver.SectionChecksum, 4, 0x79, 0xdc, 0xdd, 0x47, 'o', 'k'}
+
+	decoded, err := kvdriver.Decode(fixture)
+	if err != nil {
+		t.Fatal("Error on envelope decode:", err)
+	}
+
+	if string(decoded.Payload) != "ok" || len(decoded.Unknown) != 1 || string(decoded.Unknown[0].Data) != "new" {
+		t.Fatal("Unexpected decoded envelope:", decoded)
+	}
+
+	critical := []byte{kvdriver.Magic, 1, 0, 0, 1, 0x90, 3, 'n', 'e', 'w', 'o', 'k'}
+	if _, err := kvdriver.Decode(critical); err != kvdriver.ErrUnknownSection {
+		t.Fatal("Expected ErrUnknownSection, got", err)
+	}
+
+	newer := []byte{kvdriver.Magic, kvdriver.FormatVersion + 1, 0, 0, 0, 'o', 'k'}
+	if _, err := kvdriver.Decode(newer); err != kvdriver.ErrEnvelopeVersion {
+		t.Fatal("Expected ErrEnvelopeVersion, got", err)
+	}
+}
+
+func TestEnvelopeCorruption(t *testing.T) {
+	envelope := kvdriver.Envelope{Checksum: true, Payload: []byte("payload")}
+	data := envelope.Encode()
+
+	data[len(data)-1] ^= 0xFF
+	if _, err := kvdriver.Decode(data); err != kvdriver.ErrChecksumMismatch {
+		t.Fatal("Expected ErrChecksumMismatch, got", err)
+	}
+
+	if _, err := kvdriver.Decode(data[:6]); err != kvdriver.ErrCorruptEnvelope {
+		t.Fatal("Expected ErrCorruptEnvelope, got", err)
+	}
+}
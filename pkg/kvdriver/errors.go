@@ -0,0 +1,48 @@
+package kvdriver
+
+import (
+	"errors"
+	"github.com/Wolveix/kvbase"
+	"reflect"
+	"strconv"
+)
+
+// BucketExists returns kvbase.ErrBucketExists naming the bucket already in use
+func BucketExists(bucket string) error {
+	return kvbase.WrapError(kvbase.ErrBucketExists, errors.New("bucket "+strconv.Quote(bucket)))
+}
+
+// BucketNotFound returns kvbase.ErrBucketNotFound naming the missing bucket
+func BucketNotFound(bucket string) error {
+	return kvbase.WrapError(kvbase.ErrBucketNotFound, errors.New("bucket "+strconv.Quote(bucket)))
+}
+
+// KeyExists returns kvbase.ErrKeyExists naming the record already stored
+func KeyExists(bucket string, key string) error {
+	return kvbase.WrapError(kvbase.ErrKeyExists, errors.New("key "+strconv.Quote(key)+" in bucket "+strconv.Quote(bucket)))
+}
+
+// KeyNotFound returns kvbase.ErrKeyNotFound naming the missing record
+func KeyNotFound(bucket string, key string) error {
+	return kvbase.WrapError(kvbase.ErrKeyNotFound, errors.New("key "+strconv.Quote(key)+" in bucket "+strconv.Quote(bucket)))
+}
+
+// ValidateBucket returns kvbase.ErrInvalidBucket for the empty bucket name, which stores with native buckets can't
+// create and which would otherwise address the root of stores keeping a bucket per directory
+func ValidateBucket(bucket string) error {
+	if bucket == "" {
+		return kvbase.WrapError(kvbase.ErrInvalidBucket, errors.New("a bucket name is required"))
+	}
+
+	return nil
+}
+
+// ValidateModel returns an error unless model is a non-nil pointer that a record can be decoded into
+func ValidateModel(model interface{}) error {
+	value := reflect.ValueOf(model)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return errors.New("kvdriver: model must be a non-nil pointer")
+	}
+
+	return nil
+}
@@ -0,0 +1,237 @@
+// Package kvdriver contains the helpers shared by kvbase backends.
+//
+// Stores without native buckets emulate them by prefixing every key with its
+// escaped bucket name and a separator. Key, Prefix, TrimPrefix and SplitKey
+// handle the bucket encoding so every driver lays out its data identically.
+// Values are encoded with Marshal and MarshalBatch and decoded with Unmarshal,
+// through the codec kvbase.New hands the driver's Configure method, which
+// envelopes them with their codec and transforms.
+//
+// A minimal driver implements point get, put and delete, iteration in sorted
+// key order, and the kvbase.Backend methods built on them. Embedding
+// BaseBackend derives Exists from Read and GetPrefix from ForEach, which the
+// driver can override once its store offers something faster. Missing and
+// duplicate records are reported with KeyNotFound, KeyExists, BucketNotFound
+// and BucketExists, and names and models are checked with ValidateBucket and
+// ValidateModel:
+//
+//	type backend struct {
+//		kvdriver.BaseBackend
+//		Codec      kvbase.Codec
+//		Connection *mystore.DB
+//	}
+//
+//	var _ kvbase.BackendCtx = (*backend)(nil)
+//
+//	func init() {
+//		store := backend{Codec: kvbase.JSONCodec{}}
+//		store.BaseBackend = kvdriver.BaseBackend{Driver: &store}
+//
+//		if err := kvbase.Register("mydriver", &store); err != nil {
+//			panic(err)
+//		}
+//	}
+//
+//	func (store *backend) Read(bucket string, key string, model interface{}) error {
+//		data, err := store.Connection.Get(kvdriver.Key(bucket, key))
+//		if err == mystore.ErrNotFound {
+//			return kvdriver.KeyNotFound(bucket, key)
+//		} else if err != nil {
+//			return err
+//		}
+//
+//		return kvdriver.Unmarshal(store.Codec, data, model)
+//	}
+//
+// Drivers register themselves with kvbase.Register from an init function and
+// should run the shared conformance suite from pkg/kvbaseBackendTest in their
+// tests, which exercises every Backend method against a fresh store:
+//
+//	func Test_Disk(t *testing.T) {
+//		kvbaseBackendTest.RunTests(t, "mydriver", "testdata", false)
+//	}
+package kvdriver
+
+import (
+	"github.com/Wolveix/kvbase"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Separator divides the bucket name from the record key
+const Separator = "_"
+
+// bucketEscaper escapes the separator and the escape character inside of bucket names, and bucketUnescaper reverts it,
+// so that the first separator of a composite key always ends its bucket name
+var (
+	bucketEscaper   = strings.NewReplacer("%", "%25", Separator, "%5F")
+	bucketUnescaper = strings.NewReplacer("%25", "%", "%5F", Separator)
+)
+
+// Key returns the composite key for a record inside of the provided bucket. The bucket name is escaped, so bucket
+// "a" with key "b_c" and bucket "a_b" with key "c" never share a composite key; names containing neither "_" nor
+// "%" are stored as they are.
+func Key(bucket string, key string) string {
+	return Prefix(bucket) + key
+}
+
+// Prefix returns the prefix shared by every composite key inside of the provided bucket. No other bucket's prefix
+// starts with it.
+func Prefix(bucket string) string {
+	return bucketEscaper.Replace(bucket) + Separator
+}
+
+// LegacyPrefix returns the prefix earlier versions stored the records of the provided bucket under, before bucket
+// names were escaped. It only differs from Prefix for names containing "_" or "%", whose records drivers move with
+// kvbase.MigrateBucket.
+func LegacyPrefix(bucket string) string {
+	return bucket + Separator
+}
+
+// TrimPrefix strips the bucket prefix from a composite key, returning the record key
+func TrimPrefix(bucket string, key string) string {
+	return strings.TrimPrefix(key, Prefix(bucket))
+}
+
+// SplitKey splits a composite key into its unescaped bucket name and record key at the first separator, which
+// escaped bucket names never contain
+func SplitKey(key string) (string, string, bool) {
+	i := strings.Index(key, Separator)
+	if i < 0 {
+		return "", "", false
+	}
+
+	return bucketUnescaper.Replace(key[:i]), key[i+len(Separator):], true
+}
+
+// Buckets returns the distinct bucket names found in a set of composite keys, in sorted order. Keys without a
+// separator don't belong to any bucket and are ignored.
+func Buckets(keys []string) []string {
+	seen := make(map[string]bool)
+	buckets := []string{}
+
+	for _, key := range keys {
+		if bucket, _, ok := SplitKey(key); ok && !seen[bucket] {
+			seen[bucket] = true
+			buckets = append(buckets, bucket)
+		}
+	}
+
+	sort.Strings(buckets)
+
+	return buckets
+}
+
+// InRange reports whether key falls between startKey (inclusive) and endKey (exclusive) in byte order. An empty
+// startKey or endKey leaves that side of the range open.
+func InRange(key string, startKey string, endKey string) bool {
+	return key >= startKey && (endKey == "" || key < endKey)
+}
+
+// Page returns the keys belonging to the page that starts after startAfter, taken from keys sorted in ascending order,
+// and the cursor for the following page, which is empty once the last page has been returned. A limit of zero or less
+// returns every remaining key.
+func Page(keys []string, limit int, startAfter string) ([]string, string) {
+	start := 0
+	if startAfter != "" {
+		start = sort.SearchStrings(keys, startAfter)
+		if start < len(keys) && keys[start] == startAfter {
+			start++
+		}
+	}
+
+	keys = keys[start:]
+	if limit <= 0 || len(keys) <= limit {
+		return keys, ""
+	}
+
+	return keys[:limit], keys[limit-1]
+}
+
+// PageReverse mirrors Page, walking keys sorted in ascending order from the highest key down. The page holds the
+// keys before startBefore, highest first, and the cursor is the lowest key returned.
+func PageReverse(keys []string, limit int, startBefore string) ([]string, string) {
+	end := len(keys)
+	if startBefore != "" {
+		end = sort.SearchStrings(keys, startBefore)
+	}
+
+	page := make([]string, 0, end)
+	for i := end - 1; i >= 0; i-- {
+		if limit > 0 && len(page) == limit {
+			return page, page[limit-1]
+		}
+
+		page = append(page, keys[i])
+	}
+
+	return page, ""
+}
+
+// Marshal serializes a model for storage with the store's codec. Drivers route every write through it so that records
+// are encoded identically whichever method stored them.
+func Marshal(codec kvbase.Codec, model interface{}) ([]byte, error) {
+	return codec.Marshal(model)
+}
+
+// MarshalBatch serializes every record of a batch in sorted key order, so that drivers can reject the whole batch
+// before writing anything. Marshalling failures are reported as a *kvbase.BatchError naming the offending key.
+func MarshalBatch(codec kvbase.Codec, records map[string]interface{}) ([]string, [][]byte, error) {
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	values := make([][]byte, len(keys))
+
+	for i, key := range keys {
+		data, err := Marshal(codec, records[key])
+		if err != nil {
+			return nil, nil, &kvbase.BatchError{Key: key, Err: err}
+		}
+
+		values[i] = data
+	}
+
+	return keys, values, nil
+}
+
+// Unmarshal decodes a stored record with the store's codec into model, which must be a non-nil pointer. The value
+// model points to is reset first, so that decoding a sequence of records into the same model never carries fields
+// over between them.
+func Unmarshal(codec kvbase.Codec, data []byte, model interface{}) error {
+	if err := ValidateModel(model); err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(model)
+	value.Elem().Set(reflect.Zero(value.Elem().Type()))
+
+	return codec.Unmarshal(data, model)
+}
+
+// Guard wraps a ForEach callback so that a panic inside of it is returned as a *kvbase.PanicError. Drivers guard the
+// callback before iterating, so the panic unwinds no further than the callback and their transactions and locks are
+// released as on any other error.
+func Guard(fn func(key string) error) func(key string) error {
+	return func(key string) (err error) {
+		defer kvbase.Recover(&err)
+
+		return fn(key)
+	}
+}
+
+// NewModel returns a fresh instance to unmarshal a single record into. When model is a pointer, a new value of the
+// type it points to is allocated so that records never alias each other; otherwise nil is returned, letting the
+// decoder pick a generic representation.
+func NewModel(model interface{}) interface{} {
+	value := reflect.ValueOf(model)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return nil
+	}
+
+	return reflect.New(value.Elem().Type()).Interface()
+}
@@ -0,0 +1,128 @@
+package kvdriver_test
+
+import (
+	"errors"
+	"github.com/Wolveix/kvbase"
+	"github.com/Wolveix/kvbase/pkg/kvbasetest"
+	"github.com/Wolveix/kvbase/pkg/kvdriver"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKeyEscaping(t *testing.T) {
+	buckets := []string{"users", "a", "a_b", "a%5Fb", "a%", "%25", "_", ""}
+	seen := make(map[string]string)
+
+	for _, bucket := range buckets {
+		key := kvdriver.Key(bucket, "b_c")
+
+		if other, ok := seen[kvdriver.Prefix(bucket)]; ok {
+			t.Fatal("Expected buckets", other, "and", bucket, "to have distinct prefixes")
+		}
+
+		seen[kvdriver.Prefix(bucket)] = bucket
+
+		if got, record, ok := kvdriver.SplitKey(key); !ok || got != bucket || record != "b_c" {
+			t.Fatal("Expected", key, "to split into", bucket, "and b_c, got:", got, record, ok)
+		}
+
+		if record := kvdriver.TrimPrefix(bucket, key); record != "b_c" {
+			t.Fatal("Expected b_c, got:", record)
+		}
+
+		for _, other := range buckets {
+			if other != bucket && strings.HasPrefix(key, kvdriver.Prefix(other)) {
+				t.Fatal("Expected", key, "to be outside of bucket", other)
+			}
+		}
+	}
+
+	if kvdriver.Key("users", "x") != "users_x" || kvdriver.LegacyPrefix("users") != kvdriver.Prefix("users") {
+		t.Fatal("Expected bucket names without _ or % to be stored as they are")
+	}
+
+	if kvdriver.LegacyPrefix("a_b") == kvdriver.Prefix("a_b") {
+		t.Fatal("Expected a_b to be escaped")
+	}
+}
+
+type record struct {
+	Name string
+}
+
+func TestBaseBackend(t *testing.T) {
+	store := kvbasetest.New(t, kvbasetest.WithFixture(map[string]map[string]interface{}{
+		"users": {"a1": &record{"A1"}, "a2": &record{"A2"}, "b1": &record{"B1"}},
+	}))
+
+	base := kvdriver.BaseBackend{Driver: store}
+
+	for _, model := range []interface{}{&record{}, nil} {
+		expected, err := store.GetPrefix("users", "a", model)
+		if err != nil {
+			t.Fatal("Error on prefix get:", err)
+		}
+
+		results, err := base.GetPrefix("users", "a", model)
+		if err != nil {
+			t.Fatal("Error on prefix get:", err)
+		}
+
+		if !reflect.DeepEqual(*results, *expected) {
+			t.Fatal("Expected", *expected, "got", *results)
+		}
+	}
+
+	if exists, err := base.Exists("users", "a1"); err != nil || !exists {
+		t.Fatal("Expected a1 to exist, got:", exists, err)
+	}
+
+	if exists, err := base.Exists("users", "a"); err != nil || exists {
+		t.Fatal("Expected a not to exist, got:", exists, err)
+	}
+
+	// Records the JSON decoder can't read are found among the bucket's keys
+	dir, err := ioutil.TempDir("", "kvdriver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	gob, err := kvbase.New("bboltdb", filepath.Join(dir, "data.db"), false, kvbase.WithCodec(kvbase.GobCodec{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gob.Close()
+
+	if err := gob.Create("users", "a1", &record{"A1"}); err != nil {
+		t.Fatal("Error on record creation:", err)
+	}
+
+	if exists, err := gob.(interface {
+		Exists(bucket string, key string) (bool, error)
+	}).Exists("users", "a1"); err != nil || !exists {
+		t.Fatal("Expected a1 to exist in the gob store, got:", exists, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if err := kvdriver.KeyNotFound("users", "a1"); !errors.Is(err, kvbase.ErrKeyNotFound) || !strings.Contains(err.Error(), `"a1"`) {
+		t.Fatal("Expected ErrKeyNotFound naming the key, got:", err)
+	}
+
+	if err := kvdriver.BucketExists("users"); !errors.Is(err, kvbase.ErrBucketExists) {
+		t.Fatal("Expected ErrBucketExists, got:", err)
+	}
+
+	if err := kvdriver.ValidateBucket(""); !errors.Is(err, kvbase.ErrInvalidBucket) {
+		t.Fatal("Expected ErrInvalidBucket, got:", err)
+	}
+
+	if err := kvdriver.ValidateModel(record{}); err == nil {
+		t.Fatal("Expected a model that isn't a pointer to be refused")
+	}
+}
@@ -0,0 +1,717 @@
+// Package kvrecord records the operations an application performs against a backend and replays them elsewhere.
+//
+// Wrap logs every operation of the Backend and BackendCtx interfaces as a line of JSON. Replay re-executes the recorded
+// mutations against another backend and checks that every recorded read returns the same result, which makes it useful
+// both for reproducing bugs and for differential testing between drivers.
+package kvrecord
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/Wolveix/kvbase"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Entry is a single recorded operation
+type Entry struct {
+	Op     string          `json:"op"`
+	Bucket string          `json:"bucket"`
+	Key    string          `json:"key,omitempty"`
+	Args   json.RawMessage `json:"args,omitempty"`
+	Value  json.RawMessage `json:"value,omitempty"`
+	Hash   string          `json:"hash,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Time   time.Time       `json:"time"`
+}
+
+// Divergence describes a replayed operation whose outcome differs from the recording
+type Divergence struct {
+	Line     int
+	Entry    Entry
+	Expected string
+	Actual   string
+}
+
+// ReplayReport summarizes a replay. VerifyOnly holds the line of every mutation recorded with WithHashedValues: its
+// payload wasn't recorded, so it's skipped rather than replayed, and reads following it only match a target already
+// holding the data it wrote.
+type ReplayReport struct {
+	Mutations   int
+	Reads       int
+	VerifyOnly  []int
+	Divergences []Divergence
+}
+
+// Replay parameters beyond the bucket and key, recorded as an entry's args
+type (
+	forEachArgs struct {
+		Visited int `json:"visited"`
+	}
+
+	pageArgs struct {
+		Limit  int    `json:"limit"`
+		Cursor string `json:"cursor,omitempty"`
+	}
+
+	rangeArgs struct {
+		Start string `json:"start,omitempty"`
+		End   string `json:"end,omitempty"`
+	}
+
+	restoreArgs struct {
+		Wipe bool `json:"wipe"`
+	}
+)
+
+// Results of reads returning more than the records themselves
+type (
+	forEachRecord struct {
+		Key   string          `json:"key"`
+		Value json.RawMessage `json:"value"`
+	}
+
+	pageResult struct {
+		Records *map[string]interface{} `json:"records"`
+		Cursor  string                  `json:"cursor"`
+	}
+)
+
+var (
+	_ kvbase.BackendCtx    = (*recorder)(nil)
+	_ kvbase.Fragmenter    = (*recorder)(nil)
+	_ kvbase.StatsReporter = (*recorder)(nil)
+)
+
+type recorder struct {
+	kvbase.Backend
+	encoder    *json.Encoder
+	hash       bool
+	hashValues bool
+	mux        sync.Mutex
+}
+
+// Option configures a recording
+type Option func(*recorder)
+
+// WithHashedReads records the SHA-256 of read results instead of the results themselves. Mutations are always
+// recorded in full so that they can be replayed.
+func WithHashedReads() Option {
+	return func(rec *recorder) {
+		rec.hash = true
+	}
+}
+
+// WithHashedValues records the SHA-256 of read results and of the data written by mutations, so that the recording
+// holds none of the store's data. Such mutations can't be replayed: Replay skips them and reports them as
+// ReplayReport.VerifyOnly.
+func WithHashedValues() Option {
+	return func(rec *recorder) {
+		rec.hash = true
+		rec.hashValues = true
+	}
+}
+
+// Wrap returns a backend that logs every operation performed through it to w. The returned backend also implements
+// kvbase.BackendCtx; when store doesn't, the context variants check ctx before calling the plain methods. It forwards
+// kvbase.Stats and kvbase.Fragmentation to store, which don't touch records, but hides store's other optional
+// interfaces (kvbase.Transactor, ArenaReader, MultiBucketReader and BucketMigrator), whose operations would bypass
+// the recording: the package-level helpers report them as unsupported.
+func Wrap(store kvbase.Backend, w io.Writer, opts ...Option) kvbase.Backend {
+	rec := recorder{
+		Backend: store,
+		encoder: json.NewEncoder(w),
+	}
+
+	for _, opt := range opts {
+		opt(&rec)
+	}
+
+	return &rec
+}
+
+// Backup writes every record of every bucket to w. Only its outcome is recorded, as the stream itself is the caller's.
+func (rec *recorder) Backup(w io.Writer) error {
+	err := rec.Backend.Backup(w)
+	rec.record(kvbase.OpBackup, "", "", nil, nil, err, true)
+
+	return err
+}
+
+// Buckets returns the name of every bucket in sorted order
+func (rec *recorder) Buckets() ([]string, error) {
+	buckets, err := rec.Backend.Buckets()
+	rec.record(kvbase.OpBuckets, "", "", nil, result(buckets, err), err, true)
+
+	return buckets, err
+}
+
+// Count returns the total number of records inside of the provided bucket
+func (rec *recorder) Count(bucket string) (int, error) {
+	return rec.CountCtx(context.Background(), bucket)
+}
+
+// CountCtx is Count, returning the context's error once ctx is done
+func (rec *recorder) CountCtx(ctx context.Context, bucket string) (int, error) {
+	var counter int
+	var err error
+
+	if store, ok := rec.Backend.(kvbase.BackendCtx); ok {
+		counter, err = store.CountCtx(ctx, bucket)
+	} else if err = ctx.Err(); err == nil {
+		counter, err = rec.Backend.Count(bucket)
+	}
+
+	rec.record(kvbase.OpCount, bucket, "", nil, []byte(strconv.Itoa(counter)), err, true)
+
+	return counter, err
+}
+
+// CountPrefix returns the number of records whose key starts with prefix, recorded with the prefix in place of the key
+func (rec *recorder) CountPrefix(bucket string, prefix string) (int, error) {
+	counter, err := rec.Backend.CountPrefix(bucket, prefix)
+	rec.record(kvbase.OpCountPrefix, bucket, prefix, nil, []byte(strconv.Itoa(counter)), err, true)
+
+	return counter, err
+}
+
+// Create inserts a record into the backend
+func (rec *recorder) Create(bucket string, key string, model interface{}) error {
+	return rec.CreateCtx(context.Background(), bucket, key, model)
+}
+
+// CreateCtx is Create, returning the context's error once ctx is done
+func (rec *recorder) CreateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	var err error
+
+	if store, ok := rec.Backend.(kvbase.BackendCtx); ok {
+		err = store.CreateCtx(ctx, bucket, key, model)
+	} else if err = ctx.Err(); err == nil {
+		err = rec.Backend.Create(bucket, key, model)
+	}
+
+	rec.record(kvbase.OpCreate, bucket, key, nil, marshal(model), err, false)
+
+	return err
+}
+
+// CreateBatch inserts every record as one all-or-nothing operation, recorded as a single entry holding every record
+func (rec *recorder) CreateBatch(bucket string, records map[string]interface{}) error {
+	err := rec.Backend.CreateBatch(bucket, records)
+	rec.record(kvbase.OpCreateBatch, bucket, "", nil, marshal(records), err, false)
+
+	return err
+}
+
+// Delete removes a record from the backend
+func (rec *recorder) Delete(bucket string, key string) error {
+	return rec.DeleteCtx(context.Background(), bucket, key)
+}
+
+// DeleteCtx is Delete, returning the context's error once ctx is done
+func (rec *recorder) DeleteCtx(ctx context.Context, bucket string, key string) error {
+	var err error
+
+	if store, ok := rec.Backend.(kvbase.BackendCtx); ok {
+		err = store.DeleteCtx(ctx, bucket, key)
+	} else if err = ctx.Err(); err == nil {
+		err = rec.Backend.Delete(bucket, key)
+	}
+
+	rec.record(kvbase.OpDelete, bucket, key, nil, nil, err, false)
+
+	return err
+}
+
+// DeleteBatch removes every provided key as one all-or-nothing operation, recorded as a single entry holding every key
+func (rec *recorder) DeleteBatch(bucket string, keys []string) error {
+	err := rec.Backend.DeleteBatch(bucket, keys)
+	rec.record(kvbase.OpDeleteBatch, bucket, "", nil, marshal(keys), err, false)
+
+	return err
+}
+
+// DeletePrefix removes every record whose key starts with prefix, recorded with the prefix in place of the key
+func (rec *recorder) DeletePrefix(bucket string, prefix string) (int, error) {
+	counter, err := rec.Backend.DeletePrefix(bucket, prefix)
+	rec.record(kvbase.OpDeletePrefix, bucket, prefix, nil, nil, err, false)
+
+	return counter, err
+}
+
+// Drop deletes a bucket (and all of its contents) from the backend
+func (rec *recorder) Drop(bucket string) error {
+	return rec.DropCtx(context.Background(), bucket)
+}
+
+// DropCtx is Drop, returning the context's error once ctx is done
+func (rec *recorder) DropCtx(ctx context.Context, bucket string) error {
+	var err error
+
+	if store, ok := rec.Backend.(kvbase.BackendCtx); ok {
+		err = store.DropCtx(ctx, bucket)
+	} else if err = ctx.Err(); err == nil {
+		err = rec.Backend.Drop(bucket)
+	}
+
+	rec.record(kvbase.OpDrop, bucket, "", nil, nil, err, false)
+
+	return err
+}
+
+// ForEach calls fn with the key of every record inside of the provided bucket. The records fn was called with are
+// recorded in order, so that replaying stops after as many records. An error returned by fn is the caller's own
+// decision to stop, and isn't recorded as a failure of the store.
+func (rec *recorder) ForEach(bucket string, model interface{}, fn func(key string) error) error {
+	var visited []forEachRecord
+	var fnErr error
+
+	err := rec.Backend.ForEach(bucket, model, func(key string) error {
+		visited = append(visited, forEachRecord{key, marshal(model)})
+
+		fnErr = fn(key)
+		return fnErr
+	})
+
+	recorded := err
+	if fnErr != nil && errors.Is(err, fnErr) {
+		recorded = nil
+	}
+
+	rec.record(kvbase.OpForEach, bucket, "", forEachArgs{len(visited)}, result(visited, recorded), recorded, true)
+
+	return err
+}
+
+// FragmentationReport forwards to store's report when it has one. It isn't recorded, as it doesn't touch records.
+func (rec *recorder) FragmentationReport(fn func(stats kvbase.BucketFragmentation) error) (*kvbase.FragmentationReport, error) {
+	return kvbase.Fragmentation(rec.Backend, fn)
+}
+
+// Get returns all records inside of the provided bucket
+func (rec *recorder) Get(bucket string, model interface{}) (*map[string]interface{}, error) {
+	return rec.GetCtx(context.Background(), bucket, model)
+}
+
+// GetCtx is Get, returning the context's error once ctx is done
+func (rec *recorder) GetCtx(ctx context.Context, bucket string, model interface{}) (*map[string]interface{}, error) {
+	var results *map[string]interface{}
+	var err error
+
+	if store, ok := rec.Backend.(kvbase.BackendCtx); ok {
+		results, err = store.GetCtx(ctx, bucket, model)
+	} else if err = ctx.Err(); err == nil {
+		results, err = rec.Backend.Get(bucket, model)
+	}
+
+	rec.record(kvbase.OpGet, bucket, "", nil, result(results, err), err, true)
+
+	return results, err
+}
+
+// GetPage returns up to limit records after the startAfter key, recorded along with the returned cursor
+func (rec *recorder) GetPage(bucket string, model interface{}, limit int, startAfter string) (*map[string]interface{}, string, error) {
+	results, cursor, err := rec.Backend.GetPage(bucket, model, limit, startAfter)
+	rec.record(kvbase.OpGetPage, bucket, "", pageArgs{limit, startAfter}, result(pageResult{results, cursor}, err), err, true)
+
+	return results, cursor, err
+}
+
+// GetPageReverse returns up to limit records before the startBefore key, recorded along with the returned cursor
+func (rec *recorder) GetPageReverse(bucket string, model interface{}, limit int, startBefore string) (*map[string]interface{}, string, error) {
+	results, cursor, err := rec.Backend.GetPageReverse(bucket, model, limit, startBefore)
+	rec.record(kvbase.OpGetPageReverse, bucket, "", pageArgs{limit, startBefore}, result(pageResult{results, cursor}, err), err, true)
+
+	return results, cursor, err
+}
+
+// GetPrefix returns the records whose keys start with prefix, recorded with the prefix in place of the key
+func (rec *recorder) GetPrefix(bucket string, prefix string, model interface{}) (*map[string]interface{}, error) {
+	results, err := rec.Backend.GetPrefix(bucket, prefix, model)
+	rec.record(kvbase.OpGetPrefix, bucket, prefix, nil, result(results, err), err, true)
+
+	return results, err
+}
+
+// GetRange returns the records whose keys are >= startKey and < endKey
+func (rec *recorder) GetRange(bucket string, startKey string, endKey string, model interface{}) (*map[string]interface{}, error) {
+	results, err := rec.Backend.GetRange(bucket, startKey, endKey, model)
+	rec.record(kvbase.OpGetRange, bucket, "", rangeArgs{startKey, endKey}, result(results, err), err, true)
+
+	return results, err
+}
+
+// Keys returns the keys of every record inside of the provided bucket in sorted order
+func (rec *recorder) Keys(bucket string) ([]string, error) {
+	keys, err := rec.Backend.Keys(bucket)
+	rec.record(kvbase.OpKeys, bucket, "", nil, result(keys, err), err, true)
+
+	return keys, err
+}
+
+// Read returns a single struct from the provided bucket, using the provided key
+func (rec *recorder) Read(bucket string, key string, model interface{}) error {
+	return rec.ReadCtx(context.Background(), bucket, key, model)
+}
+
+// ReadCtx is Read, returning the context's error once ctx is done
+func (rec *recorder) ReadCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	var err error
+
+	if store, ok := rec.Backend.(kvbase.BackendCtx); ok {
+		err = store.ReadCtx(ctx, bucket, key, model)
+	} else if err = ctx.Err(); err == nil {
+		err = rec.Backend.Read(bucket, key, model)
+	}
+
+	rec.record(kvbase.OpRead, bucket, key, nil, result(model, err), err, true)
+
+	return err
+}
+
+// RenameBucket moves every record of oldName into newName, recorded with the new name in place of the key
+func (rec *recorder) RenameBucket(oldName string, newName string) error {
+	err := rec.Backend.RenameBucket(oldName, newName)
+	rec.record(kvbase.OpRenameBucket, oldName, newName, nil, nil, err, false)
+
+	return err
+}
+
+// Restore loads a stream written by Backup. The stream is read in full so that it can be recorded, and replayed.
+func (rec *recorder) Restore(r io.Reader, wipe bool) error {
+	data, err := ioutil.ReadAll(r)
+	if err == nil {
+		err = rec.Backend.Restore(bytes.NewReader(data), wipe)
+	}
+
+	rec.record(kvbase.OpRestore, "", "", restoreArgs{wipe}, marshal(data), err, false)
+
+	return err
+}
+
+// Stats forwards to store's statistics when it reports them. They aren't recorded, as they don't touch records.
+func (rec *recorder) Stats() (*kvbase.StoreStats, error) {
+	return kvbase.Stats(rec.Backend)
+}
+
+// Update modifies an existing record from the backend, inside of the provided bucket, using the provided key
+func (rec *recorder) Update(bucket string, key string, model interface{}) error {
+	return rec.UpdateCtx(context.Background(), bucket, key, model)
+}
+
+// UpdateCtx is Update, returning the context's error once ctx is done
+func (rec *recorder) UpdateCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	var err error
+
+	if store, ok := rec.Backend.(kvbase.BackendCtx); ok {
+		err = store.UpdateCtx(ctx, bucket, key, model)
+	} else if err = ctx.Err(); err == nil {
+		err = rec.Backend.Update(bucket, key, model)
+	}
+
+	rec.record(kvbase.OpUpdate, bucket, key, nil, marshal(model), err, false)
+
+	return err
+}
+
+// Upsert inserts or replaces a record, regardless of whether the key already exists
+func (rec *recorder) Upsert(bucket string, key string, model interface{}) error {
+	return rec.UpsertCtx(context.Background(), bucket, key, model)
+}
+
+// UpsertCtx is Upsert, returning the context's error once ctx is done
+func (rec *recorder) UpsertCtx(ctx context.Context, bucket string, key string, model interface{}) error {
+	var err error
+
+	if store, ok := rec.Backend.(kvbase.BackendCtx); ok {
+		err = store.UpsertCtx(ctx, bucket, key, model)
+	} else if err = ctx.Err(); err == nil {
+		err = rec.Backend.Upsert(bucket, key, model)
+	}
+
+	rec.record(kvbase.OpUpsert, bucket, key, nil, marshal(model), err, false)
+
+	return err
+}
+
+func (rec *recorder) record(op kvbase.Operation, bucket string, key string, args interface{}, value []byte, err error, read bool) {
+	entry := Entry{
+		Op:     op.String(),
+		Bucket: bucket,
+		Key:    key,
+		Time:   time.Now(),
+	}
+
+	if args != nil {
+		entry.Args = marshal(args)
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if value != nil && (read && rec.hash || payloads[op] && rec.hashValues) {
+		entry.Hash = hash(value)
+	} else {
+		entry.Value = value
+	}
+
+	rec.mux.Lock()
+	defer rec.mux.Unlock()
+
+	// Recording is best effort and must never change the outcome of the operation
+	_ = rec.encoder.Encode(&entry)
+}
+
+// reads holds the operations whose results are compared on replay
+var reads = map[kvbase.Operation]bool{
+	kvbase.OpBackup:         true,
+	kvbase.OpBuckets:        true,
+	kvbase.OpCount:          true,
+	kvbase.OpCountPrefix:    true,
+	kvbase.OpForEach:        true,
+	kvbase.OpGet:            true,
+	kvbase.OpGetPage:        true,
+	kvbase.OpGetPageReverse: true,
+	kvbase.OpGetPrefix:      true,
+	kvbase.OpGetRange:       true,
+	kvbase.OpKeys:           true,
+	kvbase.OpRead:           true,
+}
+
+// payloads holds the mutations whose recorded value is the data they write, which WithHashedValues hashes
+var payloads = map[kvbase.Operation]bool{
+	kvbase.OpCreate:      true,
+	kvbase.OpCreateBatch: true,
+	kvbase.OpRestore:     true,
+	kvbase.OpUpdate:      true,
+	kvbase.OpUpsert:      true,
+}
+
+// Replay re-executes the mutations recorded in r against target and verifies that every recorded read returns the
+// same result. Only the presence of errors is compared, as drivers word their errors differently. Mutations recorded
+// with WithHashedValues are listed in the report's VerifyOnly instead of being replayed.
+func Replay(r io.Reader, target kvbase.Backend) (*ReplayReport, error) {
+	report := ReplayReport{}
+	decoder := json.NewDecoder(r)
+
+	for line := 1; ; line++ {
+		var entry Entry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			return &report, nil
+		} else if err != nil {
+			return &report, errors.New("kvrecord: invalid entry on line " + strconv.Itoa(line) + ": " + err.Error())
+		}
+
+		op, err := kvbase.ParseOperation(entry.Op)
+		if err != nil {
+			return &report, err
+		}
+
+		if payloads[op] && entry.Hash != "" {
+			report.VerifyOnly = append(report.VerifyOnly, line)
+
+			continue
+		}
+
+		value, err := replay(op, entry, target)
+
+		read := reads[op]
+		if read {
+			report.Reads++
+		} else {
+			report.Mutations++
+		}
+
+		if (entry.Error != "") != (err != nil) {
+			report.Divergences = append(report.Divergences, Divergence{line, entry, describe(entry.Error), describe(err)})
+
+			continue
+		}
+
+		if !read || err != nil {
+			continue
+		}
+
+		if entry.Hash != "" {
+			if actual := hash(value); actual != entry.Hash {
+				report.Divergences = append(report.Divergences, Divergence{line, entry, entry.Hash, actual})
+			}
+		} else if expected, actual := compact(entry.Value), compact(value); expected != actual {
+			report.Divergences = append(report.Divergences, Divergence{line, entry, expected, actual})
+		}
+	}
+}
+
+// replay re-executes a single entry against target, returning the result of reads in the form they were recorded in
+func replay(op kvbase.Operation, entry Entry, target kvbase.Backend) ([]byte, error) {
+	switch op {
+	case kvbase.OpBackup:
+		return nil, target.Backup(ioutil.Discard)
+	case kvbase.OpBuckets:
+		buckets, err := target.Buckets()
+		return result(buckets, err), err
+	case kvbase.OpCount:
+		counter, err := target.Count(entry.Bucket)
+		return []byte(strconv.Itoa(counter)), err
+	case kvbase.OpCountPrefix:
+		counter, err := target.CountPrefix(entry.Bucket, entry.Key)
+		return []byte(strconv.Itoa(counter)), err
+	case kvbase.OpCreate:
+		return nil, target.Create(entry.Bucket, entry.Key, entry.Value)
+	case kvbase.OpCreateBatch:
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(entry.Value, &values); err != nil {
+			return nil, err
+		}
+
+		records := make(map[string]interface{}, len(values))
+		for key, value := range values {
+			records[key] = value
+		}
+
+		return nil, target.CreateBatch(entry.Bucket, records)
+	case kvbase.OpDelete:
+		return nil, target.Delete(entry.Bucket, entry.Key)
+	case kvbase.OpDeleteBatch:
+		var keys []string
+		if err := json.Unmarshal(entry.Value, &keys); err != nil {
+			return nil, err
+		}
+
+		return nil, target.DeleteBatch(entry.Bucket, keys)
+	case kvbase.OpDeletePrefix:
+		_, err := target.DeletePrefix(entry.Bucket, entry.Key)
+		return nil, err
+	case kvbase.OpDrop:
+		return nil, target.Drop(entry.Bucket)
+	case kvbase.OpForEach:
+		var args forEachArgs
+		if err := json.Unmarshal(entry.Args, &args); err != nil {
+			return nil, err
+		}
+
+		var raw json.RawMessage
+		visited := []forEachRecord{}
+
+		err := target.ForEach(entry.Bucket, &raw, func(key string) error {
+			if len(visited) == args.Visited {
+				return kvbase.ErrStopIteration
+			}
+
+			visited = append(visited, forEachRecord{key, append(json.RawMessage{}, raw...)})
+			return nil
+		})
+
+		return result(visited, err), err
+	case kvbase.OpGet:
+		results, err := target.Get(entry.Bucket, nil)
+		return result(results, err), err
+	case kvbase.OpGetPage, kvbase.OpGetPageReverse:
+		var args pageArgs
+		if err := json.Unmarshal(entry.Args, &args); err != nil {
+			return nil, err
+		}
+
+		page := target.GetPage
+		if op == kvbase.OpGetPageReverse {
+			page = target.GetPageReverse
+		}
+
+		results, cursor, err := page(entry.Bucket, nil, args.Limit, args.Cursor)
+		return result(pageResult{results, cursor}, err), err
+	case kvbase.OpGetPrefix:
+		results, err := target.GetPrefix(entry.Bucket, entry.Key, nil)
+		return result(results, err), err
+	case kvbase.OpGetRange:
+		var args rangeArgs
+		if err := json.Unmarshal(entry.Args, &args); err != nil {
+			return nil, err
+		}
+
+		results, err := target.GetRange(entry.Bucket, args.Start, args.End, nil)
+		return result(results, err), err
+	case kvbase.OpKeys:
+		keys, err := target.Keys(entry.Bucket)
+		return result(keys, err), err
+	case kvbase.OpRead:
+		var raw json.RawMessage
+		err := target.Read(entry.Bucket, entry.Key, &raw)
+		return result(raw, err), err
+	case kvbase.OpRenameBucket:
+		return nil, target.RenameBucket(entry.Bucket, entry.Key)
+	case kvbase.OpRestore:
+		var args restoreArgs
+		if err := json.Unmarshal(entry.Args, &args); err != nil {
+			return nil, err
+		}
+
+		var data []byte
+		if err := json.Unmarshal(entry.Value, &data); err != nil {
+			return nil, err
+		}
+
+		return nil, target.Restore(bytes.NewReader(data), args.Wipe)
+	case kvbase.OpUpdate:
+		return nil, target.Update(entry.Bucket, entry.Key, entry.Value)
+	case kvbase.OpUpsert:
+		return nil, target.Upsert(entry.Bucket, entry.Key, entry.Value)
+	}
+
+	return nil, errors.New("kvrecord: operation " + entry.Op + " can't be replayed")
+}
+
+func compact(value []byte) string {
+	var buffer bytes.Buffer
+	if err := json.Compact(&buffer, value); err != nil {
+		return string(value)
+	}
+
+	return buffer.String()
+}
+
+func describe(value interface{}) string {
+	switch value := value.(type) {
+	case error:
+		if value != nil {
+			return "error: " + value.Error()
+		}
+	case string:
+		if value != "" {
+			return "error: " + value
+		}
+	}
+
+	return "success"
+}
+
+func hash(value []byte) string {
+	sum := sha256.Sum256([]byte(compact(value)))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func marshal(model interface{}) []byte {
+	data, err := json.Marshal(model)
+	if err != nil {
+		return nil
+	}
+
+	return data
+}
+
+// result marshals the result of a read, or returns nil when the read failed
+func result(value interface{}, err error) []byte {
+	if err != nil {
+		return nil
+	}
+
+	return marshal(value)
+}
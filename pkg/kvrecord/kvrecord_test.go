@@ -0,0 +1,181 @@
+package kvrecord_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/Wolveix/kvbase"
+	"github.com/Wolveix/kvbase/pkg/kvbasetest"
+	"github.com/Wolveix/kvbase/pkg/kvrecord"
+	"testing"
+)
+
+type model struct {
+	Name string
+}
+
+func record(t *testing.T, opts ...kvrecord.Option) *bytes.Buffer {
+	var log bytes.Buffer
+	store := kvrecord.Wrap(kvbasetest.New(t, kvbasetest.WithDriver("bboltdb")), &log, opts...)
+
+	_ = store.Create("users", "john", &model{"John Smith"})
+	_ = store.Create("users", "john", &model{"John Smith"})
+	_ = store.Create("users", "james", &model{"James Green"})
+	_ = store.Update("users", "james", &model{"James Brown"})
+	_ = store.Update("users", "missing", &model{"Nobody"})
+	_ = store.Read("users", "james", &model{})
+	_ = store.Read("users", "missing", &model{})
+	_, _ = store.Count("users")
+	_, _ = store.Get("users", model{})
+	_ = store.Delete("users", "john")
+	_ = store.Drop("users")
+
+	_ = store.CreateBatch("orders", map[string]interface{}{"a1": &model{"A1"}, "a2": &model{"A2"}, "b1": &model{"B1"}})
+	_ = store.CreateBatch("orders", map[string]interface{}{"a1": &model{"A1"}})
+	_, _ = store.CountPrefix("orders", "a")
+	_, _ = store.GetPrefix("orders", "a", model{})
+	_, _ = store.GetRange("orders", "a2", "b2", model{})
+	_, _, _ = store.GetPage("orders", model{}, 2, "")
+	_, _, _ = store.GetPageReverse("orders", model{}, 2, "b1")
+	_ = store.ForEach("orders", &model{}, func(key string) error {
+		if key == "a2" {
+			return kvbase.ErrStopIteration
+		}
+
+		return nil
+	})
+	_, _ = store.Keys("orders")
+	_, _ = store.Buckets()
+
+	var backup bytes.Buffer
+	_ = store.Backup(&backup)
+	_ = store.DeleteBatch("orders", []string{"a1", "b1"})
+	_ = store.Restore(&backup, false)
+	_, _ = store.Keys("orders")
+	_ = store.Drop("orders")
+
+	return &log
+}
+
+func TestReplay(t *testing.T) {
+	log := record(t)
+
+	report, err := kvrecord.Replay(log, kvbasetest.New(t, kvbasetest.WithDriver("leveldb")))
+	if err != nil {
+		t.Fatal("Error on replay:", err)
+	}
+
+	if report.Mutations != 12 || report.Reads != 14 {
+		t.Fatal("Expected 12 mutations and 14 reads, got", report.Mutations, report.Reads)
+	}
+
+	if len(report.Divergences) != 0 {
+		t.Fatal("Expected no divergences, got", report.Divergences)
+	}
+}
+
+func TestReplayDivergence(t *testing.T) {
+	log := record(t, kvrecord.WithHashedReads())
+
+	if bytes.Contains(log.Bytes(), []byte(`"op":"read","bucket":"users","key":"james","value"`)) {
+		t.Fatal("Expected read results to be hashed")
+	}
+
+	target := kvbasetest.New(t, kvbasetest.WithDriver("leveldb"), kvbasetest.WithFixture(map[string]map[string]interface{}{
+		"users": {"extra": &model{"Extra"}},
+	}))
+
+	report, err := kvrecord.Replay(log, target)
+	if err != nil {
+		t.Fatal("Error on replay:", err)
+	}
+
+	// The extra record changes the result of Count and Get, but not of Read
+	if len(report.Divergences) != 2 {
+		t.Fatal("Expected 2 divergences, got", report.Divergences)
+	}
+
+	if report.Divergences[0].Entry.Op != "count" || report.Divergences[1].Entry.Op != "get" {
+		t.Fatal("Expected count and get to diverge, got", report.Divergences)
+	}
+}
+
+func TestRecordBatch(t *testing.T) {
+	var log bytes.Buffer
+	store := kvrecord.Wrap(kvbasetest.New(t, kvbasetest.WithDriver("bboltdb")), &log)
+
+	_ = store.CreateBatch("users", map[string]interface{}{"james": &model{"James Green"}, "john": &model{"John Smith"}})
+	_ = store.DeleteBatch("users", []string{"james", "john"})
+
+	var entries []kvrecord.Entry
+	for decoder := json.NewDecoder(&log); decoder.More(); {
+		var entry kvrecord.Entry
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatal("Error on entry decode:", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 2 || entries[0].Op != "create_batch" || entries[1].Op != "delete_batch" {
+		t.Fatal("Expected each batch to be recorded as a single entry, got", entries)
+	}
+}
+
+func TestRecordCtx(t *testing.T) {
+	var log bytes.Buffer
+	store, ok := kvrecord.Wrap(kvbasetest.New(t, kvbasetest.WithDriver("bboltdb")), &log).(kvbase.BackendCtx)
+	if !ok {
+		t.Fatal("Expected the recorder to implement BackendCtx")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := store.CreateCtx(ctx, "users", "john", &model{"John Smith"}); err != context.Canceled {
+		t.Fatal("Expected context.Canceled, got", err)
+	}
+
+	if !bytes.Contains(log.Bytes(), []byte(`"op":"create"`)) {
+		t.Fatal("Expected the cancelled create to be recorded, got", log.String())
+	}
+}
+
+func TestReplayHashedValues(t *testing.T) {
+	log := record(t, kvrecord.WithHashedValues())
+
+	if bytes.Contains(log.Bytes(), []byte("Name")) {
+		t.Fatal("Expected no record to be written to the log, got", log.String())
+	}
+
+	report, err := kvrecord.Replay(log, kvbasetest.New(t, kvbasetest.WithDriver("leveldb")))
+	if err != nil {
+		t.Fatal("Error on replay:", err)
+	}
+
+	// Creates, updates, batch creates and the restore are skipped, leaving deletes and drops to be replayed
+	if len(report.VerifyOnly) != 8 || report.VerifyOnly[0] != 1 || report.Mutations != 4 || report.Reads != 14 {
+		t.Fatal("Expected 8 verify-only mutations, 4 mutations and 14 reads, got", report.VerifyOnly, report.Mutations, report.Reads)
+	}
+}
+
+func TestWrapOptionalInterfaces(t *testing.T) {
+	var log bytes.Buffer
+	store := kvrecord.Wrap(kvbasetest.New(t, kvbasetest.WithDriver("bboltdb")), &log)
+
+	if _, err := kvbase.Stats(store); err != nil {
+		t.Fatal("Expected Stats to be forwarded, got", err)
+	}
+
+	if _, err := kvbase.Fragmentation(store, nil); err != nil {
+		t.Fatal("Expected Fragmentation to be forwarded, got", err)
+	}
+
+	if err := kvbase.Tx(store, func(tx kvbase.Transaction) error { return nil }); err != kvbase.ErrTransactionsUnsupported {
+		t.Fatal("Expected transactions to be hidden, got", err)
+	}
+
+	if _, err := kvbase.Stats(kvrecord.Wrap(kvbasetest.New(t, kvbasetest.WithDriver("memory")), &log)); err != kvbase.ErrNotSupported {
+		t.Fatal("Expected ErrNotSupported from a store without statistics, got", err)
+	}
+}
@@ -0,0 +1,347 @@
+// Package kvstatic publishes buckets as static files that can be served from any file server or CDN, and reads them
+// back without running kvbase.
+//
+// Each exported bucket is laid out as:
+//
+//	<bucket>/index.json        {"version":1,"shards":256,"count":N}
+//	<bucket>/shards/<xx>.json  a JSON object of the records whose key hashes to shard xx, sorted by key
+//
+// Keys are assigned to shards by their FNV-1a hash, so regenerating an unchanged bucket produces identical files.
+package kvstatic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Wolveix/kvbase"
+	"github.com/Wolveix/kvbase/internal/atomicfile"
+	"github.com/Wolveix/kvbase/pkg/kvdriver"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// FormatVersion is the layout version written to, and accepted from, index files
+const FormatVersion = 1
+
+// DefaultShards is the number of shard files a bucket is split into unless WithShards is used
+const DefaultShards = 256
+
+type index struct {
+	Version int `json:"version"`
+	Shards  int `json:"shards"`
+	Count   int `json:"count"`
+}
+
+type options struct {
+	shards int
+}
+
+// Option configures an export
+type Option func(*options)
+
+// WithShards splits each bucket into n shard files, between 1 and 256
+func WithShards(n int) Option {
+	return func(opts *options) {
+		opts.shards = n
+	}
+}
+
+// Export writes every record of the provided bucket into dir
+func Export(store kvbase.Backend, dir string, bucket string, opts ...Option) error {
+	config := options{
+		shards: DefaultShards,
+	}
+
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if config.shards < 1 || config.shards > 256 {
+		return errors.New("kvbase: static exports support between 1 and 256 shards")
+	}
+
+	name, err := bucketPath(bucket)
+	if err != nil {
+		return err
+	}
+
+	keys, err := store.Keys(bucket)
+	if err != nil {
+		return err
+	}
+
+	shards := make([]map[string]json.RawMessage, config.shards)
+	for i := range shards {
+		shards[i] = make(map[string]json.RawMessage)
+	}
+
+	for _, key := range keys {
+		var raw json.RawMessage
+		if err := store.Read(bucket, key, &raw); err != nil {
+			return err
+		}
+
+		shards[shard(key, config.shards)][key] = raw
+	}
+
+	root := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Join(root, "shards"), 0755); err != nil {
+		return err
+	}
+
+	// Maps are marshalled in sorted key order, keeping the output deterministic
+	for i, records := range shards {
+		data, err := json.Marshal(records)
+		if err != nil {
+			return err
+		}
+
+		if err := atomicfile.WriteFile(filepath.Join(root, "shards", shardName(i)), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(index{FormatVersion, config.shards, len(keys)})
+	if err != nil {
+		return err
+	}
+
+	// The index is written last so that readers never see it before the shards it describes
+	return atomicfile.WriteFile(filepath.Join(root, "index.json"), data, 0644)
+}
+
+// Opener returns the contents of the file at the provided slash-separated path, relative to the export's root
+type Opener func(name string) (io.ReadCloser, error)
+
+// Dir returns an Opener reading an export from a local directory
+func Dir(dir string) Opener {
+	return func(name string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+	}
+}
+
+// HTTP returns an Opener fetching an export from baseURL. When client is nil, http.DefaultClient is used.
+func HTTP(baseURL string, client *http.Client) Opener {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return func(name string) (io.ReadCloser, error) {
+		resp, err := client.Get(baseURL + "/" + name)
+		if err != nil {
+			return nil, err
+		}
+
+		// A missing file is reported the way Dir reports it, so that missing buckets are recognised either way
+		if resp.StatusCode == http.StatusNotFound {
+			resp.Body.Close()
+
+			return nil, &os.PathError{Op: "get", Path: baseURL + "/" + name, Err: os.ErrNotExist}
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+
+			return nil, fmt.Errorf("kvbase: unable to fetch %s: %s", name, resp.Status)
+		}
+
+		return resp.Body, nil
+	}
+}
+
+type reader struct {
+	indexes map[string]*index
+	mux     sync.Mutex
+	open    Opener
+	shards  map[string]map[string]json.RawMessage
+}
+
+// NewReader returns a Reader over an export. Index and shard files are fetched on demand and cached in memory.
+func NewReader(open Opener) kvbase.Reader {
+	return &reader{
+		indexes: make(map[string]*index),
+		open:    open,
+		shards:  make(map[string]map[string]json.RawMessage),
+	}
+}
+
+// Count returns the total number of records inside of the provided bucket
+func (r *reader) Count(bucket string) (int, error) {
+	idx, err := r.index(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	return idx.Count, nil
+}
+
+// Get returns all records inside of the provided bucket, fetching every shard
+func (r *reader) Get(bucket string, model interface{}) (*map[string]interface{}, error) {
+	idx, err := r.index(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]interface{}, idx.Count)
+
+	for i := 0; i < idx.Shards; i++ {
+		records, err := r.shard(bucket, i)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, data := range records {
+			value := kvdriver.NewModel(model)
+			if err := json.Unmarshal(data, &value); err != nil {
+				return nil, err
+			}
+
+			results[key] = value
+		}
+	}
+
+	return &results, nil
+}
+
+// Keys returns the keys of every record inside of the provided bucket in sorted order, fetching every shard
+func (r *reader) Keys(bucket string) ([]string, error) {
+	idx, err := r.index(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, idx.Count)
+
+	for i := 0; i < idx.Shards; i++ {
+		records, err := r.shard(bucket, i)
+		if err != nil {
+			return nil, err
+		}
+
+		for key := range records {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Read returns a single struct from the provided bucket, fetching only the shard holding the key
+func (r *reader) Read(bucket string, key string, model interface{}) error {
+	idx, err := r.index(bucket)
+	if err != nil {
+		return err
+	}
+
+	records, err := r.shard(bucket, shard(key, idx.Shards))
+	if err != nil {
+		return err
+	}
+
+	data, ok := records[key]
+	if !ok {
+		return kvbase.ErrKeyNotFound
+	}
+
+	return json.Unmarshal(data, &model)
+}
+
+func (r *reader) index(bucket string) (*index, error) {
+	r.mux.Lock()
+	idx, ok := r.indexes[bucket]
+	r.mux.Unlock()
+
+	if ok {
+		return idx, nil
+	}
+
+	name, err := bucketPath(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	idx = &index{}
+	if err := r.fetch(path.Join(name, "index.json"), idx); os.IsNotExist(err) {
+		return nil, kvbase.WrapError(kvbase.ErrBucketNotFound, err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if idx.Version != FormatVersion || idx.Shards < 1 || idx.Shards > 256 {
+		return nil, fmt.Errorf("kvbase: unsupported static export (version %d, %d shards)", idx.Version, idx.Shards)
+	}
+
+	r.mux.Lock()
+	r.indexes[bucket] = idx
+	r.mux.Unlock()
+
+	return idx, nil
+}
+
+func (r *reader) shard(bucket string, i int) (map[string]json.RawMessage, error) {
+	name := path.Join(url.PathEscape(bucket), "shards", shardName(i))
+
+	r.mux.Lock()
+	records, ok := r.shards[name]
+	r.mux.Unlock()
+
+	if ok {
+		return records, nil
+	}
+
+	if err := r.fetch(name, &records); err != nil {
+		return nil, err
+	}
+
+	r.mux.Lock()
+	r.shards[name] = records
+	r.mux.Unlock()
+
+	return records, nil
+}
+
+func (r *reader) fetch(name string, v interface{}) error {
+	file, err := r.open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// bucketPath returns the directory an export of bucket is laid out in. Its name is escaped, except for dots, so the
+// names that would resolve to the export's root or its parent are refused.
+func bucketPath(bucket string) (string, error) {
+	if bucket == "" || bucket == "." || bucket == ".." {
+		return "", kvbase.WrapError(kvbase.ErrInvalidBucket, errors.New("bucket "+strconv.Quote(bucket)+" can't be exported as a directory"))
+	}
+
+	return url.PathEscape(bucket), nil
+}
+
+func shard(key string, shards int) int {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+
+	return int(hash.Sum32() % uint32(shards))
+}
+
+func shardName(i int) string {
+	return fmt.Sprintf("%02x.json", i)
+}
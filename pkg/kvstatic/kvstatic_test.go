@@ -0,0 +1,195 @@
+package kvstatic_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/Wolveix/kvbase"
+	"github.com/Wolveix/kvbase/pkg/kvbasetest"
+	"github.com/Wolveix/kvbase/pkg/kvstatic"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type user struct {
+	Name string
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	store := kvbasetest.New(t, kvbasetest.WithFixture(map[string]map[string]interface{}{
+		"users": {
+			"john":  &user{"John"},
+			"james": &user{"James"},
+			"jane":  &user{"Jane"},
+		},
+	}))
+
+	dir, err := ioutil.TempDir("", "kvstatic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Two shards guarantee that some of the three keys share a shard
+	if err := kvstatic.Export(store, dir, "users", kvstatic.WithShards(2)); err != nil {
+		t.Fatal("Error on export:", err)
+	}
+
+	var opened []string
+	open := kvstatic.Dir(dir)
+
+	reader := kvstatic.NewReader(func(name string) (io.ReadCloser, error) {
+		opened = append(opened, name)
+
+		return open(name)
+	})
+
+	if err := reader.Read("users", "missing", &user{}); !errors.Is(err, kvbase.ErrKeyNotFound) {
+		t.Fatal("Expected ErrKeyNotFound, got", err)
+	}
+
+	if len(opened) != 2 {
+		t.Fatal("Expected a missing key to fetch the index and a single shard, got", opened)
+	}
+
+	for _, name := range []string{"john", "james", "jane"} {
+		model := user{}
+		if err := reader.Read("users", name, &model); err != nil {
+			t.Fatal("Error on read:", err)
+		}
+
+		if model.Name == "" {
+			t.Fatal("Expected", name, "to round-trip")
+		}
+	}
+
+	if len(opened) != 3 {
+		t.Fatal("Expected shards to be cached after their first fetch, got", opened)
+	}
+
+	counter, err := reader.Count("users")
+	if err != nil || counter != 3 {
+		t.Fatal("Expected 3 from counter, got", counter, err)
+	}
+
+	results, err := reader.Get("users", &user{})
+	if err != nil {
+		t.Fatal("Error on get:", err)
+	}
+
+	if (*results)["jane"].(*user).Name != "Jane" {
+		t.Fatal("Expected Jane, got", (*results)["jane"])
+	}
+
+	if keys, err := reader.Keys("users"); err != nil || strings.Join(keys, ",") != "james,jane,john" {
+		t.Fatal("Expected every key in sorted order, got", keys, err)
+	}
+
+	if _, err := reader.Count("missing"); !errors.Is(err, kvbase.ErrBucketNotFound) {
+		t.Fatal("Expected ErrBucketNotFound, got", err)
+	}
+}
+
+func TestExportDeterministic(t *testing.T) {
+	records := make(map[string]interface{})
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		records[name] = &user{name}
+	}
+
+	store := kvbasetest.New(t, kvbasetest.WithFixture(map[string]map[string]interface{}{"users": records}))
+
+	var exports [2]string
+
+	for i := range exports {
+		dir, err := ioutil.TempDir("", "kvstatic")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		if err := kvstatic.Export(store, dir, "users", kvstatic.WithShards(4)); err != nil {
+			t.Fatal("Error on export:", err)
+		}
+
+		exports[i] = dir
+	}
+
+	for _, name := range []string{"index.json", "shards/00.json", "shards/01.json", "shards/02.json", "shards/03.json"} {
+		first, err := ioutil.ReadFile(filepath.Join(exports[0], "users", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		second, err := ioutil.ReadFile(filepath.Join(exports[1], "users", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(first, second) {
+			t.Fatal("Expected identical exports for", name)
+		}
+	}
+}
+
+func TestExportInvalidBucket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kvstatic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "root")
+	store := kvbasetest.New(t)
+	reader := kvstatic.NewReader(kvstatic.Dir(root))
+
+	for _, bucket := range []string{"", ".", ".."} {
+		if err := kvstatic.Export(store, root, bucket); !errors.Is(err, kvbase.ErrInvalidBucket) {
+			t.Fatalf("Expected ErrInvalidBucket exporting %q, got: %v", bucket, err)
+		}
+
+		if _, err := reader.Keys(bucket); !errors.Is(err, kvbase.ErrInvalidBucket) {
+			t.Fatalf("Expected ErrInvalidBucket reading %q, got: %v", bucket, err)
+		}
+	}
+
+	if files, _ := ioutil.ReadDir(dir); len(files) != 0 {
+		t.Fatal("Expected nothing to be written, got", len(files), "files")
+	}
+}
+
+func TestHTTPReader(t *testing.T) {
+	store := kvbasetest.New(t, kvbasetest.WithFixture(map[string]map[string]interface{}{
+		"users": {"john": &user{"John"}},
+	}))
+
+	dir, err := ioutil.TempDir("", "kvstatic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := kvstatic.Export(store, dir, "users"); err != nil {
+		t.Fatal("Error on export:", err)
+	}
+
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+
+	model := user{}
+	if err := kvstatic.NewReader(kvstatic.HTTP(server.URL, nil)).Read("users", "john", &model); err != nil {
+		t.Fatal("Error on read:", err)
+	}
+
+	if model.Name != "John" {
+		t.Fatal("Expected John, got", model.Name)
+	}
+
+	if _, err := kvstatic.NewReader(kvstatic.HTTP(server.URL, nil)).Count("missing"); !errors.Is(err, kvbase.ErrBucketNotFound) {
+		t.Fatal("Expected ErrBucketNotFound, got", err)
+	}
+}
@@ -0,0 +1,22 @@
+package kvbase
+
+// StoreStats describes the disk usage of a store. Driver holds backend-specific figures.
+type StoreStats struct {
+	DiskBytes int64
+	Driver    map[string]interface{}
+}
+
+// StatsReporter is implemented by backends able to report on their disk usage
+type StatsReporter interface {
+	Stats() (*StoreStats, error)
+}
+
+// Stats returns the disk usage statistics of the provided store
+func Stats(store Backend) (*StoreStats, error) {
+	reporter, ok := store.(StatsReporter)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+
+	return reporter.Stats()
+}
@@ -0,0 +1,35 @@
+package kvbase
+
+import "errors"
+
+// ErrTransactionsUnsupported is returned by Tx when the backend can't apply several operations atomically
+var ErrTransactionsUnsupported = errors.New("kvbase: transactions not supported by backend")
+
+// Transaction exposes the record operations of a backend, scoped to a single atomic transaction
+type Transaction interface {
+	Create(bucket string, key string, model interface{}) error
+	Delete(bucket string, key string) error
+	Read(bucket string, key string, model interface{}) error
+	Update(bucket string, key string, model interface{}) error
+}
+
+// Transactor is implemented by backends able to apply several operations atomically
+type Transactor interface {
+	Tx(fn func(tx Transaction) error) error
+}
+
+// Tx runs fn inside of a single transaction of the provided store. If fn returns an error, every operation made
+// through tx is rolled back and the error is returned; otherwise they're all committed together. A panic inside fn
+// rolls back the same way and is returned as a *PanicError. Reads made through tx observe its own uncommitted writes.
+func Tx(store Backend, fn func(tx Transaction) error) error {
+	transactor, ok := store.(Transactor)
+	if !ok {
+		return ErrTransactionsUnsupported
+	}
+
+	return transactor.Tx(func(tx Transaction) (err error) {
+		defer Recover(&err)
+
+		return fn(tx)
+	})
+}
@@ -0,0 +1,121 @@
+package kvbase
+
+import (
+	"errors"
+	"github.com/Wolveix/kvbase/internal/envelope"
+	"strconv"
+)
+
+// transform rewrites the bytes produced by the store's codec, such as to compress or encrypt them. Values written
+// through transforms are enveloped with the name of every transform applied, so that they can be reverted on read
+// whatever options the store is opened with.
+type transform interface {
+	name() string
+	apply(data []byte) ([]byte, error)
+	revert(data []byte) ([]byte, error)
+}
+
+// envelopeCodec wraps the store's codec, applying its transforms to every value written and reverting the transforms
+// named by the envelope of every value read. Values written without transforms are stored bare.
+type envelopeCodec struct {
+	Codec
+	codec      string
+	transforms []transform
+
+	// encrypted refuses values written without encryption, so that plaintext can't be passed off as the store's own
+	encrypted bool
+}
+
+// Marshal encodes model with the wrapped codec and applies every transform to the result
+func (codec envelopeCodec) Marshal(model interface{}) ([]byte, error) {
+	data, err := codec.Codec.Marshal(model)
+	if err != nil || len(codec.transforms) == 0 {
+		return data, err
+	}
+
+	value := envelope.Envelope{Codec: codec.codec}
+
+	for _, transform := range codec.transforms {
+		if data, err = transform.apply(data); err != nil {
+			return nil, err
+		}
+
+		value.Transforms = append(value.Transforms, transform.name())
+	}
+
+	value.Payload = data
+
+	return value.Encode(), nil
+}
+
+// Unmarshal reverts the transforms named by data's envelope and decodes the result with the wrapped codec
+func (codec envelopeCodec) Unmarshal(data []byte, model interface{}) error {
+	value, err := envelope.Decode(data)
+	if err != nil {
+		// JSON documents never start with the magic byte, but other codecs' bare values may
+		if codec.codec != "json" {
+			value = &envelope.Envelope{Legacy: true, Payload: data}
+		} else {
+			return err
+		}
+	}
+
+	if codec.encrypted && !contains(value.Transforms, encryptionTransform) {
+		return ErrDecryptionFailed
+	}
+
+	if value.Codec != "" && codec.codec != "" && value.Codec != codec.codec {
+		return errors.New("kvbase: value was written with the " + value.Codec + " codec, not " + codec.codec)
+	}
+
+	data = value.Payload
+
+	for i := len(value.Transforms) - 1; i >= 0; i-- {
+		transform := codec.transform(value.Transforms[i])
+		if transform == nil && value.Transforms[i] == encryptionTransform {
+			return ErrDecryptionFailed
+		} else if transform == nil {
+			return WrapError(ErrUnknownCompression, errors.New("transform "+strconv.Quote(value.Transforms[i])))
+		}
+
+		if data, err = transform.revert(data); err != nil {
+			return err
+		}
+	}
+
+	return codec.Codec.Unmarshal(data, model)
+}
+
+// transform returns the transform with the provided name, or nil when the store doesn't know it. Compression doesn't
+// depend on the store's options, so every store knows every compression scheme.
+func (codec envelopeCodec) transform(name string) transform {
+	for _, transform := range codec.transforms {
+		if transform.name() == name {
+			return transform
+		}
+	}
+
+	return compressions[name]
+}
+
+// codecName returns the name values encoded by codec are tagged with, or "" for codecs defined outside of kvbase
+func codecName(codec Codec) string {
+	switch codec.(type) {
+	case JSONCodec, canonicalCodec:
+		return "json"
+	case GobCodec:
+		return "gob"
+	}
+
+	return ""
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
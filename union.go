@@ -0,0 +1,123 @@
+package kvbase
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Reader implements the read-only subset of the Backend interface
+type Reader interface {
+	Count(bucket string) (int, error)
+	Get(bucket string, model interface{}) (*map[string]interface{}, error)
+	Keys(bucket string) ([]string, error)
+	Read(bucket string, key string, model interface{}) error
+}
+
+// UnionReader is a Reader serving records from a primary reader, falling back to a secondary one
+type UnionReader struct {
+	primary    Reader
+	secondary  Reader
+	onFallback func(bucket string, key string)
+}
+
+// NewUnionReader returns a Reader serving records from primary, falling back to secondary for records primary doesn't
+// hold. When onFallback isn't nil, it's called for every record served from secondary by Read.
+func NewUnionReader(primary Reader, secondary Reader, onFallback func(bucket string, key string)) *UnionReader {
+	return &UnionReader{
+		primary:    primary,
+		secondary:  secondary,
+		onFallback: onFallback,
+	}
+}
+
+// Count returns the number of distinct keys inside of the provided bucket across both readers
+func (union *UnionReader) Count(bucket string) (int, error) {
+	keys, err := union.Keys(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(keys), nil
+}
+
+// Exists reports whether either reader holds a record under the provided key. The record is read as raw JSON, so
+// readers over stores using another codec can't be checked.
+func (union *UnionReader) Exists(bucket string, key string) (bool, error) {
+	for _, reader := range []Reader{union.primary, union.secondary} {
+		var raw json.RawMessage
+		if err := reader.Read(bucket, key, &raw); err == nil {
+			return true, nil
+		} else if !errors.Is(err, ErrKeyNotFound) {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// Get returns all records inside of the provided bucket across both readers, preferring primary on key conflicts
+func (union *UnionReader) Get(bucket string, model interface{}) (*map[string]interface{}, error) {
+	results, err := union.secondary.Get(bucket, model)
+	if err != nil {
+		return nil, err
+	}
+
+	primary, err := union.primary.Get(bucket, model)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range *primary {
+		(*results)[key] = value
+	}
+
+	return results, nil
+}
+
+// Keys returns the keys of every record inside of the provided bucket across both readers, in sorted order
+func (union *UnionReader) Keys(bucket string) ([]string, error) {
+	primary, err := union.primary.Keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	secondary, err := union.secondary.Keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(primary)+len(secondary))
+
+	// Both lists are sorted, so they're merged in a single pass
+	for len(primary) > 0 || len(secondary) > 0 {
+		switch {
+		case len(secondary) == 0 || len(primary) > 0 && primary[0] < secondary[0]:
+			keys, primary = append(keys, primary[0]), primary[1:]
+		case len(primary) == 0 || secondary[0] < primary[0]:
+			keys, secondary = append(keys, secondary[0]), secondary[1:]
+		default:
+			keys, primary, secondary = append(keys, primary[0]), primary[1:], secondary[1:]
+		}
+	}
+
+	return keys, nil
+}
+
+// Read returns a single struct from primary, or from secondary when primary doesn't hold the key. Any other error from
+// primary is returned as is.
+func (union *UnionReader) Read(bucket string, key string, model interface{}) error {
+	err := union.primary.Read(bucket, key, model)
+	if !errors.Is(err, ErrKeyNotFound) {
+		return err
+	}
+
+	if err := union.secondary.Read(bucket, key, model); err != nil {
+		return err
+	}
+
+	if union.onFallback != nil {
+		union.onFallback(bucket, key)
+	}
+
+	return nil
+}